@@ -15,6 +15,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 
 	"gorm.io/gorm/schema"
 
@@ -28,8 +29,14 @@ import (
 	"github.com/islax/microapp/config"
 	microappCtx "github.com/islax/microapp/context"
 	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/event/idempotency"
+	"github.com/islax/microapp/event/monitor"
+	"github.com/islax/microapp/event/rabbitmq"
+	"github.com/islax/microapp/health"
 	"github.com/islax/microapp/log"
 	"github.com/islax/microapp/metrics"
+	"github.com/islax/microapp/outbox"
+	"github.com/islax/microapp/purge"
 	"github.com/islax/microapp/repository"
 	"github.com/islax/microapp/retry"
 	"github.com/islax/microapp/security"
@@ -55,11 +62,21 @@ type App struct {
 	Name            string
 	Config          *config.Config
 	DB              *gorm.DB
+	DBResolver      *repository.DBResolver
 	MemcachedClient *memcache.Client
 	Router          *mux.Router
 	server          *http.Server
 	log             zerolog.Logger
 	eventDispatcher event.Dispatcher
+	rabbitMQ        *rabbitmq.ConnectionManager
+	lifecycle       lifecycle
+	healthRegistry  *health.Registry
+	outboxRelay     *outbox.Relay
+	purger          *purge.Purger
+
+	processedMessages     idempotency.ProcessedMessageStore
+	processedMessagesErr  error
+	processedMessagesOnce sync.Once
 }
 
 // NewWithEnvValues creates a new application with environment variable values for initializing database, event dispatcher and logger.
@@ -80,9 +97,18 @@ func NewWithEnvValues(appName string, appConfigDefaults map[string]interface{})
 
 	var err error
 	var appEventDispatcher event.Dispatcher
+	var rabbitMQConnectionManager *rabbitmq.ConnectionManager
+	isInMemoryBroker := strings.EqualFold(appConfig.GetString(config.EvSuffixForEventBroker), event.BrokerInMemory)
+	monitor.SetDefaultBroker(appConfig.GetString(config.EvSuffixForEventBroker))
 	if appConfig.GetStringWithDefault("ENABLE_EVENT_DISPATCHER", "0") == "1" || appConfig.GetStringWithDefault("LOG_TO_EVENTQ", "0") == "1" {
-		if appEventDispatcher, err = event.NewRabbitMQEventDispatcher(consoleOnlyLogger); err != nil {
-			consoleOnlyLogger.Fatal().Err(err).Msg("Failed to initialize event dispatcher to queue, exiting the application!")
+		if isInMemoryBroker {
+			appEventDispatcher = event.DefaultInMemoryBroker()
+		} else {
+			rabbitMQConnectionManager = rabbitmq.NewConnectionManager(rabbitmq.NewConfig(appConfig), consoleOnlyLogger)
+			rabbitmq.SetDefaultConnectionManager(rabbitMQConnectionManager)
+			if appEventDispatcher, err = event.NewRabbitMQEventDispatcherWithConnectionManager(consoleOnlyLogger, rabbitMQConnectionManager); err != nil {
+				consoleOnlyLogger.Fatal().Err(err).Msg("Failed to initialize event dispatcher to queue, exiting the application!")
+			}
 		}
 		if appConfig.GetStringWithDefault("LOG_TO_EVENTQ", "0") == "1" {
 			multiWriters = io.MultiWriter(os.Stdout, event.NewEventQWriter(appEventDispatcher))
@@ -93,12 +119,27 @@ func NewWithEnvValues(appName string, appConfigDefaults map[string]interface{})
 	} else {
 		consoleOnlyLogger.Warn().Msg("Event dispatcher not enabled. Please set ISLA_ENABLE_EVENT_DISPATCHER or ISLA_LOG_TO_EVENTQ to '1' to enable it.")
 	}
+	if cloudEventsMode := appConfig.GetString(config.EvSuffixForCloudEventsMode); cloudEventsMode != "" {
+		cloudEventsEncoder, ok := appEventDispatcher.(interface {
+			UseCloudEvents(mode string, source string) error
+		})
+		if ok {
+			if err = cloudEventsEncoder.UseCloudEvents(cloudEventsMode, strings.ToLower(strings.ReplaceAll(appName, " ", ""))); err != nil {
+				consoleOnlyLogger.Fatal().Err(err).Msg("Failed to enable CloudEvents, exiting the application!")
+			}
+		}
+	}
 	//TODO: default module to system
 	appLogger := log.New(appName, appConfig.GetString("LOG_LEVEL"), multiWriters)
-	//TODO: Need to wait till eventDispatcher is ready
-	time.Sleep(5 * time.Second)
+	if rabbitMQConnectionManager != nil {
+		waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err = rabbitMQConnectionManager.WaitUntilConnected(waitCtx); err != nil {
+			consoleOnlyLogger.Warn().Err(err).Msg("RabbitMQ not connected yet, events will be spooled till the connection is established.")
+		}
+		cancel()
+	}
 
-	app := App{Name: appName, Config: appConfig, log: *appLogger, eventDispatcher: appEventDispatcher}
+	app := App{Name: appName, Config: appConfig, log: *appLogger, eventDispatcher: appEventDispatcher, rabbitMQ: rabbitMQConnectionManager}
 	err = app.initializeDB()
 	if err != nil {
 		consoleOnlyLogger.Fatal().Err(err).Msg("Failed to initialize database, exiting the application!!")
@@ -115,42 +156,32 @@ func NewWithEnvValues(appName string, appConfigDefaults map[string]interface{})
 	}
 	http.DefaultTransport.(*http.Transport).TLSClientConfig = tlsConfig
 
+	app.registerDBPlugins()
+	app.registerDefaultStopHooks()
+	app.registerDefaultHealthCheckers()
 	return &app
 }
 
 // New creates a new microApp
 func New(appName string, appConfigDefaults map[string]interface{}, appLog zerolog.Logger, appDB *gorm.DB, appMemcache *memcache.Client, appEventDispatcher event.Dispatcher) *App {
 	appConfig := config.NewConfig(appConfigDefaults)
-	return &App{Name: appName, Config: appConfig, log: appLog, DB: appDB, MemcachedClient: appMemcache, eventDispatcher: appEventDispatcher}
+	app := &App{Name: appName, Config: appConfig, log: appLog, DB: appDB, MemcachedClient: appMemcache, eventDispatcher: appEventDispatcher}
+	app.registerDBPlugins()
+	app.registerDefaultStopHooks()
+	app.registerDefaultHealthCheckers()
+	return app
 }
 
 func (app *App) initializeDB() error {
 	if app.Config.GetBool(config.EvSuffixForDBRequired) {
+		if err := registerTLSConfig(app.Config.GetString("DB_SSL_CA_PATH"), app.Config.GetString("DB_SSL_CERT_PATH"), app.Config.GetString("DB_SSL_KEY_PATH")); err != nil {
+			app.log.Warn().Err(err).Msgf("TLS config error [%v]. Connecting without certificates", err)
+		}
+
 		var db *gorm.DB
 		err := retry.Do(3, time.Second*15, func() error {
-			//gorm custom logger
-			dbLogger := log.NewGormLogger(app.log, log.Config{SlowThreshold: time.Duration(app.Config.GetInt(config.EvSuffixForGormSlowThreshold)) * time.Millisecond})
 			var err error
-			dbconf := &gorm.Config{PrepareStmt: true, Logger: dbLogger}
-
-			if app.Config.GetBool("DB_NAMING_STRATEGY_IS_SINGULAR") {
-				dbconf.NamingStrategy = schema.NamingStrategy{SingularTable: true}
-			}
-
-			if err = registerTLSConfig(app.Config.GetString("DB_SSL_CA_PATH"), app.Config.GetString("DB_SSL_CERT_PATH"), app.Config.GetString("DB_SSL_KEY_PATH")); err != nil {
-				app.log.Warn().Err(err).Msgf("TLS config error [%v]. Connecting without certificates", err)
-			}
-
-			sqlDB, err := sql.Open("mysql", app.GetConnectionString())
-			if err != nil {
-				app.log.Error().Err(err).Msgf("Error creating connection pool [%v]. Trying again...", err)
-			}
-			sqlDB.SetConnMaxLifetime(time.Duration(app.Config.GetInt(config.EvSuffixForDBConnectionLifetime)) * time.Minute)
-			sqlDB.SetMaxIdleConns(app.Config.GetInt(config.EvSuffixForDBMaxIdleConnections))
-			sqlDB.SetMaxOpenConns(app.Config.GetInt(config.EvSuffixForDBMaxOpenConnections))
-			db, err = gorm.Open(gormmysqldriver.New(gormmysqldriver.Config{
-				Conn: sqlDB,
-			}), dbconf)
+			db, err = app.openDB(app.GetConnectionString(), false)
 			if err != nil && strings.Contains(err.Error(), "connection refused") {
 				app.log.Warn().Msgf("Error connecting to Database [%v]. Trying again...", err)
 				return err
@@ -159,12 +190,88 @@ func (app *App) initializeDB() error {
 			return retry.Stop{OriginalError: err}
 		})
 		app.DB = db
+		if err != nil {
+			return err
+		}
 		app.log.Info().Msg("Database connected!")
-		return err
+		return app.initializeDBReplicas()
+	}
+	return nil
+}
+
+// initializeDBReplicas opens the read replicas configured with DB_REPLICA_HOSTS, the read-only units of work are routed to the healthy replicas
+func (app *App) initializeDBReplicas() error {
+	replicaHosts := strings.Split(app.Config.GetString(config.EvSuffixForDBReplicaHosts), ",")
+	replicas := make(map[string]*gorm.DB)
+	for _, replicaHost := range replicaHosts {
+		replicaHost = strings.TrimSpace(replicaHost)
+		if replicaHost == "" {
+			continue
+		}
+		replica, err := app.openDB(app.getReplicaConnectionString(replicaHost), true)
+		if err != nil {
+			return fmt.Errorf("unable to open read replica [%v]: %w", replicaHost, err)
+		}
+		replicas[replicaHost] = replica
+	}
+	if len(replicas) == 0 {
+		return nil
 	}
+
+	app.DBResolver = repository.NewDBResolver(app.DB, replicas, repository.DBResolverOptions{
+		HealthCheckInterval:  time.Duration(app.Config.GetInt(config.EvSuffixForDBReplicaHealthCheckInterval)) * time.Second,
+		ReadYourWritesWindow: time.Duration(app.Config.GetInt(config.EvSuffixForDBReadYourWritesWindow)) * time.Second,
+	}, app.log)
+	app.DBResolver.Start()
+	app.log.Info().Int("replicas", len(replicas)).Msg("Database read replicas configured!")
 	return nil
 }
 
+// openDB opens the connection pool, the replicas are not connected till they are used or health checked
+func (app *App) openDB(connectionString string, isReplica bool) (*gorm.DB, error) {
+	//gorm custom logger
+	dbLogger := log.NewGormLogger(app.log, log.Config{SlowThreshold: time.Duration(app.Config.GetInt(config.EvSuffixForGormSlowThreshold)) * time.Millisecond})
+	dbconf := &gorm.Config{PrepareStmt: true, Logger: dbLogger, DisableAutomaticPing: isReplica}
+
+	if app.Config.GetBool("DB_NAMING_STRATEGY_IS_SINGULAR") {
+		dbconf.NamingStrategy = schema.NamingStrategy{SingularTable: true}
+	}
+
+	sqlDB, err := sql.Open("mysql", connectionString)
+	if err != nil {
+		app.log.Error().Err(err).Msgf("Error creating connection pool [%v]. Trying again...", err)
+		return nil, err
+	}
+	sqlDB.SetConnMaxLifetime(time.Duration(app.Config.GetInt(config.EvSuffixForDBConnectionLifetime)) * time.Minute)
+	sqlDB.SetMaxIdleConns(app.Config.GetInt(config.EvSuffixForDBMaxIdleConnections))
+	sqlDB.SetMaxOpenConns(app.Config.GetInt(config.EvSuffixForDBMaxOpenConnections))
+	return gorm.Open(gormmysqldriver.New(gormmysqldriver.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: isReplica,
+	}), dbconf)
+}
+
+// registerDBPlugins registers the gorm plugins of the app with the database and its read replicas
+func (app *App) registerDBPlugins() {
+	if app.DB == nil {
+		return
+	}
+	dbs := []*gorm.DB{app.DB}
+	if app.DBResolver != nil {
+		dbs = append(dbs, app.DBResolver.Replicas()...)
+	}
+	for _, db := range dbs {
+		if err := db.Use(repository.NewStatementTimeoutPlugin()); err != nil && err != gorm.ErrRegistered {
+			app.log.Error().Err(err).Msg("Failed to register statement timeout plugin.")
+		}
+		if app.Config.GetBool(config.EvSuffixForDBTenantScoping) {
+			if err := db.Use(repository.NewTenantScopePlugin()); err != nil && err != gorm.ErrRegistered {
+				app.log.Error().Err(err).Msg("Failed to register tenant scope plugin.")
+			}
+		}
+	}
+}
+
 // GetConnectionString gets database connection string
 func (app *App) GetConnectionString() string {
 	dbHost := app.Config.GetString("DB_HOST")
@@ -176,9 +283,70 @@ func (app *App) GetConnectionString() string {
 	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?multiStatements=true&charset=utf8&parseTime=True&loc=Local&tls=preferred", dbUser, dbPassword, dbHost, dbPort, dbName)
 }
 
-// NewUnitOfWork creates new UnitOfWork
+// getReplicaConnectionString gets read replica connection string for the host or host:port, the port, user and password of the primary are used if not configured
+func (app *App) getReplicaConnectionString(replicaHost string) string {
+	dbHost, dbPort, err := net.SplitHostPort(replicaHost)
+	if err != nil {
+		dbHost, dbPort = replicaHost, app.Config.GetString("DB_PORT")
+	}
+	dbName := app.Config.GetString("DB_NAME")
+	dbUser := app.Config.GetStringWithDefault(config.EvSuffixForDBReplicaUser, app.Config.GetString("DB_USER"))
+	dbPassword := app.Config.GetStringWithDefault(config.EvSuffixForDBReplicaPassword, app.Config.GetString("DB_PWD"))
+
+	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?multiStatements=true&charset=utf8&parseTime=True&loc=Local&tls=preferred", dbUser, dbPassword, dbHost, dbPort, dbName)
+}
+
+// NewUnitOfWork creates new UnitOfWork, the read-only unit of work uses a healthy read replica if the replicas are configured
 func (app *App) NewUnitOfWork(readOnly bool, logger zerolog.Logger) *repository.UnitOfWork {
-	return repository.NewUnitOfWork(app.DB, readOnly, logger, log.Config{SlowThreshold: time.Duration(app.Config.GetInt(config.EvSuffixForGormSlowThreshold)) * time.Millisecond})
+	return app.newUnitOfWork(context.Background(), readOnly, logger, "")
+}
+
+// newUnitOfWork creates new UnitOfWork with the context and the statement timeout as per readOnly,
+// the reads of the correlation id are routed to the primary for the read-your-writes window after its unit of work commits
+func (app *App) newUnitOfWork(ctx context.Context, readOnly bool, logger zerolog.Logger, correlationID string) *repository.UnitOfWork {
+	logConfig := log.Config{SlowThreshold: time.Duration(app.Config.GetInt(config.EvSuffixForGormSlowThreshold)) * time.Millisecond}
+	statementTimeout := time.Duration(app.Config.GetInt(config.EvSuffixForDBWriteStatementTimeout)) * time.Second
+	if readOnly {
+		statementTimeout = time.Duration(app.Config.GetInt(config.EvSuffixForDBReadStatementTimeout)) * time.Second
+	}
+
+	var uow *repository.UnitOfWork
+	if app.DBResolver != nil && readOnly {
+		uow = repository.NewUnitOfWorkWithContext(ctx, app.DBResolver.ReadDB(correlationID), readOnly, logger, logConfig)
+	} else {
+		uow = repository.NewUnitOfWorkWithContext(ctx, app.DB, readOnly, logger, logConfig)
+	}
+	uow.SetStatementTimeout(statementTimeout)
+	if app.DBResolver != nil && !readOnly {
+		uow.OnCommit(func() {
+			app.DBResolver.PinToPrimary(correlationID)
+		})
+	}
+	return uow
+}
+
+// HealthRegistry returns the registry of the health checkers used by the liveness and readiness endpoints
+func (app *App) HealthRegistry() *health.Registry {
+	return app.healthRegistry
+}
+
+// registerDefaultHealthCheckers registers readiness checkers for the dependencies of the app, the registry is used by the default health controller
+func (app *App) registerDefaultHealthCheckers() {
+	app.healthRegistry = health.NewRegistry(time.Duration(app.Config.GetInt(config.EvSuffixForHealthCheckTimeout)) * time.Second)
+	health.SetDefaultRegistry(app.healthRegistry)
+
+	if app.DB != nil {
+		app.healthRegistry.RegisterReadinessChecker(health.NewDBChecker(app.DB))
+	}
+	if app.MemcachedClient != nil {
+		app.healthRegistry.RegisterReadinessChecker(health.NewMemcachedChecker(app.MemcachedClient))
+	}
+	if connectionStateProvider, ok := app.eventDispatcher.(health.ConnectionStateProvider); ok {
+		app.healthRegistry.RegisterReadinessChecker(health.NewConnectionChecker("eventdispatcher", connectionStateProvider))
+	}
+	if app.rabbitMQ != nil {
+		app.healthRegistry.RegisterReadinessChecker(health.NewConnectionChecker("rabbitmq", app.rabbitMQ))
+	}
 }
 
 //Initialize initializes properties of the app
@@ -227,6 +395,9 @@ func (app *App) Initialize(routeSpecifiers []RouteSpecifier) {
 		IdleTimeout:  time.Second * time.Duration(app.Config.GetInt("HTTP_IDLE_TIMEOUT")),
 		Handler:      app.Router,
 	}
+	app.OnStop("http-server", LifecyclePriorityHTTPServer, 2*time.Minute, func(ctx context.Context) error {
+		return app.server.Shutdown(ctx)
+	})
 }
 
 //Start http server and start listening to the requests
@@ -315,20 +486,14 @@ func (app *App) MigrateDB() {
 	logger.Info().Msg("DB Migration End!")
 }
 
-// Stop http server
+// Stop executes the registered stop hooks in the order of their priority, stopping the http server first and closing the database last.
+// Stop is safe to be called multiple times, hooks are executed only once.
 func (app *App) Stop() {
-	wait, _ := time.ParseDuration("2m")
-	ctx, cancel := context.WithTimeout(context.Background(), wait)
-	defer cancel()
-
-	app.server.Shutdown(ctx)
-
-	if app.Config.GetBool("DB_REQUIRED") {
-		sqlDB, err := app.DB.DB()
-		if err != nil {
-			sqlDB.Close()
-		}
-	}
+	app.lifecycle.stopOnce.Do(func() {
+		app.log.Info().Msg("Stopping the application...")
+		app.executeLifecycleHooks("stop", app.lifecycle.stopHooks, false)
+		app.log.Info().Msg("Application stopped!")
+	})
 }
 
 type httpStatusRecorder struct {
@@ -351,11 +516,11 @@ func (app *App) loggingMiddleware(next http.Handler) http.Handler {
 		logger := app.Logger("Ingress").With().Timestamp().Str("caller", r.Header.Get("X-Client")).Str("correlationId", r.Header.Get("X-Correlation-ID")).Str("method", r.Method).Str("requestURI", r.RequestURI).Logger()
 
 		rec := &httpStatusRecorder{ResponseWriter: w}
-		if (!strings.HasSuffix(r.RequestURI, "/health") || app.Config.GetBool(config.EvSuffixForEnableHealthLog)) && !strings.HasSuffix(r.RequestURI, "/metrics") {
+		if (!isHealthRequest(r.RequestURI) || app.Config.GetBool(config.EvSuffixForEnableHealthLog)) && !strings.HasSuffix(r.RequestURI, "/metrics") {
 			logger.Info().Msg("Begin")
 		}
 		next.ServeHTTP(rec, r)
-		if (!strings.HasSuffix(r.RequestURI, "/health") || app.Config.GetBool(config.EvSuffixForEnableHealthLog)) && !strings.HasSuffix(r.RequestURI, "/metrics") {
+		if (!isHealthRequest(r.RequestURI) || app.Config.GetBool(config.EvSuffixForEnableHealthLog)) && !strings.HasSuffix(r.RequestURI, "/metrics") {
 			if rec.status >= http.StatusInternalServerError {
 				logger.Error().Int("status", rec.status).Dur("responseTime", time.Now().Sub(startTime)).Msg("End.")
 			} else {
@@ -365,6 +530,10 @@ func (app *App) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+func isHealthRequest(requestURI string) bool {
+	return strings.HasSuffix(requestURI, "/health") || strings.HasSuffix(requestURI, "/health/live") || strings.HasSuffix(requestURI, "/health/ready")
+}
+
 // DispatchEvent delegates to eventDispatcher.
 func (app *App) DispatchEvent(token string, corelationID string, topic string, payload interface{}) {
 	if app.eventDispatcher != nil {
@@ -372,21 +541,146 @@ func (app *App) DispatchEvent(token string, corelationID string, topic string, p
 	}
 }
 
+// DispatchEventWithOptions dispatches the event with the token, correlation id and tenant id of the execution context unless they are set in the options.
+// If the event dispatcher does not support options, only the token and correlation id are dispatched.
+func (app *App) DispatchEventWithOptions(context microappCtx.ExecutionContext, topic string, payload interface{}, options *event.DispatchOptions) {
+	if app.eventDispatcher == nil {
+		return
+	}
+
+	dispatchOptions := event.DispatchOptions{}
+	if options != nil {
+		dispatchOptions = *options
+	}
+	if dispatchOptions.CorrelationID == "" {
+		dispatchOptions.CorrelationID = context.GetCorrelationID()
+	}
+	if token := context.GetToken(); token != nil {
+		if dispatchOptions.Token == "" {
+			dispatchOptions.Token = token.Raw
+		}
+		if dispatchOptions.TenantID == "" {
+			dispatchOptions.TenantID = token.TenantID.String()
+		}
+	}
+
+	if optionsDispatcher, ok := app.eventDispatcher.(event.OptionsDispatcher); ok {
+		optionsDispatcher.DispatchEventWithOptions(topic, payload, &dispatchOptions)
+		return
+	}
+	app.eventDispatcher.DispatchEvent(dispatchOptions.Token, dispatchOptions.CorrelationID, topic, payload)
+}
+
+// EnableOutbox creates the outbox table if required and starts the relay which publishes the events enqueued using UnitOfWork.Enqueue
+func (app *App) EnableOutbox() error {
+	if app.DB == nil {
+		return errors.New("outbox requires database")
+	}
+	if app.eventDispatcher == nil {
+		return errors.New("outbox requires event dispatcher")
+	}
+	if app.outboxRelay != nil {
+		return nil
+	}
+	if err := outbox.Migrate(app.DB); err != nil {
+		return err
+	}
+	app.outboxRelay = outbox.NewRelay(app.DB, app.eventDispatcher, app.Logger("OutboxRelay"), app.Config)
+	app.outboxRelay.Start()
+	app.OnStop("outbox-relay", LifecyclePriorityWorker, 0, app.outboxRelay.Stop)
+	return nil
+}
+
+// EnableAudit creates the audit log table if required and registers the plugin which records the changes of the models implementing model.Auditable.
+// The changes are attributed to the token and the action of the execution context of the unit of work.
+func (app *App) EnableAudit() error {
+	if app.DB == nil {
+		return errors.New("audit requires database")
+	}
+	if err := repository.MigrateAuditLog(app.DB); err != nil {
+		return err
+	}
+	if err := app.DB.Use(repository.NewAuditPlugin()); err != nil && err != gorm.ErrRegistered {
+		return err
+	}
+	return nil
+}
+
+// EnablePurge starts the purger which permanently removes the soft deleted rows of the models older than their retention
+func (app *App) EnablePurge(policies ...purge.Policy) error {
+	if app.DB == nil {
+		return errors.New("purge requires database")
+	}
+	if app.purger != nil {
+		return errors.New("purge already enabled")
+	}
+	app.purger = purge.NewPurger(app.DB, policies, app.Logger("Purger"), app.Config)
+	app.purger.Start()
+	app.OnStop("purger", LifecyclePriorityWorker, 0, app.purger.Stop)
+	return nil
+}
+
+// EnableProcessedMessageStore creates the processed message table if required and starts the purger which removes the processed messages older than PROCESSED_MESSAGE_RETENTION.
+// It returns the database store used by the event routers to deduplicate the events, subsequent calls return the same store.
+func (app *App) EnableProcessedMessageStore() (idempotency.ProcessedMessageStore, error) {
+	app.processedMessagesOnce.Do(func() {
+		if app.DB == nil {
+			app.processedMessagesErr = errors.New("processed message store requires database")
+			return
+		}
+		if err := idempotency.Migrate(app.DB); err != nil {
+			app.processedMessagesErr = err
+			return
+		}
+		purger := idempotency.NewPurger(app.DB, app.Logger("ProcessedMessagePurger"), app.Config)
+		purger.Start()
+		app.OnStop("processed-message-purger", LifecyclePriorityWorker, 0, purger.Stop)
+		app.processedMessages = idempotency.NewDBProcessedMessageStore()
+	})
+	return app.processedMessages, app.processedMessagesErr
+}
+
+// IsOutboxEnabled returns whether the events are published through the outbox
+func (app *App) IsOutboxEnabled() bool {
+	return app.outboxRelay != nil
+}
+
 // NewExecutionContext creates new exectuion context
 func (app *App) NewExecutionContext(token *security.JwtToken, correlationID string, action string, isUOWReqd, isUOWReadonly bool) microappCtx.ExecutionContext {
+	return app.NewExecutionContextWithContext(context.Background(), token, correlationID, action, isUOWReqd, isUOWReadonly)
+}
+
+// NewExecutionContextWithContext creates new exectuion context whose unit of work runs the statements with the context,
+// pass the context of the http request so that the statements are cancelled when the client disconnects.
+// The unit of work is scoped to the tenant of the token, except for the admin tokens which can access all the tenants.
+func (app *App) NewExecutionContextWithContext(ctx context.Context, token *security.JwtToken, correlationID string, action string, isUOWReqd, isUOWReadonly bool) microappCtx.ExecutionContext {
 	executionContext := microappCtx.NewExecutionContext(token, correlationID, action, app.log)
 	if isUOWReqd {
-		uow := app.NewUnitOfWork(isUOWReadonly, *executionContext.GetDefaultLogger())
+		uow := app.newUnitOfWork(ctx, isUOWReadonly, *executionContext.GetDefaultLogger(), executionContext.GetCorrelationID())
+		if token != nil {
+			uow.SetEventContext(token.Raw, executionContext.GetCorrelationID())
+			uow.SetEventTenant(token.TenantID)
+			if !token.Admin {
+				uow.SetTenant(token.TenantID)
+			}
+		}
+		uow.SetAuditActor(newAuditActor(executionContext))
 		executionContext.SetUOW(uow)
 	}
 	return executionContext
 }
 
-// NewExecutionContextWithCustomToken creates new exectuion context with custom made token
+// NewExecutionContextWithCustomToken creates new exectuion context with custom made token, the unit of work is scoped to the tenant unless admin is true
 func (app *App) NewExecutionContextWithCustomToken(tenantID uuid.UUID, userID uuid.UUID, username string, correlationID string, action string, admin, isUOWReqd, isUOWReadonly bool) microappCtx.ExecutionContext {
 	executionContext := microappCtx.NewExecutionContext(&security.JwtToken{Admin: admin, TenantID: tenantID, UserID: userID, UserName: username}, correlationID, action, app.log)
 	if isUOWReqd {
-		uow := app.NewUnitOfWork(isUOWReadonly, *executionContext.GetDefaultLogger())
+		uow := app.newUnitOfWork(context.Background(), isUOWReadonly, *executionContext.GetDefaultLogger(), executionContext.GetCorrelationID())
+		uow.SetEventContext("", executionContext.GetCorrelationID())
+		uow.SetEventTenant(tenantID)
+		if !admin {
+			uow.SetTenant(tenantID)
+		}
+		uow.SetAuditActor(newAuditActor(executionContext))
 		executionContext.SetUOW(uow)
 	}
 	return executionContext
@@ -396,12 +690,24 @@ func (app *App) NewExecutionContextWithCustomToken(tenantID uuid.UUID, userID uu
 func (app *App) NewExecutionContextWithSystemToken(correlationID string, action string, admin, isUOWReqd, isUOWReadonly bool) microappCtx.ExecutionContext {
 	executionContext := microappCtx.NewExecutionContext(&security.JwtToken{Admin: admin, TenantID: uuid.Nil, UserID: uuid.Nil, TenantName: "None", UserName: "System", DisplayName: "System"}, correlationID, action, app.log)
 	if isUOWReqd {
-		uow := app.NewUnitOfWork(isUOWReadonly, *executionContext.GetDefaultLogger())
+		uow := app.newUnitOfWork(context.Background(), isUOWReadonly, *executionContext.GetDefaultLogger(), executionContext.GetCorrelationID())
+		uow.SetEventContext("", executionContext.GetCorrelationID())
+		uow.SetEventTenant(uuid.Nil)
+		uow.SetAuditActor(newAuditActor(executionContext))
 		executionContext.SetUOW(uow)
 	}
 	return executionContext
 }
 
+// newAuditActor attributes the changes made in the execution context to its token and action
+func newAuditActor(executionContext microappCtx.ExecutionContext) repository.AuditActor {
+	actor := repository.AuditActor{CorrelationID: executionContext.GetCorrelationID(), Action: executionContext.GetActionName()}
+	if token := executionContext.GetToken(); token != nil {
+		actor.TenantID, actor.UserID, actor.UserName = token.TenantID, token.UserID, token.UserName
+	}
+	return actor
+}
+
 // GetCorrelationIDFromRequest returns correlationId from request header
 func GetCorrelationIDFromRequest(r *http.Request) string {
 	return r.Header.Get("X-Correlation-ID")
@@ -0,0 +1,87 @@
+package microapp
+
+import (
+	"testing"
+
+	"github.com/islax/microapp/config"
+	"github.com/islax/microapp/model"
+	"github.com/islax/microapp/repository"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type tenantItem struct {
+	model.TenantBase
+	Name string
+}
+
+// newTenantScopeTestApp creates an app on an in-memory sqlite database having an item for each of the tenants
+func newTenantScopeTestApp(t *testing.T, appConfigDefaults map[string]interface{}, tenantIDs ...uuid.UUID) *App {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Each connection opens a new in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	if err = db.AutoMigrate(&tenantItem{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tenantID := range tenantIDs {
+		if err = db.Create(&tenantItem{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: tenantID}}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New("test", appConfigDefaults, zerolog.Nop(), db, nil, nil)
+}
+
+func TestTenantScoping(t *testing.T) {
+	tenantID, otherTenantID := uuid.NewV4(), uuid.NewV4()
+	count := func(uow *repository.UnitOfWork, queryProcessors ...repository.QueryProcessor) int {
+		var items []tenantItem
+		if err := repository.NewRepository().GetAll(uow, &items, queryProcessors); err != nil {
+			t.Fatal(err)
+		}
+		return len(items)
+	}
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		app := newTenantScopeTestApp(t, nil, tenantID, otherTenantID)
+		context := app.NewExecutionContextWithCustomToken(tenantID, uuid.NewV4(), "user", "", "test", false, true, true)
+		if items := count(context.GetUOW()); items != 2 {
+			t.Errorf("Expected the queries not to be scoped unless %v is set, Actual [%v] items", config.EvSuffixForDBTenantScoping, items)
+		}
+	})
+
+	app := newTenantScopeTestApp(t, map[string]interface{}{config.EvSuffixForDBTenantScoping: true}, tenantID, otherTenantID)
+
+	t.Run("Scoped", func(t *testing.T) {
+		context := app.NewExecutionContextWithCustomToken(tenantID, uuid.NewV4(), "user", "", "test", false, true, true)
+		if items := count(context.GetUOW()); items != 1 {
+			t.Errorf("Expected only the items of the tenant, Actual [%v] items", items)
+		}
+	})
+
+	t.Run("CrossTenant", func(t *testing.T) {
+		context := app.NewExecutionContextWithCustomToken(tenantID, uuid.NewV4(), "System", "", "test", false, true, true)
+		if items := count(context.GetUOW(), repository.CrossTenant()); items != 2 {
+			t.Errorf("Expected the items of all the tenants using CrossTenant query processor, Actual [%v] items", items)
+		}
+		if items := count(context.GetUOW().CrossTenant()); items != 2 {
+			t.Errorf("Expected the items of all the tenants using the cross tenant unit of work, Actual [%v] items", items)
+		}
+		if items := count(context.GetUOW()); items != 1 {
+			t.Errorf("Expected the unit of work to remain scoped, Actual [%v] items", items)
+		}
+	})
+
+	t.Run("NonAdminSystemToken", func(t *testing.T) {
+		context := app.NewExecutionContextWithSystemToken("", "test", false, true, true)
+		if items := count(context.GetUOW()); items != 2 {
+			t.Errorf("Expected the system token without tenant not to be scoped, Actual [%v] items", items)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/islax/microapp"
+	microappError "github.com/islax/microapp/error"
+	microappLog "github.com/islax/microapp/log"
+	"github.com/islax/microapp/model"
+	microappRepo "github.com/islax/microapp/repository"
+	microappSecurity "github.com/islax/microapp/security"
+	microappWeb "github.com/islax/microapp/web"
+	uuid "github.com/satori/go.uuid"
+)
+
+// NewAuditController creates a new audit controller, the audit must be enabled using App.EnableAudit
+func NewAuditController(app *microapp.App, repository microappRepo.Repository) *AuditController {
+	return &AuditController{app: app, repository: repository}
+}
+
+// AuditController exposes the audit log of the app
+type AuditController struct {
+	app        *microapp.App
+	repository microappRepo.Repository
+}
+
+type auditLogDTO struct {
+	ID            uuid.UUID       `json:"id"`
+	TenantID      uuid.UUID       `json:"tenantId"`
+	UserID        uuid.UUID       `json:"userId"`
+	UserName      string          `json:"userName"`
+	CorrelationID string          `json:"correlationId"`
+	Action        string          `json:"action"`
+	Operation     string          `json:"operation"`
+	EntityType    string          `json:"entityType"`
+	EntityID      string          `json:"entityId"`
+	Changes       json.RawMessage `json:"changes"`
+	CreatedOn     time.Time       `json:"createdOn"`
+}
+
+// RegisterRoutes implements interface RouteSpecifier
+func (controller *AuditController) RegisterRoutes(muxRouter *mux.Router) {
+	auditRouter := muxRouter.PathPrefix(fmt.Sprintf("/api/%s/audit", strings.ToLower(controller.app.Name))).Subrouter()
+	auditRouter.HandleFunc("", microappSecurity.Protect(controller.app.Config, controller.getAll, []string{"audit:read"}, false)).Methods("GET")
+	auditRouter.HandleFunc("/{id}", microappSecurity.Protect(controller.app.Config, controller.get, []string{"audit:read"}, false)).Methods("GET")
+}
+
+// getAll returns the audit logs, latest first. They can be filtered by entityType, entityId, userId, correlationId, operation, action and
+// the start and end of createdOn, admin can filter by tenantId while others get the audit logs of their tenant.
+func (controller *AuditController) getAll(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "audit.getall", true, true)
+	uow := context.GetUOW()
+	defer uow.Complete()
+
+	filters := []string{"entityType", "entityId", "userId", "correlationId", "operation", "action"}
+	if token.Admin {
+		filters = append(filters, "tenantId")
+	}
+	queryProcessors, err := microappRepo.AddFiltersFromQueryParams(r, filters...)
+	if err != nil {
+		microappWeb.RespondError(w, err)
+		return
+	}
+	if !token.Admin {
+		queryProcessors = append(queryProcessors, microappRepo.Filter("tenantId = ?", token.TenantID))
+	}
+	queryProcessors = append(queryProcessors, microappRepo.TimeRangeForWeb(r, "createdOn"), microappRepo.Order("createdOn DESC", false), microappRepo.PaginateForWeb(w, r))
+
+	var auditLogs []model.AuditLog
+	if err := controller.repository.GetAll(uow, &auditLogs, queryProcessors); err != nil {
+		context.LogError(err, fmt.Sprintf(microappLog.MessageGenericErrorTemplate, "getting audit logs"))
+		microappWeb.RespondError(w, err)
+		return
+	}
+
+	auditLogDTOs := make([]auditLogDTO, len(auditLogs))
+	for i, auditLog := range auditLogs {
+		auditLogDTOs[i] = toAuditLogDTO(auditLog)
+	}
+	microappWeb.RespondJSON(w, http.StatusOK, auditLogDTOs)
+}
+
+func (controller *AuditController) get(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "audit.get", true, true)
+	uow := context.GetUOW()
+	defer uow.Complete()
+
+	id, err := uuid.FromString(mux.Vars(r)["id"])
+	if err != nil {
+		microappWeb.RespondError(w, microappError.NewHTTPResourceNotFound("audit", mux.Vars(r)["id"]))
+		return
+	}
+	queryProcessors := []microappRepo.QueryProcessor{microappRepo.Filter("id = ?", id)}
+	if !token.Admin {
+		queryProcessors = append(queryProcessors, microappRepo.Filter("tenantId = ?", token.TenantID))
+	}
+
+	var auditLog model.AuditLog
+	if err := controller.repository.GetFirst(uow, &auditLog, queryProcessors); err != nil {
+		if err.IsRecordNotFoundError() {
+			microappWeb.RespondError(w, microappError.NewHTTPResourceNotFound("audit", id.String()))
+			return
+		}
+		context.LogError(err, fmt.Sprintf(microappLog.MessageGenericErrorTemplate, "getting audit log"))
+		microappWeb.RespondError(w, err)
+		return
+	}
+	microappWeb.RespondJSON(w, http.StatusOK, toAuditLogDTO(auditLog))
+}
+
+func toAuditLogDTO(auditLog model.AuditLog) auditLogDTO {
+	changes := json.RawMessage(auditLog.Changes)
+	if len(changes) == 0 {
+		changes = json.RawMessage("{}")
+	}
+	return auditLogDTO{
+		ID:            auditLog.ID,
+		TenantID:      auditLog.TenantID,
+		UserID:        auditLog.UserID,
+		UserName:      auditLog.UserName,
+		CorrelationID: auditLog.CorrelationID,
+		Action:        auditLog.Action,
+		Operation:     auditLog.Operation,
+		EntityType:    auditLog.EntityType,
+		EntityID:      auditLog.EntityID,
+		Changes:       changes,
+		CreatedOn:     auditLog.CreatedAt,
+	}
+}
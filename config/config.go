@@ -22,6 +22,11 @@ func NewConfig(defaults map[string]interface{}) *Config {
 	config.viper.SetDefault(EvSuffixForDBPassword, "Cyber!nc#")
 	config.viper.SetDefault(EvSuffixForDBConnectionLifetime, 60)
 	config.viper.SetDefault(EvSuffixForDBMaxIdleConnections, 30)
+	config.viper.SetDefault(EvSuffixForDBTenantScoping, false)
+	config.viper.SetDefault(EvSuffixForDBReplicaHealthCheckInterval, 10)
+	config.viper.SetDefault(EvSuffixForDBReadYourWritesWindow, 5)
+	config.viper.SetDefault(EvSuffixForDBReadStatementTimeout, 10)
+	config.viper.SetDefault(EvSuffixForDBWriteStatementTimeout, 10)
 
 	config.viper.SetDefault(EvSuffixForLogLevel, "error")
 
@@ -30,6 +35,29 @@ func NewConfig(defaults map[string]interface{}) *Config {
 	config.viper.SetDefault(EvSuffixForHTTPIdleTimeout, 60)
 
 	config.viper.SetDefault(EvSuffixForHTTPIdleTimeout, 15)
+	config.viper.SetDefault(EvSuffixForShutdownHookTimeout, 30)
+	config.viper.SetDefault(EvSuffixForHealthCheckTimeout, 5)
+	config.viper.SetDefault(EvSuffixForOutboxPollInterval, 1000)
+	config.viper.SetDefault(EvSuffixForOutboxBatchSize, 100)
+	config.viper.SetDefault(EvSuffixForOutboxMaxAttempts, 10)
+	config.viper.SetDefault(EvSuffixForOutboxRetention, 24)
+	config.viper.SetDefault(EvSuffixForPurgeInterval, 24)
+	config.viper.SetDefault(EvSuffixForPurgeBatchSize, 500)
+	config.viper.SetDefault(EvSuffixForPurgeRetention, 30)
+	config.viper.SetDefault(EvSuffixForProcessedMessageRetention, 168)
+	config.viper.SetDefault(EvSuffixForProcessedMessagePurgeInterval, 60)
+	config.viper.SetDefault(EvSuffixForEventRouterWorkers, 4)
+	config.viper.SetDefault(EvSuffixForEventBroker, "rabbitmq")
+	config.viper.SetDefault(EvSuffixForQueueHost, "localhost")
+	config.viper.SetDefault(EvSuffixForQueuePort, "5672")
+	config.viper.SetDefault(EvSuffixForQueueUser, "guest")
+	config.viper.SetDefault(EvSuffixForQueuePassword, "guest")
+	config.viper.SetDefault(EvSuffixForQueueVirtualHost, "/")
+	config.viper.SetDefault(EvSuffixForQueueExchange, "isla_exchange")
+	config.viper.SetDefault(EvSuffixForQueueExchangeType, "topic")
+	config.viper.SetDefault(EvSuffixForQueueHeartbeat, 10)
+	config.viper.SetDefault(EvSuffixForQueueReconnectInitialDelay, 500)
+	config.viper.SetDefault(EvSuffixForQueueReconnectMaxDelay, 30000)
 
 	config.viper.SetDefault("TLS_CRT", "/opt/isla/tls.crt")
 	config.viper.SetDefault("TLS_KEY", "/opt/isla/tls.key")
@@ -20,16 +20,35 @@ const (
 	EvSuffixForDBPassword = "DB_PWD"
 	// EvSuffixForDBPort environment variable name for database port
 	EvSuffixForDBPort = "DB_PORT"
+	// EvSuffixForDBReadStatementTimeout environment variable name for timeout (in seconds) of the statements of the read-only units of work, 0 disables it
+	EvSuffixForDBReadStatementTimeout = "DB_READ_STATEMENT_TIMEOUT"
+	// EvSuffixForDBReadYourWritesWindow environment variable name for duration (in seconds) for which the reads of a correlation id are routed to the primary after it writes, 0 disables it
+	EvSuffixForDBReadYourWritesWindow = "DB_READ_YOUR_WRITES_WINDOW"
+	// EvSuffixForDBReplicaHealthCheckInterval environment variable name for interval (in seconds) of the read replica health checks
+	EvSuffixForDBReplicaHealthCheckInterval = "DB_REPLICA_HEALTH_CHECK_INTERVAL"
+	// EvSuffixForDBReplicaHosts environment variable name for comma separated read replica hosts, host or host:port
+	EvSuffixForDBReplicaHosts = "DB_REPLICA_HOSTS"
+	// EvSuffixForDBReplicaPassword environment variable name for read replica bind user password, DB_PWD is used if empty
+	EvSuffixForDBReplicaPassword = "DB_REPLICA_PWD"
+	// EvSuffixForDBReplicaUser environment variable name for read replica bind user, DB_USER is used if empty
+	EvSuffixForDBReplicaUser = "DB_REPLICA_USER"
 	// EvSuffixForDBRequired environment variable name for database required flag
 	EvSuffixForDBRequired = "DB_REQUIRED"
+	// EvSuffixForDBTenantScoping environment variable name for scoping the queries of the tenant models to the tenant of the unit of work, disabled by default.
+	// Enable it once the queries reading the rows of other tenants with non admin tokens use repository.CrossTenant.
+	EvSuffixForDBTenantScoping = "DB_TENANT_SCOPING"
 	// EvSuffixForDBUser environment variable name for database bind user
 	EvSuffixForDBUser = "DB_USER"
+	// EvSuffixForDBWriteStatementTimeout environment variable name for timeout (in seconds) of the statements of the read-write units of work, 0 disables it
+	EvSuffixForDBWriteStatementTimeout = "DB_WRITE_STATEMENT_TIMEOUT"
 	// EvSuffixForHTTPIdleTimeout environment variable name for HTT idle timeout
 	EvSuffixForHTTPIdleTimeout = "HTTP_IDLE_TIMEOUT"
 	// EvSuffixForHTTPReadTimeout environment variable name for HTTP read timeout
 	EvSuffixForHTTPReadTimeout = "HTTP_READ_TIMEOUT"
 	// EvSuffixForHTTPWriteTimeout environment variable name for http write timeout
 	EvSuffixForHTTPWriteTimeout = "HTTP_WRITE_TIMEOUT"
+	// EvSuffixForShutdownHookTimeout environment variable name for default timeout (in seconds) of each lifecycle hook
+	EvSuffixForShutdownHookTimeout = "SHUTDOWN_HOOK_TIMEOUT"
 	// EvSuffixForJwtSecret environment variable name for JWT secrete
 	EvSuffixForJwtSecret = "JWT_SECRET"
 	// EvSuffixForLogLevel environment variable name for log level
@@ -38,6 +57,68 @@ const (
 	EvSuffixForGormSlowThreshold = "GORM_SLOW_THRESHOLD"
 	// EvSuffixForEnableHealthLog environment variable name for log level
 	EvSuffixForEnableHealthLog = "ENABLE_HEALTH_LOG"
+	// EvSuffixForHealthCheckTimeout environment variable name for timeout (in seconds) of each health check
+	EvSuffixForHealthCheckTimeout = "HEALTH_CHECK_TIMEOUT"
+	// EvSuffixForOutboxPollInterval environment variable name for interval (in milliseconds) at which the outbox relay polls for events
+	EvSuffixForOutboxPollInterval = "OUTBOX_POLL_INTERVAL"
+	// EvSuffixForOutboxBatchSize environment variable name for number of outbox events published per poll
+	EvSuffixForOutboxBatchSize = "OUTBOX_BATCH_SIZE"
+	// EvSuffixForOutboxMaxAttempts environment variable name for number of attempts to publish an outbox event, after which the event is dead till it is replayed
+	EvSuffixForOutboxMaxAttempts = "OUTBOX_MAX_ATTEMPTS"
+	// EvSuffixForOutboxRetention environment variable name for retention (in hours) of the published outbox events
+	EvSuffixForOutboxRetention = "OUTBOX_RETENTION"
+	// EvSuffixForPurgeInterval environment variable name for interval (in hours) at which the soft deleted rows are purged
+	EvSuffixForPurgeInterval = "PURGE_INTERVAL"
+	// EvSuffixForPurgeBatchSize environment variable name for number of soft deleted rows removed per statement
+	EvSuffixForPurgeBatchSize = "PURGE_BATCH_SIZE"
+	// EvSuffixForPurgeRetention environment variable name for default retention (in days) of the soft deleted rows
+	EvSuffixForPurgeRetention = "PURGE_RETENTION"
+	// EvSuffixForProcessedMessageRetention environment variable name for retention (in hours) of the ids of the processed messages used for deduplication
+	EvSuffixForProcessedMessageRetention = "PROCESSED_MESSAGE_RETENTION"
+	// EvSuffixForProcessedMessagePurgeInterval environment variable name for interval (in minutes) at which the processed messages older than the retention are purged
+	EvSuffixForProcessedMessagePurgeInterval = "PROCESSED_MESSAGE_PURGE_INTERVAL"
+	// EvSuffixForEventRouterWorkers environment variable name for number of workers handling the events routed by event router
+	EvSuffixForEventRouterWorkers = "EVENT_ROUTER_WORKERS"
+	// EvSuffixForEventBroker environment variable name for event broker, rabbitmq or inmemory
+	EvSuffixForEventBroker = "EVENT_BROKER"
+	// EvSuffixForCloudEventsMode environment variable name for CloudEvents encoding of the dispatched events, binary or structured
+	EvSuffixForCloudEventsMode = "CLOUDEVENTS_MODE"
+	// EvSuffixForQueueHost environment variable name for comma separated list of RabbitMQ hosts, each host can include the port
+	EvSuffixForQueueHost = "QUEUE_HOST"
+	// EvSuffixForQueuePort environment variable name for RabbitMQ port used for the hosts without port
+	EvSuffixForQueuePort = "QUEUE_PORT"
+	// EvSuffixForQueueUser environment variable name for RabbitMQ user
+	EvSuffixForQueueUser = "QUEUE_USER"
+	// EvSuffixForQueuePassword environment variable name for RabbitMQ password
+	EvSuffixForQueuePassword = "QUEUE_PWD"
+	// EvSuffixForQueueVirtualHost environment variable name for RabbitMQ virtual host
+	EvSuffixForQueueVirtualHost = "QUEUE_VHOST"
+	// EvSuffixForQueueExchange environment variable name for exchange to which the events are published
+	EvSuffixForQueueExchange = "QUEUE_EXCHANGE"
+	// EvSuffixForQueueExchangeType environment variable name for type of the exchange to which the events are published
+	EvSuffixForQueueExchangeType = "QUEUE_EXCHANGE_TYPE"
+	// EvSuffixForQueueHeartbeat environment variable name for RabbitMQ heartbeat interval in seconds
+	EvSuffixForQueueHeartbeat = "QUEUE_HEARTBEAT"
+	// EvSuffixForQueueTLSEnabled environment variable name for enabling tls for RabbitMQ connection
+	EvSuffixForQueueTLSEnabled = "QUEUE_TLS_ENABLED"
+	// EvSuffixForQueueCACert environment variable name for PEM encoded RabbitMQ CA certificate
+	EvSuffixForQueueCACert = "QUEUE_RMQ_CA_CERT"
+	// EvSuffixForQueueClientCert environment variable name for PEM encoded RabbitMQ client certificate
+	EvSuffixForQueueClientCert = "QUEUE_RMQ_CERT"
+	// EvSuffixForQueueClientKey environment variable name for PEM encoded RabbitMQ client private key
+	EvSuffixForQueueClientKey = "QUEUE_RMQ_CERT_KEY"
+	// EvSuffixForQueueCACertFile environment variable name for path of RabbitMQ CA certificate
+	EvSuffixForQueueCACertFile = "QUEUE_RMQ_CA_CERT_FILE"
+	// EvSuffixForQueueClientCertFile environment variable name for path of RabbitMQ client certificate
+	EvSuffixForQueueClientCertFile = "QUEUE_RMQ_CERT_FILE"
+	// EvSuffixForQueueClientKeyFile environment variable name for path of RabbitMQ client private key
+	EvSuffixForQueueClientKeyFile = "QUEUE_RMQ_CERT_KEY_FILE"
+	// EvSuffixForQueueReconnectInitialDelay environment variable name for delay (in milliseconds) before reconnecting to RabbitMQ, doubled on every failure
+	EvSuffixForQueueReconnectInitialDelay = "QUEUE_RECONNECT_INITIAL_DELAY"
+	// EvSuffixForQueueReconnectMaxDelay environment variable name for maximum delay (in milliseconds) before reconnecting to RabbitMQ
+	EvSuffixForQueueReconnectMaxDelay = "QUEUE_RECONNECT_MAX_DELAY"
+	// EvSuffixForQueueSpoolPath environment variable name for file to which the events which could not be published are written along with their bearer tokens
+	EvSuffixForQueueSpoolPath = "QUEUE_SPOOL_PATH"
 	// EvSuffixForEnableMetrics environment variable name for enable metrics
 	EvSuffixForEnableMetrics = "ENABLE_METRICS"
 	// EvSuffixForGormMetricsRefresh environment variable name for gorm metrics refresh interval
@@ -135,6 +135,13 @@ func (context *executionContextImpl) LogError(err error, errorMessage string) {
 	case microappError.HTTPResourceNotFound:
 		resourceNotFoundErr := err.(microappError.HTTPResourceNotFound)
 		context.Logger(log.EventTypeUnexpectedErr, resourceNotFoundErr.ErrorKey).Debug().Err(err).Str("resourceName", resourceNotFoundErr.ResourceName).Str("resourceValue", resourceNotFoundErr.ResourceValue).Msg(errorMessage)
+	case microappError.CanceledError:
+		context.Logger(log.EventTypeUnexpectedErr, microappError.ErrorCodeCanceled).Debug().Err(err).Msg(errorMessage)
+	case microappError.ConflictError:
+		conflictErr := err.(microappError.ConflictError)
+		context.Logger(log.EventTypeUnexpectedErr, conflictErr.ErrorKey).Info().Err(err).Str("resourceName", conflictErr.ResourceName).Str("resourceValue", conflictErr.ResourceValue).Msg(errorMessage)
+	case microappError.TimeoutError:
+		context.Logger(log.EventTypeUnexpectedErr, microappError.ErrorCodeTimeout).Warn().Err(err).Msg(errorMessage)
 	case microappError.APIClientError:
 		apiCallError := err.(microappError.APIClientError)
 		tmpLoggerEvent := context.Logger(log.EventTypeUnexpectedErr, apiCallError.GetErrorCode()).Error().Err(err).Str("stack", apiCallError.GetStackTrace()).Str("apiURL", apiCallError.GetAPIURL())
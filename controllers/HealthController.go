@@ -4,22 +4,64 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/islax/microapp/health"
+	"github.com/islax/microapp/web"
 )
 
 // HealthController provides method to check health and readiness
 type HealthController struct {
+	registry *health.Registry
 }
 
-// NewHealthController returns a new instance of HealthController
+// NewHealthController returns a new instance of HealthController which reports health using the checkers of the default registry, i.e. the registry of the App
 func NewHealthController() *HealthController {
 	return &HealthController{}
 }
 
+// NewHealthControllerWithRegistry returns a new instance of HealthController which reports health using the checkers of the given registry
+func NewHealthControllerWithRegistry(registry *health.Registry) *HealthController {
+	return &HealthController{registry: registry}
+}
+
 // RegisterRoutes implements interface RouteSpecifier
 func (controller *HealthController) RegisterRoutes(router *mux.Router) {
 	healthRouter := router.PathPrefix("/health").Subrouter()
-	healthRouter.HandleFunc("", controller.healthCheck).Methods("GET")
+	healthRouter.HandleFunc("", controller.liveness).Methods("GET")
+	healthRouter.HandleFunc("/live", controller.liveness).Methods("GET")
+	healthRouter.HandleFunc("/ready", controller.readiness).Methods("GET")
+}
+
+// healthRegistry returns the registry of the controller, the default registry is resolved on each request as the App may set it after the controller is created
+func (controller *HealthController) healthRegistry() *health.Registry {
+	if controller.registry != nil {
+		return controller.registry
+	}
+	return health.DefaultRegistry()
+}
+
+func (controller *HealthController) liveness(w http.ResponseWriter, r *http.Request) {
+	registry := controller.healthRegistry()
+	if registry == nil {
+		respondHealthReport(w, &health.Report{Status: health.StatusUp, Components: map[string]*health.ComponentReport{}})
+		return
+	}
+	respondHealthReport(w, registry.CheckLiveness(r.Context()))
+}
+
+// readiness reports DOWN if there is no registry, as the dependencies of the application can not be checked
+func (controller *HealthController) readiness(w http.ResponseWriter, r *http.Request) {
+	registry := controller.healthRegistry()
+	if registry == nil {
+		respondHealthReport(w, &health.Report{Status: health.StatusDown, Components: map[string]*health.ComponentReport{}})
+		return
+	}
+	respondHealthReport(w, registry.CheckReadiness(r.Context()))
 }
 
-func (controller *HealthController) healthCheck(w http.ResponseWriter, r *http.Request) {
+func respondHealthReport(w http.ResponseWriter, report *health.Report) {
+	if report.IsUp() {
+		web.RespondJSON(w, http.StatusOK, report)
+		return
+	}
+	web.RespondJSON(w, http.StatusServiceUnavailable, report)
 }
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/islax/microapp/health"
+)
+
+func TestHealthControllerReadiness(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	var databaseErr error
+	registry.RegisterReadinessChecker(health.NewCheckerFunc("database", func(ctx context.Context) error { return databaseErr }))
+	router := mux.NewRouter()
+	NewHealthControllerWithRegistry(registry).RegisterRoutes(router)
+
+	tests := []struct {
+		name       string
+		path       string
+		err        error
+		wantCode   int
+		wantStatus string
+	}{
+		{"ReadyUp", "/health/ready", nil, http.StatusOK, health.StatusUp},
+		{"ReadyDown", "/health/ready", errors.New("connection refused"), http.StatusServiceUnavailable, health.StatusDown},
+		{"LiveIgnoresReadinessCheckers", "/health/live", errors.New("connection refused"), http.StatusOK, health.StatusUp},
+		{"Legacy", "/health", errors.New("connection refused"), http.StatusOK, health.StatusUp},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			databaseErr = test.err
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+
+			var report health.Report
+			if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if rr.Code != test.wantCode || report.Status != test.wantStatus {
+				t.Errorf("Expected [%v %v], Actual [%v %v]", test.wantCode, test.wantStatus, rr.Code, report.Status)
+			}
+		})
+	}
+}
+
+func TestHealthControllerDefaultRegistry(t *testing.T) {
+	defer health.SetDefaultRegistry(nil)
+	router := mux.NewRouter()
+	NewHealthController().RegisterRoutes(router)
+
+	health.SetDefaultRegistry(nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/health/ready", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected readiness to fail without registry, Actual [%v]", rr.Code)
+	}
+
+	health.SetDefaultRegistry(health.NewRegistry(time.Second))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/health/ready", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected readiness to use the default registry set after the controller is created, Actual [%v]", rr.Code)
+	}
+}
@@ -0,0 +1,38 @@
+package error
+
+// NewCanceledError creates a new error for the operation cancelled along with its context, e.g. the client disconnected
+func NewCanceledError(err error) CanceledError {
+	return CanceledError{ErrorKey: ErrorCodeCanceled, cause: err}
+}
+
+// CanceledError represents HTTP 499 error, returned when a statement is cancelled along with the context of the request as the client disconnected.
+// It is not a failure of the service, so it is not logged as an error. It implements DatabaseError so that the repository can return it for the cancelled statements.
+type CanceledError struct {
+	ErrorKey string `json:"errorKey"`
+	cause    error
+}
+
+// Error returns the error string
+func (e CanceledError) Error() string {
+	return e.ErrorKey
+}
+
+// GetErrorCode returns the error code
+func (e CanceledError) GetErrorCode() string {
+	return e.ErrorKey
+}
+
+// GetStackTrace returns empty stack trace as the cancellation is not unexpected
+func (e CanceledError) GetStackTrace() string {
+	return ""
+}
+
+// GetCause returns the underlying error, context.Canceled
+func (e CanceledError) GetCause() error {
+	return e.cause
+}
+
+// IsRecordNotFoundError returns false
+func (e CanceledError) IsRecordNotFoundError() bool {
+	return false
+}
@@ -0,0 +1,39 @@
+package error
+
+// NewConflictError creates a new conflict error for the resource which was modified concurrently
+func NewConflictError(resourceName, resourceValue string) ConflictError {
+	return ConflictError{ErrorCodeConflict, resourceName, resourceValue}
+}
+
+// ConflictError represents HTTP 409 error, returned when the resource was modified after it was read.
+// It implements DatabaseError so that the repository can return it for the failed optimistic locking.
+type ConflictError struct {
+	ErrorKey      string `json:"errorKey"`
+	ResourceName  string `json:"resourceName"`
+	ResourceValue string `json:"resourceValue"`
+}
+
+// Error returns the error string
+func (e ConflictError) Error() string {
+	return e.ErrorKey
+}
+
+// GetErrorCode returns the error code
+func (e ConflictError) GetErrorCode() string {
+	return e.ErrorKey
+}
+
+// GetStackTrace returns empty stack trace as the conflict is not unexpected
+func (e ConflictError) GetStackTrace() string {
+	return ""
+}
+
+// GetCause returns nil as the conflict does not have an underlying error
+func (e ConflictError) GetCause() error {
+	return nil
+}
+
+// IsRecordNotFoundError returns false
+func (e ConflictError) IsRecordNotFoundError() bool {
+	return false
+}
@@ -1,13 +1,21 @@
 package error
 
 import (
+	"context"
 	"errors"
 
 	"gorm.io/gorm"
 )
 
-// NewDatabaseError creates a new database error
+// NewDatabaseError creates a new database error, TimeoutError if the statement was cancelled as its deadline exceeded
+// and CanceledError if it was cancelled along with its context, e.g. the client disconnected
 func NewDatabaseError(err error) DatabaseError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewTimeoutError(err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return NewCanceledError(err)
+	}
 	return &databaseErrorImpl{createUnexpectedErrorImpl(ErrorCodeDatabaseFailure, err)}
 }
 
@@ -0,0 +1,38 @@
+package error
+
+// NewTimeoutError creates a new timeout error for the operation cancelled as its deadline exceeded
+func NewTimeoutError(err error) TimeoutError {
+	return TimeoutError{ErrorKey: ErrorCodeTimeout, cause: err}
+}
+
+// TimeoutError represents HTTP 504 error, returned when a statement or a request is cancelled as its deadline exceeded.
+// It implements DatabaseError so that the repository can return it for the cancelled statements.
+type TimeoutError struct {
+	ErrorKey string `json:"errorKey"`
+	cause    error
+}
+
+// Error returns the error string
+func (e TimeoutError) Error() string {
+	return e.ErrorKey
+}
+
+// GetErrorCode returns the error code
+func (e TimeoutError) GetErrorCode() string {
+	return e.ErrorKey
+}
+
+// GetStackTrace returns empty stack trace as the timeout is not unexpected
+func (e TimeoutError) GetStackTrace() string {
+	return ""
+}
+
+// GetCause returns the underlying error, context.DeadlineExceeded
+func (e TimeoutError) GetCause() error {
+	return e.cause
+}
+
+// IsRecordNotFoundError returns false
+func (e TimeoutError) IsRecordNotFoundError() bool {
+	return false
+}
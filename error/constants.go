@@ -4,6 +4,8 @@ package error
 const (
 	// ErrorCodeAPICallFailure error code for API call failure
 	ErrorCodeAPICallFailure = "Key_APICallFailure"
+	// ErrorCodeConflict error code for resource modified concurrently
+	ErrorCodeConflict = "Key_Conflict"
 	// ErrorCodeCryptoFailure error code for encrypt / decrypt / hashing failure
 	ErrorCodeCryptoFailure = "Key_CryptoFailure"
 	// ErrorCodeDatabaseFailure error code for database falure
@@ -38,4 +40,8 @@ const (
 	ErrorCodeRequired = "Key_Required"
 	// ErrorCodeStringExpected error code for string type
 	ErrorCodeStringExpected = "Key_StringExpected"
+	// ErrorCodeTimeout error code for operation cancelled as its deadline exceeded
+	ErrorCodeTimeout = "Key_Timeout"
+	// ErrorCodeCanceled error code for operation cancelled along with its context, e.g. the client disconnected
+	ErrorCodeCanceled = "Key_Canceled"
 )
@@ -0,0 +1,164 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/islax/microapp/event/schema"
+)
+
+const (
+	// CloudEventsModeBinary publishes the CloudEvents attributes as message headers and the payload as message body
+	CloudEventsModeBinary = "binary"
+	// CloudEventsModeStructured publishes the CloudEvents attributes and the payload as a JSON envelope in the message body
+	CloudEventsModeStructured = "structured"
+
+	// HeaderEventVersion header carrying the schema version of the event payload
+	HeaderEventVersion = "X-Event-Version"
+
+	cloudEventsSpecVersion  = "1.0"
+	cloudEventsContentType  = "application/cloudevents+json"
+	cloudEventsHeaderPrefix = "cloudEvents:"
+)
+
+// CloudEvent represents the CloudEvents 1.0 attributes of an event
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            *time.Time      `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Subject         string          `json:"subject,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// eventEncoder validates the payload against the registered schema and encodes the event as per the CloudEvents mode
+type eventEncoder struct {
+	cloudEventsMode   string
+	cloudEventsSource string
+	schemaRegistry    *schema.Registry
+}
+
+// UseCloudEvents enables the CloudEvents encoding in binary or structured mode, source identifies the service publishing the events
+func (encoder *eventEncoder) UseCloudEvents(mode string, source string) error {
+	if mode != "" && mode != CloudEventsModeBinary && mode != CloudEventsModeStructured {
+		return fmt.Errorf("invalid CloudEvents mode [%v]", mode)
+	}
+	encoder.cloudEventsMode = mode
+	encoder.cloudEventsSource = source
+	return nil
+}
+
+// UseSchemaRegistry sets the registry whose schemas are used to validate the payloads before publishing
+func (encoder *eventEncoder) UseSchemaRegistry(registry *schema.Registry) {
+	encoder.schemaRegistry = registry
+}
+
+func (encoder *eventEncoder) validate(topic string, body []byte, options *DispatchOptions) error {
+	if encoder.schemaRegistry == nil {
+		return nil
+	}
+	return encoder.schemaRegistry.Validate(topic, options.Version, body)
+}
+
+// encode returns the message body, content type and headers of the event
+func (encoder *eventEncoder) encode(routingKey string, body []byte, options *DispatchOptions) ([]byte, string, map[string]interface{}, error) {
+	headers := options.headers()
+	if encoder.cloudEventsMode == "" {
+		return body, options.ContentType, headers, nil
+	}
+
+	cloudEvent := &CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              options.MessageID,
+		Source:          encoder.cloudEventsSource,
+		Type:            routingKey,
+		Time:            &options.Timestamp,
+		DataContentType: options.ContentType,
+		Subject:         options.Subject,
+	}
+	if options.Version != "" {
+		cloudEvent.DataSchema = fmt.Sprintf("urn:schema:%v:%v", routingKey, options.Version)
+	}
+
+	if encoder.cloudEventsMode == CloudEventsModeBinary {
+		for attribute, value := range cloudEvent.attributes() {
+			headers[cloudEventsHeaderPrefix+attribute] = value
+		}
+		return body, options.ContentType, headers, nil
+	}
+
+	if strings.HasSuffix(options.ContentType, "json") && json.Valid(body) {
+		cloudEvent.Data = body
+	} else {
+		cloudEvent.DataBase64 = body
+	}
+	envelope, err := json.Marshal(cloudEvent)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return envelope, cloudEventsContentType, headers, nil
+}
+
+func (cloudEvent *CloudEvent) attributes() map[string]interface{} {
+	attributes := map[string]interface{}{
+		"specversion": cloudEvent.SpecVersion,
+		"id":          cloudEvent.ID,
+		"source":      cloudEvent.Source,
+		"type":        cloudEvent.Type,
+	}
+	if cloudEvent.Time != nil {
+		attributes["time"] = cloudEvent.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if cloudEvent.DataSchema != "" {
+		attributes["dataschema"] = cloudEvent.DataSchema
+	}
+	if cloudEvent.Subject != "" {
+		attributes["subject"] = cloudEvent.Subject
+	}
+	return attributes
+}
+
+// DecodeCloudEvent decodes the CloudEvents attributes and returns them along with the payload.
+// If the message is not a CloudEvent, nil is returned along with the body as the payload.
+func DecodeCloudEvent(contentType string, headers map[string]interface{}, body []byte) (*CloudEvent, []byte, error) {
+	if strings.HasPrefix(contentType, cloudEventsContentType) {
+		var cloudEvent CloudEvent
+		if err := json.Unmarshal(body, &cloudEvent); err != nil {
+			return nil, body, err
+		}
+		if cloudEvent.SpecVersion == "" {
+			return nil, body, errors.New("specversion is missing in the CloudEvent")
+		}
+		data := []byte(cloudEvent.Data)
+		if len(cloudEvent.DataBase64) > 0 {
+			data = cloudEvent.DataBase64
+		}
+		cloudEvent.Data = nil
+		cloudEvent.DataBase64 = nil
+		return &cloudEvent, data, nil
+	}
+
+	specVersion, ok := headers[cloudEventsHeaderPrefix+"specversion"].(string)
+	if !ok {
+		return nil, body, nil
+	}
+	cloudEvent := &CloudEvent{SpecVersion: specVersion, DataContentType: contentType}
+	cloudEvent.ID, _ = headers[cloudEventsHeaderPrefix+"id"].(string)
+	cloudEvent.Source, _ = headers[cloudEventsHeaderPrefix+"source"].(string)
+	cloudEvent.Type, _ = headers[cloudEventsHeaderPrefix+"type"].(string)
+	cloudEvent.DataSchema, _ = headers[cloudEventsHeaderPrefix+"dataschema"].(string)
+	cloudEvent.Subject, _ = headers[cloudEventsHeaderPrefix+"subject"].(string)
+	if eventTime, ok := headers[cloudEventsHeaderPrefix+"time"].(string); ok {
+		if parsedTime, err := time.Parse(time.RFC3339Nano, eventTime); err == nil {
+			cloudEvent.Time = &parsedTime
+		}
+	}
+	return cloudEvent, body, nil
+}
@@ -1,6 +1,87 @@
 package event
 
+import (
+	"context"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// HeaderAuthorization header carrying the raw token of the user who raised the event
+	HeaderAuthorization = "X-Authorization"
+	// HeaderCorrelationID header carrying the correlation id of the request which raised the event
+	HeaderCorrelationID = "X-Correlation-ID"
+	// HeaderTenantID header carrying the id of the tenant for which the event is raised
+	HeaderTenantID = "X-Tenant-ID"
+)
+
 // Dispatcher interface must be implemented by Queue
 type Dispatcher interface {
 	DispatchEvent(token string, corelationID string, topic string, payload interface{})
 }
+
+// OptionsDispatcher should be implemented by the dispatchers which support the dispatch options
+type OptionsDispatcher interface {
+	DispatchEventWithOptions(topic string, payload interface{}, options *DispatchOptions)
+}
+
+// ConfirmingDispatcher should be implemented by the dispatchers which can report whether the broker has accepted the event
+type ConfirmingDispatcher interface {
+	// DispatchEventAndWait returns once the broker has accepted the event, an error is returned if the event could not be published or the context is done
+	DispatchEventAndWait(ctx context.Context, topic string, payload interface{}, options *DispatchOptions) error
+}
+
+// Stopper should be implemented by the dispatchers which need to flush pending events before the application exits
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// DispatchOptions represents the metadata published along with the event
+type DispatchOptions struct {
+	Token         string                 `json:"token,omitempty"`
+	CorrelationID string                 `json:"correlationId,omitempty"`
+	TenantID      string                 `json:"tenantId,omitempty"`
+	MessageID     string                 `json:"messageId,omitempty"`   // Generated if empty
+	Timestamp     time.Time              `json:"timestamp"`             // Current time if zero
+	ContentType   string                 `json:"contentType,omitempty"` // application/json if empty
+	Priority      uint8                  `json:"priority,omitempty"`    // 0 to 9, honoured only by priority queues
+	Version       string                 `json:"version,omitempty"`     // Schema version of the payload
+	Subject       string                 `json:"subject,omitempty"`     // Subject of the event, used by CloudEvents encoding
+	Headers       map[string]interface{} `json:"headers,omitempty"`     // Custom headers e.g. event version, trace context
+}
+
+// withDefaults returns a copy of the options with message id, timestamp and content type populated
+func (options *DispatchOptions) withDefaults() *DispatchOptions {
+	optionsWithDefaults := DispatchOptions{}
+	if options != nil {
+		optionsWithDefaults = *options
+	}
+	if optionsWithDefaults.MessageID == "" {
+		optionsWithDefaults.MessageID = uuid.NewV4().String()
+	}
+	if optionsWithDefaults.Timestamp.IsZero() {
+		optionsWithDefaults.Timestamp = time.Now().UTC()
+	}
+	if optionsWithDefaults.ContentType == "" {
+		optionsWithDefaults.ContentType = "application/json"
+	}
+	return &optionsWithDefaults
+}
+
+// headers returns the custom headers along with the authorization, correlation id and tenant id headers
+func (options *DispatchOptions) headers() map[string]interface{} {
+	headers := make(map[string]interface{}, len(options.Headers)+4)
+	for key, value := range options.Headers {
+		headers[key] = value
+	}
+	headers[HeaderAuthorization] = options.Token
+	headers[HeaderCorrelationID] = options.CorrelationID
+	if options.TenantID != "" {
+		headers[HeaderTenantID] = options.TenantID
+	}
+	if options.Version != "" {
+		headers[HeaderEventVersion] = options.Version
+	}
+	return headers
+}
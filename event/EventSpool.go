@@ -0,0 +1,93 @@
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// spooledEvent represents an event written to the spool, Options include the bearer token of the event
+type spooledEvent struct {
+	Topic   string           `json:"topic"`
+	Body    []byte           `json:"body"`
+	Options *DispatchOptions `json:"options"`
+}
+
+// eventSpool is an append-only file of the events which could not be published, the file is readable only by the owner as it holds the tokens of the events.
+// On replay the spool is renamed, so that the events which fail again are appended to a new spool.
+type eventSpool struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func newEventSpool(path string) *eventSpool {
+	return &eventSpool{path: path}
+}
+
+func (spool *eventSpool) append(event *spooledEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+
+	file, err := os.OpenFile(spool.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// replay invokes the callback for each spooled event and removes the spooled events once the callback succeeds for all of them.
+// If the callback fails the replay is interrupted, events left over by an interrupted replay are replayed first.
+// The spool must not be replayed concurrently.
+func (spool *eventSpool) replay(callback func(event *spooledEvent) error) error {
+	replayPath := spool.path + ".replay"
+
+	spool.mutex.Lock()
+	if _, err := os.Stat(replayPath); os.IsNotExist(err) {
+		if err = os.Rename(spool.path, replayPath); err != nil {
+			spool.mutex.Unlock()
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+	}
+	spool.mutex.Unlock()
+
+	file, err := os.Open(replayPath)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event spooledEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Skip the partially written line, e.g. if the process crashed while appending
+			continue
+		}
+		if err := callback(&event); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	file.Close()
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return os.Remove(replayPath)
+}
@@ -0,0 +1,49 @@
+package event
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEventSpoolReplay(t *testing.T) {
+	spool := newEventSpool(filepath.Join(t.TempDir(), "events.spool"))
+	for _, topic := range []string{"user.created", "user.deleted"} {
+		if err := spool.append(&spooledEvent{Topic: topic, Body: []byte("{}")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayed := []string{}
+	err := spool.replay(func(event *spooledEvent) error {
+		replayed = append(replayed, event.Topic)
+		return errors.New("not confirmed")
+	})
+	if err == nil || len(replayed) != 1 {
+		t.Fatalf("Expected the replay to be interrupted by the callback, Actual error [%v] replayed %v", err, replayed)
+	}
+	if _, err = os.Stat(spool.path + ".replay"); err != nil {
+		t.Fatalf("Expected the replay file to be kept after an interrupted replay, Actual [%v]", err)
+	}
+
+	if err = spool.append(&spooledEvent{Topic: "user.updated", Body: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	replayed = []string{}
+	if err = spool.replay(func(event *spooledEvent) error {
+		replayed = append(replayed, event.Topic)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 2 || replayed[0] != "user.created" || replayed[1] != "user.deleted" {
+		t.Errorf("Expected the interrupted replay to be replayed first, Actual %v", replayed)
+	}
+	if _, err = os.Stat(spool.path + ".replay"); !os.IsNotExist(err) {
+		t.Errorf("Expected the replay file to be removed, Actual [%v]", err)
+	}
+	if _, err = os.Stat(spool.path); err != nil {
+		t.Errorf("Expected the event spooled during the replay to be kept, Actual [%v]", err)
+	}
+}
@@ -0,0 +1,185 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/islax/microapp/event/schema"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// BrokerRabbitMQ selects RabbitMQ as the event broker
+	BrokerRabbitMQ = "rabbitmq"
+	// BrokerInMemory selects the in-process event broker, events are delivered only to the event monitors of the same process
+	BrokerInMemory = "inmemory"
+)
+
+// InMemoryMessage represents an event delivered by the in-memory broker
+type InMemoryMessage struct {
+	RoutingKey  string
+	Body        []byte
+	MessageID   string
+	Timestamp   time.Time
+	ContentType string
+	Priority    uint8
+	Headers     map[string]interface{} // Custom headers along with the authorization, correlation id and tenant id headers
+}
+
+type inMemorySubscription struct {
+	patterns []string
+	handler  func(message *InMemoryMessage)
+	mutex    sync.Mutex
+	queue    []*InMemoryMessage
+	signal   chan struct{}
+	closed   bool
+}
+
+// InMemoryBroker is an in-process event broker with the routing semantics of the RabbitMQ topic exchange.
+// It implements Dispatcher, so that a service can publish and consume its own events without RabbitMQ.
+type InMemoryBroker struct {
+	eventEncoder
+	logger        *zerolog.Logger
+	mutex         sync.RWMutex
+	subscriptions map[*inMemorySubscription]struct{}
+}
+
+var (
+	defaultInMemoryBroker     *InMemoryBroker
+	defaultInMemoryBrokerOnce sync.Once
+)
+
+// NewInMemoryBroker creates a new in-memory broker
+func NewInMemoryBroker(logger *zerolog.Logger) *InMemoryBroker {
+	ctxLogger := logger.With().Str("module", "InMemoryBroker").Logger()
+	broker := &InMemoryBroker{logger: &ctxLogger, subscriptions: make(map[*inMemorySubscription]struct{})}
+	broker.UseSchemaRegistry(schema.DefaultRegistry())
+	return broker
+}
+
+// DefaultInMemoryBroker returns the in-memory broker shared by the dispatcher and the event monitors of the process
+func DefaultInMemoryBroker() *InMemoryBroker {
+	defaultInMemoryBrokerOnce.Do(func() {
+		logger := zerolog.Nop()
+		defaultInMemoryBroker = NewInMemoryBroker(&logger)
+	})
+	return defaultInMemoryBroker
+}
+
+// DispatchEvent delivers the event to the subscriptions whose patterns match the topic
+func (broker *InMemoryBroker) DispatchEvent(token string, corelationID string, topic string, payload interface{}) {
+	broker.DispatchEventWithOptions(topic, payload, &DispatchOptions{Token: token, CorrelationID: corelationID})
+}
+
+// DispatchEventWithOptions delivers the event along with the metadata in the options to the subscriptions whose patterns match the topic
+func (broker *InMemoryBroker) DispatchEventWithOptions(topic string, payload interface{}, options *DispatchOptions) {
+	broker.dispatch(topic, payload, options)
+}
+
+// DispatchEventAndWait delivers the event to the subscriptions and returns an error if the event can not be encoded, the broker accepts the event once it is queued for the subscriptions
+func (broker *InMemoryBroker) DispatchEventAndWait(ctx context.Context, topic string, payload interface{}, options *DispatchOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return broker.dispatch(topic, payload, options)
+}
+
+func (broker *InMemoryBroker) dispatch(topic string, payload interface{}, options *DispatchOptions) error {
+	body, err := marshalPayload(payload)
+	if err != nil {
+		broker.logger.Error().Err(err).Str("topic", topic).Msg("Failed to convert payload to JSON")
+		return err
+	}
+	options = options.withDefaults()
+	if err = broker.validate(topic, body, options); err != nil {
+		broker.logger.Error().Err(err).Str("topic", topic).Msg("Event payload does not conform to the schema")
+		return err
+	}
+	routingKey := NormalizeTopic(topic)
+	body, contentType, headers, err := broker.encode(routingKey, body, options)
+	if err != nil {
+		broker.logger.Error().Err(err).Str("topic", topic).Msg("Failed to encode the event")
+		return err
+	}
+	message := &InMemoryMessage{
+		RoutingKey:  routingKey,
+		Body:        body,
+		MessageID:   options.MessageID,
+		Timestamp:   options.Timestamp,
+		ContentType: contentType,
+		Priority:    options.Priority,
+		Headers:     headers,
+	}
+
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+	for subscription := range broker.subscriptions {
+		for _, pattern := range subscription.patterns {
+			if MatchTopic(pattern, message.RoutingKey) {
+				subscription.enqueue(message)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe registers the handler for the events matching any of the patterns, the handler is invoked sequentially in the order the events are dispatched.
+// The returned function removes the subscription.
+func (broker *InMemoryBroker) Subscribe(patterns []string, handler func(message *InMemoryMessage)) func() {
+	subscription := &inMemorySubscription{patterns: patterns, handler: handler, signal: make(chan struct{}, 1)}
+	go subscription.deliver()
+
+	broker.mutex.Lock()
+	broker.subscriptions[subscription] = struct{}{}
+	broker.mutex.Unlock()
+
+	return func() {
+		broker.mutex.Lock()
+		delete(broker.subscriptions, subscription)
+		broker.mutex.Unlock()
+		subscription.close()
+	}
+}
+
+// IsConnected always returns true as the broker runs in process
+func (broker *InMemoryBroker) IsConnected() bool {
+	return true
+}
+
+// enqueue queues the message without blocking the dispatcher
+func (subscription *inMemorySubscription) enqueue(message *InMemoryMessage) {
+	subscription.mutex.Lock()
+	subscription.queue = append(subscription.queue, message)
+	subscription.mutex.Unlock()
+
+	select {
+	case subscription.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (subscription *inMemorySubscription) close() {
+	subscription.mutex.Lock()
+	subscription.closed = true
+	subscription.mutex.Unlock()
+	close(subscription.signal)
+}
+
+func (subscription *inMemorySubscription) deliver() {
+	for range subscription.signal {
+		for {
+			subscription.mutex.Lock()
+			if subscription.closed || len(subscription.queue) == 0 {
+				subscription.mutex.Unlock()
+				break
+			}
+			message := subscription.queue[0]
+			subscription.queue = subscription.queue[1:]
+			subscription.mutex.Unlock()
+
+			subscription.handler(message)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package event
+
+import "sync"
+
+// pendingEvents counts the events accepted for publishing.
+// Unlike sync.WaitGroup events can be added while waiting, events are rejected once the counter is closed.
+type pendingEvents struct {
+	mutex   sync.Mutex
+	count   int
+	closed  bool
+	drained chan struct{}
+}
+
+func newPendingEvents() *pendingEvents {
+	return &pendingEvents{drained: make(chan struct{})}
+}
+
+// add counts a new event, false is returned if the counter is closed
+func (pending *pendingEvents) add() bool {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	if pending.closed {
+		return false
+	}
+	pending.count++
+	return true
+}
+
+// done marks a counted event as completed
+func (pending *pendingEvents) done() {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	pending.count--
+	if pending.closed && pending.count == 0 {
+		close(pending.drained)
+	}
+}
+
+// close stops accepting new events and returns a channel which is closed once the counted events are completed
+func (pending *pendingEvents) close() <-chan struct{} {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	if !pending.closed {
+		pending.closed = true
+		if pending.count == 0 {
+			close(pending.drained)
+		}
+	}
+	return pending.drained
+}
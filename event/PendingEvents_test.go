@@ -0,0 +1,39 @@
+package event
+
+import "testing"
+
+func TestPendingEvents(t *testing.T) {
+	pending := newPendingEvents()
+	if !pending.add() || !pending.add() {
+		t.Fatal("Expected the events to be accepted before close")
+	}
+
+	drained := pending.close()
+	if pending.add() {
+		t.Error("Expected the events to be rejected after close")
+	}
+	pending.done()
+	select {
+	case <-drained:
+		t.Fatal("Expected close to wait for the pending event")
+	default:
+	}
+	pending.done()
+	select {
+	case <-drained:
+	default:
+		t.Fatal("Expected drained once the pending events are completed")
+	}
+
+	if drained = pending.close(); drained == nil {
+		t.Error("Expected close to be idempotent")
+	}
+}
+
+func TestPendingEventsCloseWithoutEvents(t *testing.T) {
+	select {
+	case <-newPendingEvents().close():
+	default:
+		t.Fatal("Expected drained when there are no pending events")
+	}
+}
@@ -1,71 +1,173 @@
 package event
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/islax/microapp/event/rabbitmq"
+	"github.com/islax/microapp/event/schema"
+	"github.com/islax/microapp/metrics"
 	"github.com/rs/zerolog"
 	"github.com/streadway/amqp"
 )
 
 type queueCommand struct {
-	token        string
-	topic        string
-	corelationID string
-	payload      interface{}
+	topic   string
+	payload interface{}
+	options *DispatchOptions
+	result  chan error // Receives the outcome of the publish instead of spooling the event, nil if nobody waits for the event
 }
 
+var (
+	errDispatcherStopped = errors.New("event dispatcher is stopped")
+	errEventDropped      = errors.New("event dropped")
+)
+
+const (
+	publishRetries      = 3
+	confirmationTimeout = 10 * time.Second
+	spoolReplayInterval = 5 * time.Second // Interval at which the events spooled while connected are replayed
+)
+
 type retryCommand struct {
 	retryCount int
 	command    *queueCommand
 }
 
+// confirmingChannel is a channel in publisher confirm mode along with the delivery tag of the last published event
+type confirmingChannel struct {
+	channel       *amqp.Channel
+	confirmations chan amqp.Confirmation
+	deliveryTag   uint64
+}
+
 // RabbitMQEventDispatcher is an event dispatcher that sends event to the RabbitMQ Exchange
 type RabbitMQEventDispatcher struct {
-	logger                 *zerolog.Logger
-	exchangeName           string
-	connection             *amqp.Connection
-	channel                *amqp.Channel
-	sendChannel            chan *queueCommand
-	retryChannel           chan *retryCommand
-	connectionCloseChannel chan *amqp.Error
-	connectionMutex        sync.Mutex
+	eventEncoder
+	logger            *zerolog.Logger
+	connectionManager *rabbitmq.ConnectionManager
+	publisher         *confirmingChannel
+	spool             *eventSpool
+	stats             *metrics.EventDispatcherStats
+	sendChannel       chan *queueCommand
+	retryChannel      chan *retryCommand
+	connectionMutex   sync.Mutex
+	pendingEvents     *pendingEvents
+	stopped           bool
+	connected         int32
+	replayRequests    int32
+	spooled           int32 // Set when an event is spooled, the events spooled while connected are replayed on the next tick
 }
 
-// NewRabbitMQEventDispatcher create and returns a new RabbitMQEventDispatcher
+// NewRabbitMQEventDispatcher create and returns a new RabbitMQEventDispatcher which uses the default connection manager.
+// Events are published in publisher confirm mode, events which can not be published are written to the spool file
+// ISLA_QUEUE_SPOOL_PATH (defaults to microapp-events.spool in the temp directory) and are replayed once the connection is re-established,
+// or periodically while connected, e.g. for the events spooled because the send channel was full.
+// The spool holds the dispatch options of the events, including the bearer token published as the authorization header,
+// it is created readable only by the owner; keep it on a volume not shared with other services.
 func NewRabbitMQEventDispatcher(logger *zerolog.Logger) (*RabbitMQEventDispatcher, error) {
-	sendChannel := make(chan *queueCommand, 200)
-	retryChannel := make(chan *retryCommand, 200)
-	connectionCloseChannel := make(chan *amqp.Error)
+	return NewRabbitMQEventDispatcherWithConnectionManager(logger, rabbitmq.DefaultConnectionManager())
+}
 
+// NewRabbitMQEventDispatcherWithConnectionManager create and returns a new RabbitMQEventDispatcher which publishes on a channel of the given connection manager
+func NewRabbitMQEventDispatcherWithConnectionManager(logger *zerolog.Logger, connectionManager *rabbitmq.ConnectionManager) (*RabbitMQEventDispatcher, error) {
+	if connectionManager == nil {
+		return nil, errors.New("connection manager is required")
+	}
 	ctxLogger := logger.With().Str("module", "RabbitMQEventDispatcher").Logger()
 
 	dispatcher := &RabbitMQEventDispatcher{
-		logger:                 &ctxLogger,
-		exchangeName:           "isla_exchange",
-		sendChannel:            sendChannel,
-		retryChannel:           retryChannel,
-		connectionCloseChannel: connectionCloseChannel,
+		logger:            &ctxLogger,
+		connectionManager: connectionManager,
+		sendChannel:       make(chan *queueCommand, 200),
+		retryChannel:      make(chan *retryCommand, 200),
+		spool:             newEventSpool(connectionManager.Config().SpoolPath),
+		stats:             metrics.GetEventDispatcherStats(),
+		pendingEvents:     newPendingEvents(),
 	}
+	dispatcher.UseSchemaRegistry(schema.DefaultRegistry())
 
 	go dispatcher.rabbitConnector()
 	go dispatcher.start()
 
-	connectionCloseChannel <- amqp.ErrClosed // Trigger the connection
-
 	return dispatcher, nil
 }
 
 // DispatchEvent dispatches events to the message queue
 func (eventDispatcher *RabbitMQEventDispatcher) DispatchEvent(token string, corelationID string, topic string, payload interface{}) {
-	eventDispatcher.sendChannel <- &queueCommand{token: token, topic: topic, payload: payload}
+	eventDispatcher.DispatchEventWithOptions(topic, payload, &DispatchOptions{Token: token, CorrelationID: corelationID})
+}
+
+// DispatchEventWithOptions dispatches events to the message queue along with the metadata in the options.
+// Events dispatched after the dispatcher is stopped are spooled.
+func (eventDispatcher *RabbitMQEventDispatcher) DispatchEventWithOptions(topic string, payload interface{}, options *DispatchOptions) {
+	command := &queueCommand{topic: topic, payload: payload, options: options.withDefaults()}
+	if !eventDispatcher.pendingEvents.add() {
+		eventDispatcher.logger.Warn().Str("topic", topic).Msg("Dispatcher is stopped, spooling the event.")
+		eventDispatcher.marshalAndSpoolEvent(command)
+		return
+	}
+	select {
+	case eventDispatcher.sendChannel <- command:
+	default:
+		eventDispatcher.logger.Warn().Str("topic", topic).Msg("Send channel is full, spooling the event.")
+		eventDispatcher.marshalAndSpoolEvent(command)
+		eventDispatcher.pendingEvents.done()
+	}
+}
+
+// DispatchEventAndWait dispatches the event and waits till the broker confirms it.
+// Unlike DispatchEventWithOptions the event is not spooled if it can not be published, the error is returned instead.
+func (eventDispatcher *RabbitMQEventDispatcher) DispatchEventAndWait(ctx context.Context, topic string, payload interface{}, options *DispatchOptions) error {
+	if !eventDispatcher.pendingEvents.add() {
+		return errDispatcherStopped
+	}
+	command := &queueCommand{topic: topic, payload: payload, options: options.withDefaults(), result: make(chan error, 1)}
+	select {
+	case eventDispatcher.sendChannel <- command:
+	case <-ctx.Done():
+		eventDispatcher.pendingEvents.done()
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-command.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop waits till the pending events are published or the context is done and then closes the channel, the connection is closed by the connection manager.
+// Events dispatched after Stop is called are spooled and published on the next start.
+func (eventDispatcher *RabbitMQEventDispatcher) Stop(ctx context.Context) error {
+	flushed := eventDispatcher.pendingEvents.close()
+
+	var err error
+	select {
+	case <-flushed:
+		eventDispatcher.logger.Debug().Msg("All pending events published")
+	case <-ctx.Done():
+		err = fmt.Errorf("unable to publish %v pending event(s): %w", len(eventDispatcher.sendChannel)+len(eventDispatcher.retryChannel), ctx.Err())
+	}
+
+	eventDispatcher.connectionMutex.Lock()
+	defer eventDispatcher.connectionMutex.Unlock()
+	eventDispatcher.stopped = true
+	if eventDispatcher.publisher != nil {
+		eventDispatcher.publisher.channel.Close()
+	}
+	return err
+}
+
+// IsConnected returns whether the dispatcher is connected to the RabbitMQ
+func (eventDispatcher *RabbitMQEventDispatcher) IsConnected() bool {
+	return atomic.LoadInt32(&eventDispatcher.connected) == 1
 }
 
 func (eventDispatcher *RabbitMQEventDispatcher) start() {
@@ -74,10 +176,6 @@ func (eventDispatcher *RabbitMQEventDispatcher) start() {
 		var command *queueCommand
 		var retryCount int
 
-		// Ensure that connection process is not going on
-		eventDispatcher.connectionMutex.Lock()
-		eventDispatcher.connectionMutex.Unlock()
-
 		select {
 		case commandFromSendChannel := <-eventDispatcher.sendChannel:
 			command = commandFromSendChannel
@@ -86,154 +184,247 @@ func (eventDispatcher *RabbitMQEventDispatcher) start() {
 			retryCount = commandFromRetryChannel.retryCount
 		}
 
-		routingKey := strings.ReplaceAll(command.topic, "_", ".")
-		var body []byte
-		var err error
-
-		body, isByteMessage := command.payload.([]byte)
-		if !isByteMessage {
-			body, err = json.Marshal(command.payload)
-			if err != nil {
-				eventDispatcher.logger.Error().Msg("Failed to convert payload to JSON" + ": " + err.Error())
-				//TODO: Can we log this message
-			}
-		}
-
+		body, err := marshalPayload(command.payload)
 		if err == nil {
-			err = eventDispatcher.channel.Publish(
-				eventDispatcher.exchangeName,
-				routingKey,
-				false,
-				false,
-				amqp.Publishing{
-					ContentType: "application/json",
-					Body:        body,
-					Headers:     map[string]interface{}{"X-Authorization": command.token, "X-Correlation-ID": command.corelationID},
-				})
+			err = eventDispatcher.validate(command.topic, body, command.options)
+		}
+		if err != nil {
+			eventDispatcher.drop(command, err)
+			eventDispatcher.finish(command, fmt.Errorf("%w: %v", errEventDropped, err))
+			continue
+		}
 
-			if err != nil {
-				if retryCount < 3 {
-					eventDispatcher.logger.Warn().Msg("Publish to queue failed. Trying again ... Error: " + err.Error())
-
-					go func(command *queueCommand, retryCount int) {
-						time.Sleep(time.Second)
-						eventDispatcher.retryChannel <- &retryCommand{retryCount: retryCount, command: command}
-					}(command, retryCount+1)
-				} else {
-					eventDispatcher.logger.Error().Msg("Failed to publish to an Exchange" + ": " + err.Error())
-					//TODO: Can we log this message
-				}
+		if err = eventDispatcher.publish(command, body); err != nil {
+			if retryCount < publishRetries {
+				eventDispatcher.logger.Warn().Msg("Publish to queue failed. Trying again ... Error: " + err.Error())
+
+				go func(command *queueCommand, retryCount int) {
+					time.Sleep(time.Second)
+					eventDispatcher.retryChannel <- &retryCommand{retryCount: retryCount, command: command}
+				}(command, retryCount+1)
+			} else if command.result != nil {
+				eventDispatcher.logger.Warn().Str("topic", command.topic).Msg("Failed to publish to an Exchange: " + err.Error())
+				eventDispatcher.finish(command, err)
 			} else {
-				eventDispatcher.logger.Trace().Msg("Sent message to queue")
+				eventDispatcher.logger.Error().Str("topic", command.topic).Msg("Failed to publish to an Exchange, spooling the event: " + err.Error())
+				eventDispatcher.spoolEvent(command, body)
+				eventDispatcher.pendingEvents.done()
 			}
+		} else {
+			eventDispatcher.logger.Trace().Msg("Sent message to queue")
+			eventDispatcher.stats.Confirmed.Inc()
+			eventDispatcher.finish(command, nil)
 		}
 	}
 }
 
-func (eventDispatcher *RabbitMQEventDispatcher) rabbitConnector() {
-	var rabbitErr *amqp.Error
+// finish reports the outcome to the caller waiting for the event and marks the event as no longer pending
+func (eventDispatcher *RabbitMQEventDispatcher) finish(command *queueCommand, err error) {
+	if command.result != nil {
+		command.result <- err
+	}
+	eventDispatcher.pendingEvents.done()
+}
+
+// publish publishes the event and waits for the broker to confirm it
+func (eventDispatcher *RabbitMQEventDispatcher) publish(command *queueCommand, body []byte) error {
+	eventDispatcher.connectionMutex.Lock()
+	publisher := eventDispatcher.publisher
+	eventDispatcher.connectionMutex.Unlock()
+	if publisher == nil {
+		return amqp.ErrClosed
+	}
+
+	routingKey := NormalizeTopic(command.topic)
+	body, contentType, headers, err := eventDispatcher.encode(routingKey, body, command.options)
+	if err != nil {
+		return err
+	}
 
+	err = publisher.channel.Publish(
+		eventDispatcher.connectionManager.Config().ExchangeName,
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:   contentType,
+			DeliveryMode:  amqp.Persistent,
+			Priority:      command.options.Priority,
+			CorrelationId: command.options.CorrelationID,
+			MessageId:     command.options.MessageID,
+			Timestamp:     command.options.Timestamp,
+			Body:          body,
+			Headers:       headers,
+		})
+	if err != nil {
+		return err
+	}
+	publisher.deliveryTag++
+
+	timeout := time.NewTimer(confirmationTimeout)
+	defer timeout.Stop()
 	for {
-		rabbitErr = <-eventDispatcher.connectionCloseChannel
-		if rabbitErr != nil {
-			eventDispatcher.connectionMutex.Lock()
+		select {
+		case confirmation, ok := <-publisher.confirmations:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			if confirmation.DeliveryTag < publisher.deliveryTag { // Late confirmation of an event which timed out earlier
+				continue
+			}
+			if !confirmation.Ack {
+				return errors.New("event rejected by the broker")
+			}
+			return nil
+		case <-timeout.C:
+			return errors.New("timed out waiting for the broker to confirm the event")
+		}
+	}
+}
 
-			connectionString, isTLS, connectionStringForLog := getQueueConnectionString()
-			connection, channel := connectToRabbitMQ(eventDispatcher.logger, connectionString, isTLS, eventDispatcher.exchangeName, connectionStringForLog)
+func (eventDispatcher *RabbitMQEventDispatcher) marshalAndSpoolEvent(command *queueCommand) {
+	body, err := marshalPayload(command.payload)
+	if err != nil {
+		eventDispatcher.drop(command, err)
+		return
+	}
+	eventDispatcher.spoolEvent(command, body)
+}
 
-			eventDispatcher.connection = connection
-			eventDispatcher.channel = channel
-			eventDispatcher.connectionCloseChannel = make(chan *amqp.Error)
+func (eventDispatcher *RabbitMQEventDispatcher) spoolEvent(command *queueCommand, body []byte) {
+	if err := eventDispatcher.spool.append(&spooledEvent{Topic: command.topic, Body: body, Options: command.options}); err != nil {
+		eventDispatcher.drop(command, err)
+		return
+	}
+	eventDispatcher.stats.Spooled.Inc()
+	atomic.StoreInt32(&eventDispatcher.spooled, 1)
+}
 
-			eventDispatcher.connection.NotifyClose(eventDispatcher.connectionCloseChannel)
+// drop records the event which could neither be published nor spooled, pending events must be marked done by the caller
+func (eventDispatcher *RabbitMQEventDispatcher) drop(command *queueCommand, err error) {
+	eventDispatcher.logger.Error().Err(err).Str("topic", command.topic).Str("correlationId", command.options.CorrelationID).Str("messageId", command.options.MessageID).Msg("Dropping the event.")
+	eventDispatcher.stats.Dropped.Inc()
+}
 
-			eventDispatcher.connectionMutex.Unlock()
-		}
+// replaySpoolIfSpooled replays the spool if any event was spooled since the last call
+func (eventDispatcher *RabbitMQEventDispatcher) replaySpoolIfSpooled() {
+	if atomic.CompareAndSwapInt32(&eventDispatcher.spooled, 1, 0) {
+		eventDispatcher.replaySpool()
 	}
 }
 
-func connectToRabbitMQ(logger *zerolog.Logger, connectionString string, isTLS bool, exchangeName string, connectionStringForLog string) (*amqp.Connection, *amqp.Channel) {
-	logger.Debug().Msg("Connecting to queue " + connectionStringForLog)
+// replaySpool replays the spooled events, a replay requested while another replay is running is run once the running replay completes
+func (eventDispatcher *RabbitMQEventDispatcher) replaySpool() {
+	if atomic.AddInt32(&eventDispatcher.replayRequests, 1) > 1 {
+		return
+	}
 	for {
+		eventDispatcher.replaySpoolOnce()
+		if atomic.CompareAndSwapInt32(&eventDispatcher.replayRequests, 1, 0) {
+			return
+		}
+		atomic.StoreInt32(&eventDispatcher.replayRequests, 1)
+	}
+}
 
-		conn, err := dialAMQP(connectionString, isTLS)
-		logger.Info().Msg(fmt.Sprintf("Connection String and TLS valus is %v     %v", connectionStringForLog, isTLS))
-
-		if err == nil {
-			logger.Info().Msg("RabittMQ connected")
+// replaySpoolOnce dispatches the spooled events again and waits for each of them to be confirmed, events which fail again are spooled again.
+// The replayed events are removed from the spool only after all of them are confirmed or spooled again,
+// if the dispatcher is stopped or the process crashes in between the events are replayed again on the next start.
+func (eventDispatcher *RabbitMQEventDispatcher) replaySpoolOnce() {
+	err := eventDispatcher.spool.replay(func(event *spooledEvent) error {
+		if !eventDispatcher.pendingEvents.add() {
+			return errDispatcherStopped
+		}
+		command := &queueCommand{topic: event.Topic, payload: event.Body, options: event.Options.withDefaults(), result: make(chan error, 1)}
+		eventDispatcher.stats.Replayed.Inc()
+		eventDispatcher.sendChannel <- command
 
-			ch, err := conn.Channel()
-			if err != nil {
-				logger.Warn().Msg("Failed to open a Channel" + ": " + err.Error())
-			} else {
-				err = ch.ExchangeDeclare(exchangeName, "topic", true, false, false, false, nil)
-				if err != nil {
-					logger.Warn().Msg("Failed to declare an exchange" + ": " + err.Error())
-				} else {
-					return conn, ch
-				}
+		if err := <-command.result; err != nil && !errors.Is(err, errEventDropped) {
+			if err = eventDispatcher.spool.append(event); err != nil {
+				return err
 			}
-			conn.Close()
+			eventDispatcher.stats.Spooled.Inc()
+			atomic.StoreInt32(&eventDispatcher.spooled, 1)
 		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDispatcherStopped) {
+		eventDispatcher.logger.Error().Err(err).Msg("Failed to replay the spooled events.")
+	}
+}
 
-		logger.Warn().Msgf("Cannot connect to RabbitMQ, Error [%v]. Trying again...", err)
-		time.Sleep(5 * time.Second)
+func marshalPayload(payload interface{}) ([]byte, error) {
+	if body, isByteMessage := payload.([]byte); isByteMessage {
+		return body, nil
 	}
+	return json.Marshal(payload)
 }
 
-func dialAMQP(connectionString string, isTLS bool) (*amqp.Connection, error) {
-	var cfg *tls.Config = nil
-	if isTLS {
-		caCert, _ := os.LookupEnv("ISLA_QUEUE_RMQ_CA_CERT")
-		clientCert, _ := os.LookupEnv("ISLA_QUEUE_RMQ_CERT")
-		clientCertKey, _ := os.LookupEnv("ISLA_QUEUE_RMQ_CERT_KEY")
+// rabbitConnector opens a channel on the shared connection and opens it again when it is closed, till the dispatcher is stopped
+func (eventDispatcher *RabbitMQEventDispatcher) rabbitConnector() {
+	for {
+		channel, err := eventDispatcher.connectionManager.Channel(context.Background())
+		if err != nil { // Connection manager closed
+			return
+		}
+		publisher, err := eventDispatcher.openConfirmingChannel(channel)
+		if err != nil {
+			eventDispatcher.logger.Warn().Err(err).Msg("Failed to prepare the channel for publishing. Trying again...")
+			channel.Close()
+			time.Sleep(eventDispatcher.connectionManager.Config().ReconnectInitialDelay)
+			continue
+		}
+		channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
 
-		if strings.TrimSpace(caCert) == "" || strings.TrimSpace(clientCert) == "" || strings.TrimSpace(clientCertKey) == "" {
-			return nil, fmt.Errorf("One or more client certificates not found")
+		eventDispatcher.connectionMutex.Lock()
+		if eventDispatcher.stopped {
+			eventDispatcher.connectionMutex.Unlock()
+			channel.Close()
+			return
 		}
+		eventDispatcher.publisher = publisher
+		atomic.StoreInt32(&eventDispatcher.connected, 1)
+		eventDispatcher.connectionMutex.Unlock()
 
-		cfg = &tls.Config{}
-		cfg.RootCAs = x509.NewCertPool()
-		cfg.RootCAs.AppendCertsFromPEM([]byte(caCert))
+		go eventDispatcher.replaySpool()
+		eventDispatcher.replaySpoolPeriodically(channelClosed)
 
-		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientCertKey))
-		if err != nil {
-			return nil, err
+		eventDispatcher.connectionMutex.Lock()
+		atomic.StoreInt32(&eventDispatcher.connected, 0)
+		eventDispatcher.publisher = nil
+		stopped := eventDispatcher.stopped
+		eventDispatcher.connectionMutex.Unlock()
+		if stopped {
+			return
 		}
-		cfg.Certificates = append(cfg.Certificates, cert)
 	}
-
-	return amqp.DialTLS(connectionString, cfg)
 }
 
-//TODO: read from config
-func getQueueConnectionString() (string, bool, string) {
-	var queueHost, queuePort, queueUser, queuePassword string
-	isTLS := false
-	queueProtocol := "amqp"
-	queueHost, ok := os.LookupEnv("ISLA_QUEUE_HOST")
-	if !ok {
-		queueHost = "localhost"
-	}
-	queuePassword, ok = os.LookupEnv("ISLA_QUEUE_PWD")
-	if !ok {
-		queuePassword = "guest"
-	}
-	queueUser, ok = os.LookupEnv("ISLA_QUEUE_USER")
-	if !ok {
-		queueUser = "guest"
-	}
-	queuePort, ok = os.LookupEnv("ISLA_QUEUE_PORT")
-	if !ok {
-		queuePort = "5672"
-	}
-	tls, ok := os.LookupEnv("ISLA_QUEUE_TLS_ENABLED")
-	if ok {
-		isTLS, _ = strconv.ParseBool(tls)
-		if isTLS {
-			queueProtocol = "amqps"
+// replaySpoolPeriodically replays the events spooled while connected till the channel is closed
+func (eventDispatcher *RabbitMQEventDispatcher) replaySpoolPeriodically(channelClosed chan *amqp.Error) {
+	ticker := time.NewTicker(spoolReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-channelClosed:
+			if err != nil {
+				eventDispatcher.logger.Warn().Err(err).Msg("Channel closed, opening a new channel.")
+			}
+			return
+		case <-ticker.C:
+			go eventDispatcher.replaySpoolIfSpooled()
 		}
 	}
+}
 
-	return fmt.Sprintf("%v://%v:%v@%v:%v/", queueProtocol, queueUser, queuePassword, queueHost, queuePort), isTLS, fmt.Sprintf("%v://%v:%v@%v:%v/", queueProtocol, "######", "######", queueHost, queuePort)
+// openConfirmingChannel declares the exchange and puts the channel in confirm mode
+func (eventDispatcher *RabbitMQEventDispatcher) openConfirmingChannel(channel *amqp.Channel) (*confirmingChannel, error) {
+	rabbitMQConfig := eventDispatcher.connectionManager.Config()
+	if err := channel.ExchangeDeclare(rabbitMQConfig.ExchangeName, rabbitMQConfig.ExchangeType, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare an exchange: %w", err)
+	}
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to put the channel in confirm mode: %w", err)
+	}
+	return &confirmingChannel{channel: channel, confirmations: channel.NotifyPublish(make(chan amqp.Confirmation, 1))}, nil
 }
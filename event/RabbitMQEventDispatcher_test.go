@@ -0,0 +1,58 @@
+package event
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/islax/microapp/metrics"
+	"github.com/rs/zerolog"
+)
+
+func TestRabbitMQEventDispatcherReplaysEventsSpooledWhileConnected(t *testing.T) {
+	logger := zerolog.Nop()
+	dispatcher := &RabbitMQEventDispatcher{
+		logger:        &logger,
+		sendChannel:   make(chan *queueCommand, 1),
+		spool:         newEventSpool(filepath.Join(t.TempDir(), "events.spool")),
+		stats:         metrics.GetEventDispatcherStats(),
+		pendingEvents: newPendingEvents(),
+	}
+
+	dispatcher.DispatchEvent("", "", "user.created", map[string]string{"id": "1"})
+	dispatcher.DispatchEvent("", "", "user.updated", map[string]string{"id": "1"}) // Spooled as the send channel is full
+	if _, err := os.Stat(dispatcher.spool.path); err != nil {
+		t.Fatalf("Expected the event to be spooled while the send channel is full, Actual [%v]", err)
+	}
+	<-dispatcher.sendChannel
+	dispatcher.pendingEvents.done()
+
+	published := make(chan string, 1)
+	go func() {
+		for command := range dispatcher.sendChannel {
+			published <- command.topic
+			dispatcher.finish(command, nil)
+		}
+	}()
+	defer close(dispatcher.sendChannel)
+
+	dispatcher.replaySpoolIfSpooled()
+	select {
+	case topic := <-published:
+		if topic != "user.updated" {
+			t.Errorf("Expected the spooled event [user.updated] to be replayed, Actual [%v]", topic)
+		}
+	default:
+		t.Fatal("Expected the spooled event to be replayed once the send channel drains, Actual [none]")
+	}
+	if _, err := os.Stat(dispatcher.spool.path + ".replay"); !os.IsNotExist(err) {
+		t.Errorf("Expected the replayed events to be removed from the spool, Actual [%v]", err)
+	}
+
+	dispatcher.replaySpoolIfSpooled()
+	select {
+	case topic := <-published:
+		t.Errorf("Expected no replay till an event is spooled again, Actual [%v]", topic)
+	default:
+	}
+}
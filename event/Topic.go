@@ -0,0 +1,34 @@
+package event
+
+import "strings"
+
+// NormalizeTopic converts the topic to the routing key format used by the topic exchange, e.g. tenant_added becomes tenant.added
+func NormalizeTopic(topic string) string {
+	return strings.ReplaceAll(topic, "_", ".")
+}
+
+// MatchTopic returns whether the routing key matches the pattern as per topic exchange semantics.
+// '*' matches exactly one word and '#' matches zero or more words, words are separated by '.'.
+func MatchTopic(pattern string, routingKey string) bool {
+	return matchWords(strings.Split(NormalizeTopic(pattern), "."), strings.Split(NormalizeTopic(routingKey), "."))
+}
+
+func matchWords(patternWords []string, words []string) bool {
+	if len(patternWords) == 0 {
+		return len(words) == 0
+	}
+
+	switch patternWords[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(patternWords[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(patternWords[1:], words[1:])
+	default:
+		return len(words) > 0 && patternWords[0] == words[0] && matchWords(patternWords[1:], words[1:])
+	}
+}
@@ -0,0 +1,40 @@
+package event
+
+import (
+	"testing"
+)
+
+var topicCombinations = []struct {
+	pattern    string
+	routingKey string
+	result     bool
+}{
+	{"tenant.added", "tenant.added", true},
+	{"tenant_added", "tenant.added", true},
+	{"tenant.added", "tenant_added", true},
+	{"tenant.added", "tenant.deleted", false},
+	{"tenant.*", "tenant.added", true},
+	{"tenant.*", "tenant.settings.updated", false},
+	{"*.added", "tenant.added", true},
+	{"*", "tenant.added", false},
+	{"tenant.#", "tenant", true},
+	{"tenant.#", "tenant.added", true},
+	{"tenant.#", "tenant.settings.updated", true},
+	{"#", "tenant.settings.updated", true},
+	{"#.updated", "tenant.settings.updated", true},
+	{"#.updated", "tenant.settings.added", false},
+	{"tenant.#.updated", "tenant.updated", true},
+	{"tenant.*.updated", "tenant.updated", false},
+	{"tenant.added", "tenant.added.v2", false},
+}
+
+func TestMatchTopic(t *testing.T) {
+	for _, combination := range topicCombinations {
+		t.Run(combination.pattern+"|"+combination.routingKey, func(t *testing.T) {
+			result := MatchTopic(combination.pattern, combination.routingKey)
+			if result != combination.result {
+				t.Errorf("got %v, want %v", result, combination.result)
+			}
+		})
+	}
+}
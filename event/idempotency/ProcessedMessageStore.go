@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/islax/microapp/model"
+	"github.com/islax/microapp/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrMessageInProgress indicates that the message is being processed by another delivery, the message should be redelivered later
+var ErrMessageInProgress = errors.New("message is being processed")
+
+// ProcessedMessageStore records the ids of the messages processed by the consumers
+type ProcessedMessageStore interface {
+	// TryMarkProcessed marks the message as being processed by the consumer before it is handled and returns false if it is already processed
+	TryMarkProcessed(uow *repository.UnitOfWork, consumer string, messageID string) (bool, error)
+	// MarkCompleted records that the message is processed, once the handler succeeds and its unit of work is committed
+	MarkCompleted(uow *repository.UnitOfWork, consumer string, messageID string) error
+	// Unmark removes the mark if the message could not be processed, so that it is processed again on redelivery
+	Unmark(uow *repository.UnitOfWork, consumer string, messageID string) error
+}
+
+// Migrate creates or updates the processed message table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&model.ProcessedMessage{})
+}
+
+// Purge removes the processed messages older than the given duration
+func Purge(db *gorm.DB, olderThan time.Duration) error {
+	return db.Where("processedOn < ?", time.Now().UTC().Add(-olderThan)).Delete(&model.ProcessedMessage{}).Error
+}
+
+type dbProcessedMessageStore struct {
+}
+
+// NewDBProcessedMessageStore creates a store which records the processed messages in the processed_message table within the unit of work of the handler,
+// so the mark is rolled back along with the changes of the handler
+func NewDBProcessedMessageStore() ProcessedMessageStore {
+	return &dbProcessedMessageStore{}
+}
+
+func (store *dbProcessedMessageStore) TryMarkProcessed(uow *repository.UnitOfWork, consumer string, messageID string) (bool, error) {
+	if uow == nil {
+		return false, errors.New("unit of work is required to record processed messages in database")
+	}
+	result := uow.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.ProcessedMessage{Consumer: consumer, MessageID: messageID, ProcessedAt: time.Now().UTC()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (store *dbProcessedMessageStore) MarkCompleted(uow *repository.UnitOfWork, consumer string, messageID string) error {
+	// The mark is committed along with the unit of work
+	return nil
+}
+
+func (store *dbProcessedMessageStore) Unmark(uow *repository.UnitOfWork, consumer string, messageID string) error {
+	// The mark is rolled back along with the unit of work
+	return nil
+}
+
+// memcachedProcessingLease is the max time a message is marked as being processed, a message left marked by a crashed consumer is processed again once the lease expires
+const memcachedProcessingLease = 5 * time.Minute
+
+var (
+	memcachedProcessing = []byte("processing")
+	memcachedProcessed  = []byte("processed")
+)
+
+type memcachedProcessedMessageStore struct {
+	client *memcache.Client
+	ttl    time.Duration
+}
+
+// NewMemcachedProcessedMessageStore creates a store which records the processed messages in memcached for the given duration.
+// The mark is not part of the unit of work, so the message is marked as being processed for 5 minutes before it is handled and as processed once the handler succeeds.
+// A redelivery while the message is being processed fails with ErrMessageInProgress, so that it is redelivered later,
+// if the consumer crashes the message is processed again once the mark expires. The handler must be idempotent for the redelivery after a crash between commit and MarkCompleted.
+func NewMemcachedProcessedMessageStore(client *memcache.Client, ttl time.Duration) ProcessedMessageStore {
+	return &memcachedProcessedMessageStore{client: client, ttl: ttl}
+}
+
+func (store *memcachedProcessedMessageStore) TryMarkProcessed(uow *repository.UnitOfWork, consumer string, messageID string) (bool, error) {
+	lease := memcachedProcessingLease
+	if store.ttl < lease {
+		lease = store.ttl
+	}
+	key := memcachedKey(consumer, messageID)
+	err := store.client.Add(&memcache.Item{Key: key, Value: memcachedProcessing, Expiration: int32(lease / time.Second)})
+	if err != memcache.ErrNotStored {
+		return err == nil, err
+	}
+
+	item, err := store.client.Get(key)
+	if err == memcache.ErrCacheMiss { // The mark expired in between
+		return false, ErrMessageInProgress
+	}
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(item.Value, memcachedProcessing) {
+		return false, ErrMessageInProgress
+	}
+	return false, nil
+}
+
+func (store *memcachedProcessedMessageStore) MarkCompleted(uow *repository.UnitOfWork, consumer string, messageID string) error {
+	return store.client.Set(&memcache.Item{Key: memcachedKey(consumer, messageID), Value: memcachedProcessed, Expiration: int32(store.ttl / time.Second)})
+}
+
+func (store *memcachedProcessedMessageStore) Unmark(uow *repository.UnitOfWork, consumer string, messageID string) error {
+	err := store.client.Delete(memcachedKey(consumer, messageID))
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+func memcachedKey(consumer string, messageID string) string {
+	return "processed:" + consumer + ":" + messageID
+}
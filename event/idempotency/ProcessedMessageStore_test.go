@@ -0,0 +1,163 @@
+package idempotency
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/islax/microapp/log"
+	"github.com/islax/microapp/model"
+	"github.com/islax/microapp/repository"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDBProcessedMessageStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Each connection opens a new in-memory database
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	store := NewDBProcessedMessageStore()
+	tryMark := func(messageID string, commit bool) bool {
+		uow := repository.NewUnitOfWork(db, false, zerolog.Nop(), log.Config{})
+		defer uow.Complete()
+		isNew, err := store.TryMarkProcessed(uow, "consumer", messageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.MarkCompleted(uow, "consumer", messageID); err != nil {
+			t.Fatal(err)
+		}
+		if commit {
+			if err = uow.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return isNew
+	}
+
+	if !tryMark("1", true) {
+		t.Error("Expected a new message to be marked")
+	}
+	if tryMark("1", true) {
+		t.Error("Expected the committed message to be reported as processed")
+	}
+	if !tryMark("2", false) || !tryMark("2", true) {
+		t.Error("Expected the mark to be rolled back along with the unit of work")
+	}
+
+	db.Model(&model.ProcessedMessage{}).Where("messageId = ?", "1").Update("processedOn", time.Now().UTC().Add(-2*time.Hour))
+	if err = Purge(db, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&model.ProcessedMessage{}).Count(&count)
+	if count != 1 || !tryMark("1", true) {
+		t.Errorf("Expected only the messages older than the retention to be purged, Actual count [%v]", count)
+	}
+}
+
+func TestMemcachedProcessedMessageStore(t *testing.T) {
+	store := NewMemcachedProcessedMessageStore(memcache.New(startFakeMemcached(t)), time.Hour)
+
+	if isNew, err := store.TryMarkProcessed(nil, "consumer", "1"); !isNew || err != nil {
+		t.Fatalf("Expected a new message to be marked, Actual [%v] [%v]", isNew, err)
+	}
+	if _, err := store.TryMarkProcessed(nil, "consumer", "1"); err != ErrMessageInProgress {
+		t.Errorf("Expected the redelivery to fail while the message is being processed, Actual [%v]", err)
+	}
+	if err := store.MarkCompleted(nil, "consumer", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if isNew, err := store.TryMarkProcessed(nil, "consumer", "1"); isNew || err != nil {
+		t.Errorf("Expected the completed message to be reported as processed, Actual [%v] [%v]", isNew, err)
+	}
+
+	if isNew, _ := store.TryMarkProcessed(nil, "consumer", "2"); !isNew {
+		t.Fatal("Expected a new message to be marked")
+	}
+	if err := store.Unmark(nil, "consumer", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if isNew, err := store.TryMarkProcessed(nil, "consumer", "2"); !isNew || err != nil {
+		t.Errorf("Expected the unmarked message to be processed again, Actual [%v] [%v]", isNew, err)
+	}
+}
+
+// startFakeMemcached starts a memcached server supporting add, set, gets and delete without expiration and returns its address
+func startFakeMemcached(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mutex sync.Mutex
+	items := map[string]string{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					if len(fields) < 2 {
+						return
+					}
+					mutex.Lock()
+					switch fields[0] {
+					case "add", "set":
+						var size int
+						fmt.Sscan(fields[4], &size)
+						value := make([]byte, size+2)
+						if _, err = io.ReadFull(reader, value); err != nil {
+							mutex.Unlock()
+							return
+						}
+						if _, exists := items[fields[1]]; exists && fields[0] == "add" {
+							fmt.Fprint(conn, "NOT_STORED\r\n")
+						} else {
+							items[fields[1]] = string(value[:size])
+							fmt.Fprint(conn, "STORED\r\n")
+						}
+					case "gets":
+						if value, exists := items[fields[1]]; exists {
+							fmt.Fprintf(conn, "VALUE %v 0 %v 1\r\n%v\r\n", fields[1], len(value), value)
+						}
+						fmt.Fprint(conn, "END\r\n")
+					case "delete":
+						if _, exists := items[fields[1]]; exists {
+							delete(items, fields[1])
+							fmt.Fprint(conn, "DELETED\r\n")
+						} else {
+							fmt.Fprint(conn, "NOT_FOUND\r\n")
+						}
+					}
+					mutex.Unlock()
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// Purger periodically removes the processed messages older than the retention, redeliveries after the retention are not deduplicated
+type Purger struct {
+	db        *gorm.DB
+	logger    *zerolog.Logger
+	interval  time.Duration
+	retention time.Duration
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// defaultPurgeInterval is used if PROCESSED_MESSAGE_PURGE_INTERVAL is not positive
+const defaultPurgeInterval = time.Hour
+
+// NewPurger creates a new purger of the processed message table
+func NewPurger(db *gorm.DB, logger *zerolog.Logger, appConfig *config.Config) *Purger {
+	interval := time.Duration(appConfig.GetInt(config.EvSuffixForProcessedMessagePurgeInterval)) * time.Minute
+	if interval <= 0 {
+		logger.Warn().Dur("interval", interval).Dur("defaultInterval", defaultPurgeInterval).Msgf("Invalid %v, using the default interval.", config.EvSuffixForProcessedMessagePurgeInterval)
+		interval = defaultPurgeInterval
+	}
+	return &Purger{
+		db:        db,
+		logger:    logger,
+		interval:  interval,
+		retention: time.Duration(appConfig.GetInt(config.EvSuffixForProcessedMessageRetention)) * time.Hour,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start starts purging in background, the first purge runs immediately
+func (purger *Purger) Start() {
+	purger.startOnce.Do(func() {
+		go purger.run()
+	})
+}
+
+// Stop stops the purger after the running purge is completed or the context is done
+func (purger *Purger) Stop(ctx context.Context) error {
+	purger.stopOnce.Do(func() {
+		close(purger.stop)
+	})
+	select {
+	case <-purger.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (purger *Purger) run() {
+	defer close(purger.done)
+	ticker := time.NewTicker(purger.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := Purge(purger.db, purger.retention); err != nil {
+			purger.logger.Error().Err(err).Msg("Failed to purge processed messages.")
+		}
+
+		select {
+		case <-purger.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
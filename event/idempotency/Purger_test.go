@@ -0,0 +1,25 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/rs/zerolog"
+)
+
+func TestNewPurgerWithInvalidInterval(t *testing.T) {
+	logger := zerolog.Nop()
+	for _, interval := range []int{0, -1} {
+		appConfig := config.NewConfig(nil)
+		appConfig.Set(config.EvSuffixForProcessedMessagePurgeInterval, interval)
+		if purger := NewPurger(nil, &logger, appConfig); purger.interval != defaultPurgeInterval {
+			t.Errorf("Interval [%v]: Expected default interval [%v], Actual [%v]", interval, defaultPurgeInterval, purger.interval)
+		}
+	}
+	appConfig := config.NewConfig(nil)
+	appConfig.Set(config.EvSuffixForProcessedMessagePurgeInterval, 5)
+	if purger := NewPurger(nil, &logger, appConfig); purger.interval != 5*time.Minute {
+		t.Errorf("Expected interval [5m], Actual [%v]", purger.interval)
+	}
+}
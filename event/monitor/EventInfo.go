@@ -1,9 +1,70 @@
 package monitor
 
+import (
+	"time"
+
+	"github.com/islax/microapp/event"
+)
+
 // EventInfo represents the message received from queu
 type EventInfo struct {
 	RawToken     string
 	CorelationID string
+	TenantID     string
+	MessageID    string
+	Timestamp    time.Time
+	Version      string            // Schema version of the payload
+	CloudEvent   *event.CloudEvent // CloudEvents attributes, nil if the message is not a CloudEvent
 	Name         string
 	Payload      string
+	Headers      map[string]interface{} // All the headers received with the message
+	RetryCount   int                    // Number of times the message has been redelivered, populated only for acknowledging event monitors
+
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+// Ack acknowledges the message. It is a no-op for the event monitors which do not use manual acknowledgement.
+func (eventInfo *EventInfo) Ack() error {
+	if eventInfo.ack == nil {
+		return nil
+	}
+	return eventInfo.ack()
+}
+
+// Nack rejects the message. If requeue is true, the message is redelivered after the retry delay till the max retries are exhausted,
+// after which it is routed to the dead letter queue. If requeue is false, the message is routed to the dead letter queue immediately.
+// It is a no-op for the event monitors which do not use manual acknowledgement.
+func (eventInfo *EventInfo) Nack(requeue bool) error {
+	if eventInfo.nack == nil {
+		return nil
+	}
+	return eventInfo.nack(requeue)
+}
+
+// SetAcknowledgement sets the callbacks invoked by Ack and Nack, for the event monitors implemented outside this package
+func (eventInfo *EventInfo) SetAcknowledgement(ack func() error, nack func(requeue bool) error) {
+	eventInfo.ack = ack
+	eventInfo.nack = nack
+}
+
+// GetHeader returns the value of the header as string, empty string is returned if the header is not present or is not a string
+func (eventInfo *EventInfo) GetHeader(name string) string {
+	value, _ := eventInfo.Headers[name].(string)
+	return value
+}
+
+// decodeCloudEvent populates the CloudEvents attributes and replaces the payload with the data of the CloudEvent
+func (eventInfo *EventInfo) decodeCloudEvent(contentType string, body []byte) error {
+	eventInfo.Version = eventInfo.GetHeader(event.HeaderEventVersion)
+	cloudEvent, data, err := event.DecodeCloudEvent(contentType, eventInfo.Headers, body)
+	if err != nil || cloudEvent == nil {
+		return err
+	}
+	eventInfo.CloudEvent = cloudEvent
+	eventInfo.Payload = string(data)
+	if cloudEvent.ID != "" {
+		eventInfo.MessageID = cloudEvent.ID
+	}
+	return nil
 }
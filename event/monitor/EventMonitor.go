@@ -1,6 +1,16 @@
 package monitor
 
 import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/islax/microapp/config"
+
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/event/rabbitmq"
+	"github.com/islax/microapp/event/schema"
 	"github.com/rs/zerolog"
 )
 
@@ -9,30 +19,103 @@ type EventMonitor interface {
 	initialize(eventsToMonitor []string) error
 	Start()
 	Stop()
+	IsConnected() bool
 }
 
-// NewEventMonitor creates a new eventMonitor that publishes received events to the specified channel
-func NewEventMonitor(logger *zerolog.Logger, eventsToMonitor []string, eventSignal chan *EventInfo) (EventMonitor, error) {
-	ctxLogger := logger.With().Str("module", "RabbitMQEventMonitor").Logger()
-	monitor := &rabbitMQEventMonitor{logger: &ctxLogger, eventSignal: eventSignal}
+// ConsumerOptions configures an acknowledging event monitor
+type ConsumerOptions struct {
+	Durable       bool          // Declare the queue, retry queue and dead letter queue as durable
+	MaxRetries    int           // Number of redeliveries of a nacked message before it is routed to the dead letter queue
+	RetryDelay    time.Duration // Delay before a nacked message is redelivered
+	PrefetchCount int           // Number of unacknowledged messages delivered to the consumer, zero means no limit
+}
+
+// DefaultConsumerOptions returns the consumer options with durable queues, 5 retries with delay of 30 seconds and prefetch count of 10
+func DefaultConsumerOptions() *ConsumerOptions {
+	return &ConsumerOptions{Durable: true, MaxRetries: 5, RetryDelay: 30 * time.Second, PrefetchCount: 10}
+}
 
-	err := monitor.initialize(eventsToMonitor)
-	if err != nil {
-		return nil, err
+var (
+	defaultBroker      string
+	defaultBrokerMutex sync.Mutex
+)
+
+// SetDefaultBroker sets the event broker, rabbitmq or inmemory, used by the event monitors created without a broker
+func SetDefaultBroker(broker string) {
+	defaultBrokerMutex.Lock()
+	defer defaultBrokerMutex.Unlock()
+	defaultBroker = broker
+}
+
+// DefaultBroker returns the default event broker, if not set, it is read from the ISLA_EVENT_BROKER environment variable
+func DefaultBroker() string {
+	defaultBrokerMutex.Lock()
+	defer defaultBrokerMutex.Unlock()
+	if defaultBroker == "" {
+		defaultBroker = config.NewConfig(nil).GetString(config.EvSuffixForEventBroker)
 	}
+	return defaultBroker
+}
 
-	return monitor, nil
+// conformsToSchema validates the payload against the schema registered in the default registry for the event name and version.
+// The events which do not conform are rejected without requeue, i.e. routed to the dead letter queue by the acknowledging event monitors,
+// so that they are not delivered to the handlers whether they are subscribed through the router or directly on the monitor.
+func conformsToSchema(logger *zerolog.Logger, eventInfo *EventInfo) bool {
+	err := schema.DefaultRegistry().Validate(eventInfo.Name, eventInfo.Version, []byte(eventInfo.Payload))
+	if err == nil {
+		return true
+	}
+	logger.Error().Err(err).Str("event", eventInfo.Name).Str("version", eventInfo.Version).Msg("Event payload does not conform to the schema, rejecting the event.")
+	if nackErr := eventInfo.Nack(false); nackErr != nil {
+		logger.Warn().Err(nackErr).Str("event", eventInfo.Name).Msg("Failed to reject the event.")
+	}
+	return false
+}
+
+// NewEventMonitor creates a new eventMonitor that publishes received events to the specified channel
+// If the default broker is inmemory, the events are received from the default in-memory broker.
+func NewEventMonitor(logger *zerolog.Logger, eventsToMonitor []string, eventSignal chan *EventInfo) (EventMonitor, error) {
+	return NewEventMonitorForBroker(logger, DefaultBroker(), "", eventsToMonitor, eventSignal, nil)
 }
 
 // NewEventMonitorForQueue creates a new eventMonitor that publishes received events from a named queue to the specified channel
+// If the default broker is inmemory, the events are received from the default in-memory broker.
 func NewEventMonitorForQueue(logger *zerolog.Logger, queueName string, eventsToMonitor []string, eventSignal chan *EventInfo) (EventMonitor, error) {
-	ctxLogger := logger.With().Str("module", "RabbitMQEventMonitor").Logger()
-	monitor := &rabbitMQEventMonitor{logger: &ctxLogger, queueName: queueName, eventSignal: eventSignal}
+	return NewEventMonitorForBroker(logger, DefaultBroker(), queueName, eventsToMonitor, eventSignal, nil)
+}
 
-	err := monitor.initialize(eventsToMonitor)
-	if err != nil {
-		return nil, err
+// NewAcknowledgingEventMonitorForQueue creates a new eventMonitor that publishes received events from a named queue to the specified channel.
+// The handler of the event must call EventInfo.Ack or EventInfo.Nack, unacknowledged messages are redelivered when the connection is re-established.
+// Nacked messages are redelivered after a delay and routed to the dead letter queue <queueName>.dlq once the max retries are exhausted.
+// If the default broker is inmemory, the events are received from the default in-memory broker and Ack / Nack are no-op.
+// A queue declared before, e.g. by a non acknowledging monitor, without the dead letter arguments or with a different durability can not be redeclared,
+// the monitor then consumes from it as is and publishes the rejected messages to the dead letter queue itself. Delete such a queue once drained,
+// so that it is declared with the dead letter arguments, as the messages rejected by the other consumers of the queue are dropped by the broker.
+func NewAcknowledgingEventMonitorForQueue(logger *zerolog.Logger, queueName string, eventsToMonitor []string, eventSignal chan *EventInfo, options *ConsumerOptions) (EventMonitor, error) {
+	if queueName == "" {
+		return nil, errors.New("queue name is required for acknowledging event monitor")
 	}
+	if options == nil {
+		options = DefaultConsumerOptions()
+	}
+	return NewEventMonitorForBroker(logger, DefaultBroker(), queueName, eventsToMonitor, eventSignal, options)
+}
+
+// NewEventMonitorForBroker creates a new eventMonitor for the given broker, rabbitmq or inmemory, e.g. the broker selected by the App configuration.
+// The in-memory monitor receives the events from the default in-memory broker, the RabbitMQ monitor consumes on a channel of the default connection manager.
+// If options is nil, the messages are acknowledged automatically, otherwise the monitor acknowledges as described in NewAcknowledgingEventMonitorForQueue.
+func NewEventMonitorForBroker(logger *zerolog.Logger, broker string, queueName string, eventsToMonitor []string, eventSignal chan *EventInfo, options *ConsumerOptions) (EventMonitor, error) {
+	if strings.EqualFold(strings.TrimSpace(broker), event.BrokerInMemory) {
+		return NewInMemoryEventMonitor(logger, event.DefaultInMemoryBroker(), eventsToMonitor, eventSignal)
+	}
+	return NewEventMonitorWithConnectionManager(logger, rabbitmq.DefaultConnectionManager(), queueName, eventsToMonitor, eventSignal, options)
+}
 
-	return monitor, nil
+// NewEventMonitorWithConnectionManager creates a new eventMonitor that consumes the events from a named queue on a channel of the given connection manager.
+// If options is nil, the messages are acknowledged automatically, otherwise the monitor acknowledges as described in NewAcknowledgingEventMonitorForQueue.
+func NewEventMonitorWithConnectionManager(logger *zerolog.Logger, connectionManager *rabbitmq.ConnectionManager, queueName string, eventsToMonitor []string, eventSignal chan *EventInfo, options *ConsumerOptions) (EventMonitor, error) {
+	if options != nil && queueName == "" {
+		return nil, errors.New("queue name is required for acknowledging event monitor")
+	}
+	return newRabbitMQEventMonitor(logger, connectionManager, queueName, eventsToMonitor, eventSignal, options)
 }
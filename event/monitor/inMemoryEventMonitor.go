@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"sync"
+
+	"github.com/islax/microapp/event"
+	"github.com/rs/zerolog"
+)
+
+// inMemoryEventMonitor receives the events from the in-memory broker, Ack and Nack of the received events are no-op
+type inMemoryEventMonitor struct {
+	logger          *zerolog.Logger
+	broker          *event.InMemoryBroker
+	eventSignal     chan *EventInfo
+	eventsToMonitor []string
+	mutex           sync.Mutex
+	unsubscribe     func()
+}
+
+func (monitor *inMemoryEventMonitor) initialize(eventsToMonitor []string) error {
+	monitor.eventsToMonitor = eventsToMonitor
+	return nil
+}
+
+func (monitor *inMemoryEventMonitor) Start() {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if monitor.unsubscribe != nil {
+		return
+	}
+	monitor.unsubscribe = monitor.broker.Subscribe(monitor.eventsToMonitor, func(message *event.InMemoryMessage) {
+		eventInfo := &EventInfo{
+			Payload:   string(message.Body),
+			MessageID: message.MessageID,
+			Timestamp: message.Timestamp,
+			Headers:   message.Headers,
+
+			Name: message.RoutingKey,
+		}
+		eventInfo.RawToken = eventInfo.GetHeader(event.HeaderAuthorization)
+		eventInfo.CorelationID = eventInfo.GetHeader(event.HeaderCorrelationID)
+		eventInfo.TenantID = eventInfo.GetHeader(event.HeaderTenantID)
+		if err := eventInfo.decodeCloudEvent(message.ContentType, message.Body); err != nil {
+			monitor.logger.Warn().Err(err).Str("event", eventInfo.Name).Msg("Failed to decode the CloudEvent, using the message body as payload.")
+		}
+		if !conformsToSchema(monitor.logger, eventInfo) {
+			return
+		}
+		monitor.eventSignal <- eventInfo
+	})
+}
+
+func (monitor *inMemoryEventMonitor) Stop() {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if monitor.unsubscribe != nil {
+		monitor.unsubscribe()
+		monitor.unsubscribe = nil
+	}
+}
+
+func (monitor *inMemoryEventMonitor) IsConnected() bool {
+	return true
+}
+
+// NewInMemoryEventMonitor creates a new eventMonitor that publishes the events received from the in-memory broker to the specified channel
+func NewInMemoryEventMonitor(logger *zerolog.Logger, broker *event.InMemoryBroker, eventsToMonitor []string, eventSignal chan *EventInfo) (EventMonitor, error) {
+	ctxLogger := logger.With().Str("module", "InMemoryEventMonitor").Logger()
+	monitor := &inMemoryEventMonitor{logger: &ctxLogger, broker: broker, eventSignal: eventSignal}
+
+	err := monitor.initialize(eventsToMonitor)
+	if err != nil {
+		return nil, err
+	}
+
+	return monitor, nil
+}
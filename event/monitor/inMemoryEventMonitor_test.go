@@ -0,0 +1,44 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/event/schema"
+	"github.com/rs/zerolog"
+)
+
+func TestInMemoryEventMonitorRejectsPayloadNotConformingToSchema(t *testing.T) {
+	if err := schema.DefaultRegistry().Register("monitor_test.tenant.created", "", []byte(`{"type": "object", "required": ["id"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.Nop()
+	broker := event.NewInMemoryBroker(&logger)
+	broker.UseSchemaRegistry(nil) // Publish without validation, so that the monitor receives the invalid payload
+
+	eventSignal := make(chan *EventInfo, 2)
+	eventMonitor, err := NewInMemoryEventMonitor(&logger, broker, []string{"monitor_test.tenant.*"}, eventSignal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventMonitor.Start()
+	defer eventMonitor.Stop()
+
+	broker.DispatchEvent("", "", "monitor_test.tenant.created", map[string]string{"name": "acme"})
+	broker.DispatchEvent("", "", "monitor_test.tenant.created", map[string]string{"id": "1"})
+
+	select {
+	case eventInfo := <-eventSignal:
+		if eventInfo.Payload != `{"id":"1"}` {
+			t.Errorf("Expected only the valid event to be delivered, Actual [%v]", eventInfo.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected valid event to be delivered, Actual [none]")
+	}
+	select {
+	case eventInfo := <-eventSignal:
+		t.Errorf("Expected invalid event to be rejected, Actual [%v]", eventInfo.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
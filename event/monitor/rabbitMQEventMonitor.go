@@ -1,218 +1,336 @@
 package monitor
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/event/rabbitmq"
 	"github.com/rs/zerolog"
 	"github.com/streadway/amqp"
 )
 
+const (
+	headerRetryCount         = "X-Retry-Count"
+	headerOriginalRoutingKey = "X-Original-Routing-Key"
+)
+
+// errQueueArgumentsMismatch indicates that the queue exists with different durability or arguments, e.g. declared before the dead letter queue was introduced
+var errQueueArgumentsMismatch = errors.New("queue exists with different durability or arguments")
+
+// publisher publishes the messages to the retry queue and the dead letter exchange, implemented by amqp.Channel
+type publisher interface {
+	Publish(exchange string, key string, mandatory bool, immediate bool, msg amqp.Publishing) error
+}
+
 type rabbitMQEventMonitor struct {
-	logger          *zerolog.Logger
-	queueName       string
-	eventSignal     chan *EventInfo
-	eventsToMonitor []string
+	logger            *zerolog.Logger
+	connectionManager *rabbitmq.ConnectionManager
+	queueName         string
+	eventSignal       chan *EventInfo
+	eventsToMonitor   []string
+	consumerOptions   *ConsumerOptions // nil for auto acknowledgement
+	existingQueue     bool             // the queue exists without the dead letter arguments, rejected messages are published to the dead letter exchange by the monitor
+
+	queueChannel *amqp.Channel
+
+	ctx             context.Context
+	cancel          context.CancelFunc
+	connectionMutex sync.Mutex
+	stopped         bool
+	connected       int32
+}
+
+func newRabbitMQEventMonitor(logger *zerolog.Logger, connectionManager *rabbitmq.ConnectionManager, queueName string, eventsToMonitor []string, eventSignal chan *EventInfo, options *ConsumerOptions) (EventMonitor, error) {
+	if connectionManager == nil {
+		return nil, errors.New("connection manager is required")
+	}
+	ctxLogger := logger.With().Str("module", "RabbitMQEventMonitor").Logger()
+	monitor := &rabbitMQEventMonitor{logger: &ctxLogger, connectionManager: connectionManager, queueName: queueName, eventSignal: eventSignal, consumerOptions: options}
 
-	messageChanel   <-chan amqp.Delivery
-	queueConnection *amqp.Connection
-	queueChannel    *amqp.Channel
+	err := monitor.initialize(eventsToMonitor)
+	if err != nil {
+		return nil, err
+	}
 
-	connectionCloseChannel chan *amqp.Error
+	return monitor, nil
 }
 
 func (monitor *rabbitMQEventMonitor) initialize(eventsToMonitor []string) error {
-	monitor.connectionCloseChannel = make(chan *amqp.Error)
+	monitor.ctx, monitor.cancel = context.WithCancel(context.Background())
 	monitor.eventsToMonitor = eventsToMonitor
-	go monitor.rabbitConnector()
-
 	return nil
 }
 
-func (monitor *rabbitMQEventMonitor) rabbitConnector() {
-	var rabbitErr *amqp.Error
-
+// consume opens a channel on the shared connection and consumes the messages, the channel is opened again when it is closed till the monitor is stopped
+func (monitor *rabbitMQEventMonitor) consume() {
 	for {
-		rabbitErr = <-monitor.connectionCloseChannel
-		if rabbitErr != nil {
-			connectionString, isTLS := getQueueConnectionString()
-			connection, queueChannel, messageChanel := monitor.connectToRabbitMQ(monitor.queueName, connectionString, isTLS, monitor.eventsToMonitor)
+		queueChannel, err := monitor.connectionManager.Channel(monitor.ctx)
+		if err != nil { // Monitor stopped or connection manager closed
+			return
+		}
+
+		messageChanel, err := monitor.subscribe(queueChannel)
+		if err != nil {
+			queueChannel.Close()
+			if errors.Is(err, errQueueArgumentsMismatch) && !monitor.existingQueue {
+				// The broker closes the channel on the mismatch, consume from the existing queue as is on a new channel
+				monitor.logger.Warn().Err(err).Str("queue", monitor.queueName).Msg("Queue exists without the dead letter arguments, consuming from it as is. Delete the queue to let the monitor declare it with the dead letter arguments.")
+				monitor.existingQueue = true
+				continue
+			}
+			monitor.logger.Warn().Err(err).Msg("Cannot consume from RabbitMQ. Trying again...")
+			select {
+			case <-time.After(monitor.connectionManager.Config().ReconnectInitialDelay):
+				continue
+			case <-monitor.ctx.Done():
+				return
+			}
+		}
 
-			monitor.queueConnection = connection
-			monitor.queueChannel = queueChannel
-			monitor.messageChanel = messageChanel
+		monitor.connectionMutex.Lock()
+		if monitor.stopped {
+			monitor.connectionMutex.Unlock()
+			queueChannel.Close()
+			return
+		}
+		monitor.queueChannel = queueChannel
+		atomic.StoreInt32(&monitor.connected, 1)
+		monitor.connectionMutex.Unlock()
 
-			monitor.connectionCloseChannel = make(chan *amqp.Error)
-			monitor.queueConnection.NotifyClose(monitor.connectionCloseChannel)
+		// Returns once the channel is closed
+		monitor.monitorQueueAndProcessMessages(queueChannel, messageChanel)
 
-			go monitor.monitorQueueAndProcessMessages()
+		monitor.connectionMutex.Lock()
+		atomic.StoreInt32(&monitor.connected, 0)
+		monitor.queueChannel = nil
+		stopped := monitor.stopped
+		monitor.connectionMutex.Unlock()
+		if stopped {
+			return
 		}
+		monitor.logger.Warn().Msg("Channel closed, consuming on a new channel.")
 	}
 }
 
-func (monitor *rabbitMQEventMonitor) connectToRabbitMQ(queueName string, connectionString string, isTLS bool, eventsToMonitor []string) (*amqp.Connection, *amqp.Channel, <-chan amqp.Delivery) {
-	for {
-		queueConnection, err := dialAMQP(connectionString, isTLS)
+// subscribe declares the exchange and queues, binds the events to monitor and registers the consumer
+func (monitor *rabbitMQEventMonitor) subscribe(queueChannel *amqp.Channel) (<-chan amqp.Delivery, error) {
+	rabbitMQConfig := monitor.connectionManager.Config()
+	err := queueChannel.ExchangeDeclare(
+		rabbitMQConfig.ExchangeName, // name
+		rabbitMQConfig.ExchangeType, // type
+		true,                        // durable
+		false,                       // auto-deleted
+		false,                       // internal
+		false,                       // no-wait
+		nil,                         // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare an exchange: %w", err)
+	}
 
+	q, err := monitor.declareQueues(queueChannel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare a queue: %w", err)
+	}
+
+	for _, eventToMonitor := range monitor.eventsToMonitor {
+		err = queueChannel.QueueBind(
+			q.Name,                               // queue name
+			event.NormalizeTopic(eventToMonitor), // routing key
+			rabbitMQConfig.ExchangeName,          // exchange
+			false,
+			nil)
 		if err != nil {
-			monitor.logger.Error().Err(err).Msg("Unable to connect to rabbitMQ.")
-		} else {
-			queueChannel, err := queueConnection.Channel()
-			if err != nil {
-				monitor.logger.Error().Err(err).Msg("Failed to open a channel.")
-			} else {
-				err = queueChannel.ExchangeDeclare(
-					"isla_exchange", // name
-					"topic",         // type
-					true,            // durable
-					false,           // auto-deleted
-					false,           // internal
-					false,           // no-wait
-					nil,             // arguments
-				)
-				if err != nil {
-					monitor.logger.Error().Err(err).Msg("Failed to declare an exchange.")
-				} else {
-					q, err := queueChannel.QueueDeclare(
-						monitor.queueName, // name
-						false,             // durable
-						false,             // delete when unused
-						false,             // exclusive
-						false,             // no-wait
-						nil,               // arguments
-					)
-					if err != nil {
-						monitor.logger.Error().Err(err).Msg("Failed to declare a queue.")
-					} else {
-						for _, event := range eventsToMonitor {
-							normalizedEvent := strings.ReplaceAll(event, "_", ".")
-							err = queueChannel.QueueBind(
-								q.Name,          // queue name
-								normalizedEvent, // routing key
-								"isla_exchange", // exchange
-								false,
-								nil)
-							if err != nil {
-								monitor.logger.Error().Err(err).Msgf("Failed to bind a event - %v", event)
-							}
-						}
-
-						messageChanel, err := queueChannel.Consume(
-							q.Name, // queue
-							"",     // consumer
-							true,   // auto ack
-							false,  // exclusive
-							false,  // no local
-							false,  // no wait
-							nil,    // args
-						)
-						if err != nil {
-							monitor.logger.Error().Err(err).Msg("Failed to register a consumer.")
-						} else {
-							return queueConnection, queueChannel, messageChanel
-						}
-					}
-				}
-			}
+			monitor.logger.Error().Err(err).Msgf("Failed to bind a event - %v", eventToMonitor)
 		}
-		monitor.logger.Warn().Msgf("Cannot connect to RabbitMQ. Trying again ... Error %s", err.Error())
-		time.Sleep(5 * time.Second)
 	}
+
+	messageChanel, err := queueChannel.Consume(
+		q.Name,                         // queue
+		"",                             // consumer
+		monitor.consumerOptions == nil, // auto ack
+		false,                          // exclusive
+		false,                          // no local
+		false,                          // no wait
+		nil,                            // args
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register a consumer: %w", err)
+	}
+	return messageChanel, nil
 }
 
-func (monitor *rabbitMQEventMonitor) monitorQueueAndProcessMessages() {
-	for message := range monitor.messageChanel {
-		payload := string(message.Body)
-		token := ""
-		corelationID := ""
-		authorizationHeader, ok := message.Headers["X-Authorization"]
-		if ok {
-			token = authorizationHeader.(string)
-		}
-		corelationIDHeader, ok := message.Headers["X-Correlation-ID"]
-		if ok {
-			corelationID = corelationIDHeader.(string)
+func (monitor *rabbitMQEventMonitor) deadLetterExchange() string {
+	return monitor.connectionManager.Config().ExchangeName + ".dlx"
+}
+
+// declareQueues declares the queue to consume from. For acknowledging monitors, the retry queue, dead letter exchange and dead letter queue are declared as well.
+func (monitor *rabbitMQEventMonitor) declareQueues(queueChannel *amqp.Channel) (amqp.Queue, error) {
+	if monitor.consumerOptions == nil {
+		return queueChannel.QueueDeclare(
+			monitor.queueName, // name
+			false,             // durable
+			false,             // delete when unused
+			false,             // exclusive
+			false,             // no-wait
+			nil,               // arguments
+		)
+	}
+
+	durable := monitor.consumerOptions.Durable
+	deadLetterExchange := monitor.deadLetterExchange()
+	if err := queueChannel.ExchangeDeclare(deadLetterExchange, "direct", true, false, false, false, nil); err != nil {
+		return amqp.Queue{}, err
+	}
+	if _, err := queueChannel.QueueDeclare(monitor.queueName+".dlq", durable, false, false, false, nil); err != nil {
+		return amqp.Queue{}, err
+	}
+	if err := queueChannel.QueueBind(monitor.queueName+".dlq", monitor.queueName, deadLetterExchange, false, nil); err != nil {
+		return amqp.Queue{}, err
+	}
+
+	// Messages published to the retry queue are routed back to the queue once the retry delay elapses
+	if _, err := queueChannel.QueueDeclare(monitor.queueName+".retry", durable, false, false, false, amqp.Table{
+		"x-message-ttl":             int64(monitor.consumerOptions.RetryDelay / time.Millisecond),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": monitor.queueName,
+	}); err != nil {
+		return amqp.Queue{}, err
+	}
+
+	if monitor.consumerOptions.PrefetchCount > 0 {
+		if err := queueChannel.Qos(monitor.consumerOptions.PrefetchCount, 0, false); err != nil {
+			return amqp.Queue{}, err
 		}
+	}
 
+	if monitor.existingQueue {
+		return queueChannel.QueueDeclarePassive(monitor.queueName, durable, false, false, false, nil)
+	}
+	// Rejected messages are routed to the dead letter queue by the broker
+	queue, err := queueChannel.QueueDeclare(monitor.queueName, durable, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    deadLetterExchange,
+		"x-dead-letter-routing-key": monitor.queueName,
+	})
+	if amqpErr, ok := err.(*amqp.Error); ok && amqpErr.Code == amqp.PreconditionFailed {
+		return queue, fmt.Errorf("%w: %v", errQueueArgumentsMismatch, amqpErr)
+	}
+	return queue, err
+}
+
+func (monitor *rabbitMQEventMonitor) monitorQueueAndProcessMessages(queueChannel *amqp.Channel, messageChanel <-chan amqp.Delivery) {
+	for message := range messageChanel {
 		command := &EventInfo{
-			CorelationID: corelationID,
-			Payload:      payload,
-			RawToken:     token,
+			Payload:   string(message.Body),
+			MessageID: message.MessageId,
+			Timestamp: message.Timestamp,
+			Headers:   map[string]interface{}(message.Headers),
 
 			Name: message.RoutingKey,
 		}
+		command.RawToken = command.GetHeader(event.HeaderAuthorization)
+		command.CorelationID = command.GetHeader(event.HeaderCorrelationID)
+		if command.CorelationID == "" {
+			command.CorelationID = message.CorrelationId
+		}
+		command.TenantID = command.GetHeader(event.HeaderTenantID)
+		if err := command.decodeCloudEvent(message.ContentType, message.Body); err != nil {
+			monitor.logger.Warn().Err(err).Str("event", command.Name).Msg("Failed to decode the CloudEvent, using the message body as payload.")
+		}
+
+		if monitor.consumerOptions != nil {
+			monitor.setAcknowledgement(command, queueChannel, message)
+		}
+		if !conformsToSchema(monitor.logger, command) {
+			continue
+		}
 
 		monitor.eventSignal <- command
 	}
 }
 
-func (monitor *rabbitMQEventMonitor) Start() {
-	monitor.connectionCloseChannel <- amqp.ErrClosed // Trigger the connection
-}
-
-func (monitor *rabbitMQEventMonitor) Stop() {
-	monitor.queueChannel.Close()
-	monitor.queueConnection.Close()
-}
-
-func dialAMQP(connectionString string, isTLS bool) (*amqp.Connection, error) {
-	var cfg *tls.Config = nil
-	if isTLS {
-		caCert, _ := os.LookupEnv("ISLA_QUEUE_RMQ_CA_CERT")
-		clientCert, _ := os.LookupEnv("ISLA_QUEUE_RMQ_CERT")
-		clientCertKey, _ := os.LookupEnv("ISLA_QUEUE_RMQ_CERT_KEY")
+// setAcknowledgement sets the ack / nack callbacks of the event, the retry count and original routing key are restored from the headers of redelivered messages
+func (monitor *rabbitMQEventMonitor) setAcknowledgement(command *EventInfo, queueChannel publisher, message amqp.Delivery) {
+	if originalRoutingKey, ok := message.Headers[headerOriginalRoutingKey].(string); ok {
+		command.Name = originalRoutingKey
+	}
+	command.RetryCount = getRetryCount(message.Headers)
+	existingQueue := monitor.existingQueue
 
-		if strings.TrimSpace(caCert) == "" || strings.TrimSpace(clientCert) == "" || strings.TrimSpace(clientCertKey) == "" {
-			return nil, fmt.Errorf("One or more client certificates not found")
+	command.ack = func() error {
+		return message.Ack(false)
+	}
+	command.nack = func(requeue bool) error {
+		if !requeue || command.RetryCount >= monitor.consumerOptions.MaxRetries {
+			monitor.logger.Warn().Str("event", command.Name).Int("retryCount", command.RetryCount).Msg("Routing message to dead letter queue.")
+			if !existingQueue {
+				return message.Nack(false, false)
+			}
+			// The existing queue has no dead letter exchange, the broker would drop the rejected message
+			return monitor.republish(command, message, queueChannel, monitor.deadLetterExchange(), monitor.queueName, message.Headers)
 		}
 
-		cfg = &tls.Config{}
-		cfg.RootCAs = x509.NewCertPool()
-		cfg.RootCAs.AppendCertsFromPEM([]byte(caCert))
-
-		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientCertKey))
-		if err != nil {
-			return nil, err
+		headers := amqp.Table{}
+		for key, value := range message.Headers {
+			headers[key] = value
 		}
-		cfg.Certificates = append(cfg.Certificates, cert)
+		headers[headerRetryCount] = int32(command.RetryCount + 1)
+		headers[headerOriginalRoutingKey] = command.Name
+		return monitor.republish(command, message, queueChannel, "", monitor.queueName+".retry", headers)
 	}
+}
 
-	return amqp.DialTLS(connectionString, cfg)
+// republish publishes the message with the headers and acknowledges it, the message is requeued if it can not be published so that it is not lost
+func (monitor *rabbitMQEventMonitor) republish(command *EventInfo, message amqp.Delivery, queueChannel publisher, exchange string, key string, headers amqp.Table) error {
+	err := queueChannel.Publish(exchange, key, false, false, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   message.ContentType,
+		DeliveryMode:  message.DeliveryMode,
+		CorrelationId: message.CorrelationId,
+		MessageId:     message.MessageId,
+		Timestamp:     message.Timestamp,
+		Body:          message.Body,
+	})
+	if err != nil {
+		monitor.logger.Error().Err(err).Str("event", command.Name).Str("queue", key).Msg("Failed to publish message, requeuing.")
+		return message.Nack(false, true)
+	}
+	return message.Ack(false)
 }
 
-//TODO: read from config
-func getQueueConnectionString() (string, bool) {
-	var queueHost, queuePort, queueUser, queuePassword string
-	isTLS := false
-	queueProtocol := "amqp"
-	queueHost, ok := os.LookupEnv("ISLA_QUEUE_HOST")
-	if !ok {
-		queueHost = "localhost"
-	}
-	queuePassword, ok = os.LookupEnv("ISLA_QUEUE_PWD")
-	if !ok {
-		queuePassword = "guest"
-	}
-	queueUser, ok = os.LookupEnv("ISLA_QUEUE_USER")
-	if !ok {
-		queueUser = "guest"
-	}
-	queuePort, ok = os.LookupEnv("ISLA_QUEUE_PORT")
-	if !ok {
-		queuePort = "5672"
-	}
-	tls, ok := os.LookupEnv("ISLA_QUEUE_TLS_ENABLED")
-	if ok {
-		isTLS, _ = strconv.ParseBool(tls)
-		if isTLS {
-			queueProtocol = "amqps"
-		}
+func getRetryCount(headers amqp.Table) int {
+	switch retryCount := headers[headerRetryCount].(type) {
+	case int32:
+		return int(retryCount)
+	case int64:
+		return int(retryCount)
+	case int:
+		return retryCount
 	}
+	return 0
+}
 
-	return fmt.Sprintf("%v://%v:%v@%v:%v/", queueProtocol, queueUser, queuePassword, queueHost, queuePort), isTLS
+func (monitor *rabbitMQEventMonitor) Start() {
+	go monitor.consume()
+}
+
+func (monitor *rabbitMQEventMonitor) IsConnected() bool {
+	return atomic.LoadInt32(&monitor.connected) == 1
+}
+
+// Stop closes the channel of the monitor, the connection is closed by the connection manager
+func (monitor *rabbitMQEventMonitor) Stop() {
+	monitor.connectionMutex.Lock()
+	defer monitor.connectionMutex.Unlock()
+
+	monitor.stopped = true
+	monitor.cancel()
+	if monitor.queueChannel != nil {
+		monitor.queueChannel.Close()
+	}
 }
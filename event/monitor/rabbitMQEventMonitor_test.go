@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/event/rabbitmq"
+	"github.com/rs/zerolog"
+	"github.com/streadway/amqp"
+)
+
+type fakeAcknowledger struct {
+	acks     int
+	nacks    int
+	requeued bool
+}
+
+func (acknowledger *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	acknowledger.acks++
+	return nil
+}
+
+func (acknowledger *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	acknowledger.nacks++
+	acknowledger.requeued = requeue
+	return nil
+}
+
+func (acknowledger *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return acknowledger.Nack(tag, false, requeue)
+}
+
+type publishedMessage struct {
+	exchange string
+	key      string
+	msg      amqp.Publishing
+}
+
+type fakePublisher struct {
+	published []publishedMessage
+	err       error
+}
+
+func (publisher *fakePublisher) Publish(exchange string, key string, mandatory bool, immediate bool, msg amqp.Publishing) error {
+	if publisher.err != nil {
+		return publisher.err
+	}
+	publisher.published = append(publisher.published, publishedMessage{exchange: exchange, key: key, msg: msg})
+	return nil
+}
+
+func newTestRabbitMQEventMonitor(t *testing.T) *rabbitMQEventMonitor {
+	logger := zerolog.Nop()
+	connectionManager := rabbitmq.NewConnectionManager(&rabbitmq.Config{ExchangeName: "test_exchange"}, &logger)
+	t.Cleanup(func() { connectionManager.Close() })
+	eventMonitor, err := newRabbitMQEventMonitor(&logger, connectionManager, "test_queue", []string{"tenant.*"}, make(chan *EventInfo), &ConsumerOptions{MaxRetries: 2, RetryDelay: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eventMonitor.(*rabbitMQEventMonitor)
+}
+
+func newTestDelivery(acknowledger amqp.Acknowledger, headers amqp.Table) amqp.Delivery {
+	return amqp.Delivery{Acknowledger: acknowledger, RoutingKey: "tenant.added", MessageId: "1", Headers: headers, Body: []byte(`{}`)}
+}
+
+func TestRabbitMQEventMonitorAck(t *testing.T) {
+	eventMonitor := newTestRabbitMQEventMonitor(t)
+	acknowledger, publisher := &fakeAcknowledger{}, &fakePublisher{}
+	eventInfo := &EventInfo{Name: "tenant.added"}
+	eventMonitor.setAcknowledgement(eventInfo, publisher, newTestDelivery(acknowledger, nil))
+
+	if err := eventInfo.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if acknowledger.acks != 1 || acknowledger.nacks != 0 || len(publisher.published) != 0 {
+		t.Errorf("Expected message to be acknowledged, Actual [acks: %v, nacks: %v, published: %v]", acknowledger.acks, acknowledger.nacks, len(publisher.published))
+	}
+}
+
+func TestRabbitMQEventMonitorNackRoutesToRetryQueue(t *testing.T) {
+	eventMonitor := newTestRabbitMQEventMonitor(t)
+	acknowledger, publisher := &fakeAcknowledger{}, &fakePublisher{}
+	eventInfo := &EventInfo{Name: "tenant.added"}
+	eventMonitor.setAcknowledgement(eventInfo, publisher, newTestDelivery(acknowledger, amqp.Table{"X-Custom": "value"}))
+
+	if err := eventInfo.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 1 {
+		t.Fatalf("Expected message to be published to retry queue, Actual [%v]", len(publisher.published))
+	}
+	published := publisher.published[0]
+	if published.exchange != "" || published.key != "test_queue.retry" {
+		t.Errorf("Expected message to be published to [test_queue.retry], Actual [%v / %v]", published.exchange, published.key)
+	}
+	if published.msg.Headers[headerRetryCount] != int32(1) || published.msg.Headers[headerOriginalRoutingKey] != "tenant.added" || published.msg.Headers["X-Custom"] != "value" {
+		t.Errorf("Expected retry count, original routing key and headers of the message, Actual [%v]", published.msg.Headers)
+	}
+	if acknowledger.acks != 1 || acknowledger.nacks != 0 {
+		t.Errorf("Expected original message to be acknowledged once retried, Actual [acks: %v, nacks: %v]", acknowledger.acks, acknowledger.nacks)
+	}
+
+	// The redelivered message carries the retry count and the original routing key
+	redelivered := newTestDelivery(&fakeAcknowledger{}, published.msg.Headers)
+	redelivered.RoutingKey = "test_queue"
+	redeliveredEventInfo := &EventInfo{Name: redelivered.RoutingKey}
+	eventMonitor.setAcknowledgement(redeliveredEventInfo, publisher, redelivered)
+	if redeliveredEventInfo.RetryCount != 1 || redeliveredEventInfo.Name != "tenant.added" {
+		t.Errorf("Expected retry count [1] and name [tenant.added], Actual [%v, %v]", redeliveredEventInfo.RetryCount, redeliveredEventInfo.Name)
+	}
+}
+
+func TestRabbitMQEventMonitorNackRequeuesIfRetryCanNotBePublished(t *testing.T) {
+	eventMonitor := newTestRabbitMQEventMonitor(t)
+	acknowledger, publisher := &fakeAcknowledger{}, &fakePublisher{err: errors.New("channel closed")}
+	eventInfo := &EventInfo{Name: "tenant.added"}
+	eventMonitor.setAcknowledgement(eventInfo, publisher, newTestDelivery(acknowledger, nil))
+
+	eventInfo.Nack(true)
+	if acknowledger.acks != 0 || acknowledger.nacks != 1 || !acknowledger.requeued {
+		t.Errorf("Expected message to be requeued, Actual [acks: %v, nacks: %v, requeued: %v]", acknowledger.acks, acknowledger.nacks, acknowledger.requeued)
+	}
+}
+
+func TestRabbitMQEventMonitorNackRoutesToDeadLetterQueue(t *testing.T) {
+	nackCombinations := []struct {
+		name       string
+		retryCount int32
+		requeue    bool
+	}{
+		{"without requeue", 0, false},
+		{"after max retries", 2, true},
+	}
+	for _, combination := range nackCombinations {
+		t.Run(combination.name, func(t *testing.T) {
+			eventMonitor := newTestRabbitMQEventMonitor(t)
+			acknowledger, publisher := &fakeAcknowledger{}, &fakePublisher{}
+			eventInfo := &EventInfo{Name: "tenant.added"}
+			eventMonitor.setAcknowledgement(eventInfo, publisher, newTestDelivery(acknowledger, amqp.Table{headerRetryCount: combination.retryCount}))
+
+			eventInfo.Nack(combination.requeue)
+			if acknowledger.nacks != 1 || acknowledger.requeued || len(publisher.published) != 0 {
+				t.Errorf("Expected message to be rejected to dead letter queue, Actual [nacks: %v, requeued: %v, published: %v]", acknowledger.nacks, acknowledger.requeued, len(publisher.published))
+			}
+		})
+	}
+}
+
+func TestRabbitMQEventMonitorNackPublishesToDeadLetterExchangeForExistingQueue(t *testing.T) {
+	eventMonitor := newTestRabbitMQEventMonitor(t)
+	eventMonitor.existingQueue = true
+	acknowledger, publisher := &fakeAcknowledger{}, &fakePublisher{}
+	eventInfo := &EventInfo{Name: "tenant.added"}
+	eventMonitor.setAcknowledgement(eventInfo, publisher, newTestDelivery(acknowledger, nil))
+
+	eventInfo.Nack(false)
+	if len(publisher.published) != 1 || publisher.published[0].exchange != "test_exchange.dlx" || publisher.published[0].key != "test_queue" {
+		t.Fatalf("Expected message to be published to dead letter exchange, Actual [%v]", publisher.published)
+	}
+	if acknowledger.acks != 1 || acknowledger.nacks != 0 {
+		t.Errorf("Expected original message to be acknowledged, Actual [acks: %v, nacks: %v]", acknowledger.acks, acknowledger.nacks)
+	}
+}
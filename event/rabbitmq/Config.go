@@ -0,0 +1,132 @@
+package rabbitmq
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/islax/microapp/config"
+)
+
+// Config represents the configuration of the connection to RabbitMQ and of the exchange to which the events are published
+type Config struct {
+	Hosts        []string // host:port of the brokers, the next host is tried when the connection to a host fails
+	User         string
+	Password     string
+	VirtualHost  string
+	ExchangeName string
+	ExchangeType string
+	Heartbeat    time.Duration
+
+	TLSEnabled     bool
+	CACert         string // PEM encoded CA certificate, takes precedence over CACertFile
+	ClientCert     string // PEM encoded client certificate, takes precedence over ClientCertFile
+	ClientKey      string // PEM encoded client private key, takes precedence over ClientKeyFile
+	CACertFile     string
+	ClientCertFile string
+	ClientKeyFile  string
+
+	ReconnectInitialDelay time.Duration // Delay after all the hosts fail to connect, doubled on every subsequent failure
+	ReconnectMaxDelay     time.Duration // Maximum delay between the connection attempts
+
+	SpoolPath string // File to which the events which could not be published are written, along with their bearer tokens
+}
+
+// NewConfig creates the RabbitMQ configuration from the app config. QUEUE_HOST can be a comma separated list of host[:port],
+// QUEUE_PORT is used for the hosts without port.
+func NewConfig(appConfig *config.Config) *Config {
+	port := appConfig.GetString(config.EvSuffixForQueuePort)
+	var hosts []string
+	for _, host := range strings.Split(appConfig.GetString(config.EvSuffixForQueueHost), ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if !strings.Contains(host, ":") {
+			host = host + ":" + port
+		}
+		hosts = append(hosts, host)
+	}
+
+	spoolPath := appConfig.GetString(config.EvSuffixForQueueSpoolPath)
+	if strings.TrimSpace(spoolPath) == "" {
+		spoolPath = filepath.Join(os.TempDir(), "microapp-events.spool")
+	}
+
+	return &Config{
+		Hosts:                 hosts,
+		User:                  appConfig.GetString(config.EvSuffixForQueueUser),
+		Password:              appConfig.GetString(config.EvSuffixForQueuePassword),
+		VirtualHost:           appConfig.GetString(config.EvSuffixForQueueVirtualHost),
+		ExchangeName:          appConfig.GetString(config.EvSuffixForQueueExchange),
+		ExchangeType:          appConfig.GetString(config.EvSuffixForQueueExchangeType),
+		Heartbeat:             time.Duration(appConfig.GetInt(config.EvSuffixForQueueHeartbeat)) * time.Second,
+		TLSEnabled:            appConfig.GetBool(config.EvSuffixForQueueTLSEnabled),
+		CACert:                appConfig.GetString(config.EvSuffixForQueueCACert),
+		ClientCert:            appConfig.GetString(config.EvSuffixForQueueClientCert),
+		ClientKey:             appConfig.GetString(config.EvSuffixForQueueClientKey),
+		CACertFile:            appConfig.GetString(config.EvSuffixForQueueCACertFile),
+		ClientCertFile:        appConfig.GetString(config.EvSuffixForQueueClientCertFile),
+		ClientKeyFile:         appConfig.GetString(config.EvSuffixForQueueClientKeyFile),
+		ReconnectInitialDelay: time.Duration(appConfig.GetInt(config.EvSuffixForQueueReconnectInitialDelay)) * time.Millisecond,
+		ReconnectMaxDelay:     time.Duration(appConfig.GetInt(config.EvSuffixForQueueReconnectMaxDelay)) * time.Millisecond,
+		SpoolPath:             spoolPath,
+	}
+}
+
+// url returns the connection url of the given host
+func (cfg *Config) url(host string) string {
+	scheme := "amqp"
+	if cfg.TLSEnabled {
+		scheme = "amqps"
+	}
+	return (&url.URL{Scheme: scheme, User: url.UserPassword(cfg.User, cfg.Password), Host: host, Path: "/"}).String()
+}
+
+// tlsConfig returns the client TLS configuration, nil if TLS is not enabled
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
+	if !cfg.TLSEnabled {
+		return nil, nil
+	}
+
+	caCert, err := pemOrFile(cfg.CACert, cfg.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCert, err := pemOrFile(cfg.ClientCert, cfg.ClientCertFile)
+	if err != nil {
+		return nil, err
+	}
+	clientKey, err := pemOrFile(cfg.ClientKey, cfg.ClientKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(caCert) == 0 || len(clientCert) == 0 || len(clientKey) == 0 {
+		return nil, errors.New("One or more client certificates not found")
+	}
+
+	tlsConfig := &tls.Config{RootCAs: x509.NewCertPool()}
+	tlsConfig.RootCAs.AppendCertsFromPEM(caCert)
+	cert, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	return tlsConfig, nil
+}
+
+func pemOrFile(pem string, filePath string) ([]byte, error) {
+	if strings.TrimSpace(pem) != "" {
+		return []byte(pem), nil
+	}
+	if strings.TrimSpace(filePath) == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(filePath)
+}
@@ -0,0 +1,21 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/config"
+)
+
+func TestNewConfigReconnectDelays(t *testing.T) {
+	appConfig := config.NewConfig(nil)
+	if cfg := NewConfig(appConfig); cfg.ReconnectInitialDelay != 500*time.Millisecond || cfg.ReconnectMaxDelay != 30*time.Second {
+		t.Errorf("Expected default delays [500ms, 30s], Actual [%v, %v]", cfg.ReconnectInitialDelay, cfg.ReconnectMaxDelay)
+	}
+
+	appConfig.Set(config.EvSuffixForQueueReconnectInitialDelay, 250)
+	appConfig.Set(config.EvSuffixForQueueReconnectMaxDelay, 5000)
+	if cfg := NewConfig(appConfig); cfg.ReconnectInitialDelay != 250*time.Millisecond || cfg.ReconnectMaxDelay != 5*time.Second {
+		t.Errorf("Expected both delays to be read in milliseconds [250ms, 5s], Actual [%v, %v]", cfg.ReconnectInitialDelay, cfg.ReconnectMaxDelay)
+	}
+}
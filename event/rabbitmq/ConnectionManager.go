@@ -0,0 +1,250 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/rs/zerolog"
+	"github.com/streadway/amqp"
+)
+
+// ConnectionManager maintains a single connection to RabbitMQ which is shared by the event dispatcher and the event monitors.
+// Each of them opens its own channel on the connection and opens it again once the connection is re-established.
+type ConnectionManager struct {
+	config          *Config
+	logger          *zerolog.Logger
+	mutex           sync.Mutex
+	connection      *amqp.Connection
+	connectedSignal chan struct{} // closed once the connection is established, replaced when the connection is lost
+	closed          chan struct{}
+	closeOnce       sync.Once
+	connected       int32
+	hostIndex       int
+}
+
+var (
+	defaultConnectionManager      *ConnectionManager
+	defaultConnectionManagerMutex sync.Mutex
+)
+
+// NewConnectionManager creates a new connection manager and starts connecting to RabbitMQ in background
+func NewConnectionManager(cfg *Config, logger *zerolog.Logger) *ConnectionManager {
+	cfgWithDefaults := *cfg
+	if cfgWithDefaults.ExchangeName == "" {
+		cfgWithDefaults.ExchangeName = "isla_exchange"
+	}
+	if cfgWithDefaults.ExchangeType == "" {
+		cfgWithDefaults.ExchangeType = amqp.ExchangeTopic
+	}
+	if cfgWithDefaults.ReconnectInitialDelay <= 0 {
+		cfgWithDefaults.ReconnectInitialDelay = 500 * time.Millisecond
+	}
+	if cfgWithDefaults.ReconnectMaxDelay < cfgWithDefaults.ReconnectInitialDelay {
+		cfgWithDefaults.ReconnectMaxDelay = cfgWithDefaults.ReconnectInitialDelay
+	}
+
+	manager := newConnectionManager(&cfgWithDefaults, logger)
+	go manager.maintainConnection()
+	return manager
+}
+
+func newConnectionManager(cfg *Config, logger *zerolog.Logger) *ConnectionManager {
+	ctxLogger := logger.With().Str("module", "RabbitMQConnectionManager").Logger()
+	return &ConnectionManager{
+		config:          cfg,
+		logger:          &ctxLogger,
+		connectedSignal: make(chan struct{}),
+		closed:          make(chan struct{}),
+	}
+}
+
+// SetDefaultConnectionManager sets the connection manager used by the event dispatcher and event monitors created without a connection manager
+func SetDefaultConnectionManager(manager *ConnectionManager) {
+	defaultConnectionManagerMutex.Lock()
+	defer defaultConnectionManagerMutex.Unlock()
+	defaultConnectionManager = manager
+}
+
+// DefaultConnectionManager returns the default connection manager, if not set, it is created using the ISLA_QUEUE_* environment variables
+func DefaultConnectionManager() *ConnectionManager {
+	defaultConnectionManagerMutex.Lock()
+	defer defaultConnectionManagerMutex.Unlock()
+	if defaultConnectionManager == nil {
+		logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+		defaultConnectionManager = NewConnectionManager(NewConfig(config.NewConfig(nil)), &logger)
+	}
+	return defaultConnectionManager
+}
+
+// Config returns the configuration of the connection manager
+func (manager *ConnectionManager) Config() *Config {
+	return manager.config
+}
+
+// IsConnected returns whether the connection to RabbitMQ is established
+func (manager *ConnectionManager) IsConnected() bool {
+	return atomic.LoadInt32(&manager.connected) == 1
+}
+
+// WaitUntilConnected blocks till the connection is established, the context is done or the manager is closed
+func (manager *ConnectionManager) WaitUntilConnected(ctx context.Context) error {
+	manager.mutex.Lock()
+	connectedSignal := manager.connectedSignal
+	manager.mutex.Unlock()
+
+	select {
+	case <-connectedSignal:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-manager.closed:
+		return amqp.ErrClosed
+	}
+}
+
+// Channel opens a new channel on the connection, blocking till the connection is established.
+// amqp.ErrClosed is returned once the manager is closed. The caller should watch Channel.NotifyClose and open a new channel when it is closed.
+func (manager *ConnectionManager) Channel(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		manager.mutex.Lock()
+		connection, connectedSignal := manager.connection, manager.connectedSignal
+		manager.mutex.Unlock()
+
+		if connection != nil {
+			channel, err := connection.Channel()
+			if err == nil {
+				return channel, nil
+			}
+			// The connection is being closed, wait for it to be re-established
+			manager.logger.Debug().Err(err).Msg("Failed to open a channel, waiting for the connection to be re-established.")
+			connectedSignal = nil
+		}
+
+		select {
+		case <-connectedSignal:
+		case <-time.After(manager.config.ReconnectInitialDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-manager.closed:
+			return nil, amqp.ErrClosed
+		}
+	}
+}
+
+// Close closes the connection, the channels opened on it are closed as well
+func (manager *ConnectionManager) Close() error {
+	var err error
+	manager.closeOnce.Do(func() {
+		manager.mutex.Lock()
+		defer manager.mutex.Unlock()
+		close(manager.closed)
+		atomic.StoreInt32(&manager.connected, 0)
+		if manager.connection != nil {
+			err = manager.connection.Close()
+			manager.connection = nil
+		}
+	})
+	return err
+}
+
+func (manager *ConnectionManager) isClosed() bool {
+	select {
+	case <-manager.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// maintainConnection connects to RabbitMQ and reconnects when the connection is lost, till the manager is closed
+func (manager *ConnectionManager) maintainConnection() {
+	delay := manager.config.ReconnectInitialDelay
+	for !manager.isClosed() {
+		connection, err := manager.connect()
+		if err != nil {
+			manager.logger.Warn().Err(err).Dur("retryIn", delay).Msg("Cannot connect to RabbitMQ. Trying again...")
+			select {
+			case <-time.After(delay):
+			case <-manager.closed:
+				return
+			}
+			delay = manager.nextDelay(delay)
+			continue
+		}
+		delay = manager.config.ReconnectInitialDelay
+
+		connectionClosed := connection.NotifyClose(make(chan *amqp.Error, 1))
+		if !manager.setConnected(connection) {
+			connection.Close()
+			return
+		}
+
+		if err := <-connectionClosed; err != nil {
+			manager.logger.Warn().Err(err).Msg("Connection to RabbitMQ lost, reconnecting.")
+		}
+		manager.setDisconnected()
+	}
+}
+
+// nextDelay returns the delay before the next connection attempt, the delay is doubled on every failure up to ReconnectMaxDelay
+func (manager *ConnectionManager) nextDelay(delay time.Duration) time.Duration {
+	if delay *= 2; delay > manager.config.ReconnectMaxDelay {
+		delay = manager.config.ReconnectMaxDelay
+	}
+	return delay
+}
+
+// setConnected sets the connection and notifies the callers waiting for the connection, false is returned if the manager is closed
+func (manager *ConnectionManager) setConnected(connection *amqp.Connection) bool {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.isClosed() {
+		return false
+	}
+	manager.connection = connection
+	atomic.StoreInt32(&manager.connected, 1)
+	close(manager.connectedSignal)
+	return true
+}
+
+// setDisconnected clears the lost connection, the callers waiting for the connection from now on are notified once it is re-established
+func (manager *ConnectionManager) setDisconnected() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	atomic.StoreInt32(&manager.connected, 0)
+	manager.connection = nil
+	manager.connectedSignal = make(chan struct{})
+}
+
+// connect tries the hosts one after the other starting with the last connected host
+func (manager *ConnectionManager) connect() (*amqp.Connection, error) {
+	if len(manager.config.Hosts) == 0 {
+		return nil, errors.New("no RabbitMQ host configured")
+	}
+	tlsConfig, err := manager.config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(manager.config.Hosts); i++ {
+		host := manager.config.Hosts[manager.hostIndex]
+		connection, dialErr := amqp.DialConfig(manager.config.url(host), amqp.Config{
+			Vhost:           manager.config.VirtualHost,
+			Heartbeat:       manager.config.Heartbeat,
+			TLSClientConfig: tlsConfig,
+		})
+		if dialErr == nil {
+			manager.logger.Info().Str("host", host).Bool("tls", manager.config.TLSEnabled).Msg("RabbitMQ connected.")
+			return connection, nil
+		}
+		manager.logger.Warn().Err(dialErr).Str("host", host).Msg("Unable to connect to RabbitMQ host.")
+		err = dialErr
+		manager.hostIndex = (manager.hostIndex + 1) % len(manager.config.Hosts)
+	}
+	return nil, err
+}
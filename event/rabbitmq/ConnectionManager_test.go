@@ -0,0 +1,81 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/streadway/amqp"
+)
+
+func TestConnectionManagerBackoff(t *testing.T) {
+	logger := zerolog.Nop()
+	manager := newConnectionManager(&Config{ReconnectInitialDelay: time.Second, ReconnectMaxDelay: 5 * time.Second}, &logger)
+
+	delay := manager.config.ReconnectInitialDelay
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		delay = manager.nextDelay(delay)
+		delays = append(delays, delay)
+	}
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Fatalf("Expected delays to double up to the max delay %v, Actual %v", expected, delays)
+		}
+	}
+}
+
+func TestConnectionManagerNotifiesWaitersOnReconnect(t *testing.T) {
+	logger := zerolog.Nop()
+	manager := newConnectionManager(&Config{ReconnectInitialDelay: time.Millisecond, ReconnectMaxDelay: time.Millisecond}, &logger)
+	wait := func() chan error {
+		result := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			result <- manager.WaitUntilConnected(ctx)
+		}()
+		return result
+	}
+	expectNotified := func(waiters ...chan error) {
+		t.Helper()
+		for _, waiter := range waiters {
+			if err := <-waiter; err != nil {
+				t.Errorf("Expected the waiter to be notified once connected, Actual [%v]", err)
+			}
+		}
+	}
+
+	waiters := []chan error{wait(), wait()}
+	time.Sleep(10 * time.Millisecond)
+	manager.setConnected(nil)
+	expectNotified(waiters...)
+	if !manager.IsConnected() {
+		t.Error("Expected the manager to be connected, Actual [disconnected]")
+	}
+
+	manager.setDisconnected()
+	if manager.IsConnected() {
+		t.Error("Expected the manager to be disconnected once the connection is lost, Actual [connected]")
+	}
+	waiter := wait()
+	select {
+	case err := <-waiter:
+		t.Fatalf("Expected the waiter to block till the connection is re-established, Actual [%v]", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	manager.setConnected(nil)
+	expectNotified(waiter)
+
+	manager.setDisconnected()
+	waiter = wait()
+	manager.Close()
+	if err := <-waiter; err != amqp.ErrClosed {
+		t.Errorf("Expected the waiter to be released once the manager is closed, Actual [%v]", err)
+	}
+	if manager.setConnected(nil) {
+		t.Error("Expected the connection not to be set once the manager is closed")
+	}
+}
@@ -0,0 +1,257 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"runtime/debug"
+	"sync"
+
+	"github.com/islax/microapp"
+	"github.com/islax/microapp/config"
+	microappCtx "github.com/islax/microapp/context"
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/event/idempotency"
+	"github.com/islax/microapp/event/monitor"
+	microappLog "github.com/islax/microapp/log"
+	microappSecurity "github.com/islax/microapp/security"
+	"github.com/rs/zerolog"
+)
+
+const workerQueueSize = 64
+
+// errInvalidPayload indicates that the payload could not be decoded, such events are not retried
+var errInvalidPayload = errors.New("invalid event payload")
+
+// Handler handles an event. If the app has a database, the unit of work of the context is committed if the handler returns nil, otherwise it is rolled back.
+type Handler func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo) error
+
+// PayloadHandler handles an event whose payload is decoded into a new instance of the type registered with the handler, payload is a pointer to the instance
+type PayloadHandler func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo, payload interface{}) error
+
+type route struct {
+	pattern     string
+	action      string
+	payloadType reflect.Type
+	handler     PayloadHandler
+}
+
+// Router routes the events received from the event monitors to the handlers registered for their routing keys.
+// Events are handled concurrently by a pool of workers, events with the same ordering key are handled by the same worker in the order they are received.
+// An event matching several routes is acknowledged once all of them succeed. If any of them fails, the event is nacked and the routes which succeeded,
+// and committed their unit of work, handle it again on redelivery. Either keep the handlers of such routes idempotent or set a processed message store,
+// which skips the redelivered event for the routes that already handled it.
+type Router struct {
+	app         *microapp.App
+	logger      *zerolog.Logger
+	routes      []*route
+	orderingKey func(eventInfo *monitor.EventInfo) string
+	processed   idempotency.ProcessedMessageStore
+	workers     []chan *monitor.EventInfo
+	workerWG    sync.WaitGroup
+	startOnce   sync.Once
+	stopOnce    sync.Once
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// NewRouter creates a new event router, the number of workers is read from EVENT_ROUTER_WORKERS config
+func NewRouter(app *microapp.App) *Router {
+	workers := app.Config.GetInt(config.EvSuffixForEventRouterWorkers)
+	if workers < 1 {
+		workers = 1
+	}
+	router := &Router{
+		app:     app,
+		logger:  app.Logger("EventRouter"),
+		workers: make([]chan *monitor.EventInfo, workers),
+		orderingKey: func(eventInfo *monitor.EventInfo) string {
+			return event.NormalizeTopic(eventInfo.Name)
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	return router
+}
+
+// SetProcessedMessageStore enables deduplication of the events by message id, the handler of a route is executed once per message id.
+// The action of the route is used as the consumer name while recording the processed messages.
+func (router *Router) SetProcessedMessageStore(store idempotency.ProcessedMessageStore) {
+	router.processed = store
+}
+
+// SetOrderingKey overrides the key used to order the events, by default events are ordered per routing key
+func (router *Router) SetOrderingKey(orderingKey func(eventInfo *monitor.EventInfo) string) {
+	router.orderingKey = orderingKey
+}
+
+// Handle registers the handler for the routing key pattern, pattern supports '*' and '#' wildcards as per topic exchange semantics.
+// Action is used as the action name of the execution context.
+func (router *Router) Handle(pattern string, action string, handler Handler) {
+	router.routes = append(router.routes, &route{pattern: pattern, action: action, handler: func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo, payload interface{}) error {
+		return handler(context, eventInfo)
+	}})
+}
+
+// HandleWithPayload registers the handler for the routing key pattern, the payload of the event is decoded into a new instance of the type of payloadPrototype.
+// Events whose payload can not be decoded are rejected without requeue.
+func (router *Router) HandleWithPayload(pattern string, action string, payloadPrototype interface{}, handler PayloadHandler) {
+	payloadType := reflect.TypeOf(payloadPrototype)
+	if payloadType.Kind() == reflect.Ptr {
+		payloadType = payloadType.Elem()
+	}
+	router.routes = append(router.routes, &route{pattern: pattern, action: action, payloadType: payloadType, handler: handler})
+}
+
+// Start starts the workers and routes the events received on the channel till the channel is closed or the router is stopped.
+// Start blocks, the router registers itself to be stopped along with the app.
+func (router *Router) Start(eventChannel <-chan *monitor.EventInfo) {
+	router.startOnce.Do(func() {
+		router.app.OnStop("event-router", microapp.LifecyclePriorityWorker, 0, router.Stop)
+		for i := range router.workers {
+			router.workers[i] = make(chan *monitor.EventInfo, workerQueueSize)
+			router.workerWG.Add(1)
+			go router.work(router.workers[i])
+		}
+
+		defer close(router.done)
+		defer router.closeWorkers()
+		for {
+			select {
+			case <-router.stop:
+				return
+			case eventInfo, ok := <-eventChannel:
+				if !ok {
+					return
+				}
+				router.workers[router.workerIndex(eventInfo)] <- eventInfo
+			}
+		}
+	})
+}
+
+// Stop stops routing new events and waits till the events already routed are handled or the context is done
+func (router *Router) Stop(ctx context.Context) error {
+	router.stopOnce.Do(func() {
+		close(router.stop)
+	})
+	select {
+	case <-router.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (router *Router) closeWorkers() {
+	for _, worker := range router.workers {
+		close(worker)
+	}
+	router.workerWG.Wait()
+}
+
+func (router *Router) workerIndex(eventInfo *monitor.EventInfo) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(router.orderingKey(eventInfo)))
+	return int(hash.Sum32() % uint32(len(router.workers)))
+}
+
+func (router *Router) work(events <-chan *monitor.EventInfo) {
+	defer router.workerWG.Done()
+	for eventInfo := range events {
+		router.route(eventInfo)
+	}
+}
+
+// route handles the event by all the matching routes, the message is acknowledged once for all of them as the broker tracks the message and not the routes.
+// A failing route does not prevent the remaining routes from handling the event, the message is then nacked and requeued unless all the failures are invalid payloads.
+func (router *Router) route(eventInfo *monitor.EventInfo) {
+	handled, failed, requeue := false, false, false
+	for _, route := range router.routes {
+		if event.MatchTopic(route.pattern, eventInfo.Name) {
+			handled = true
+			if err := router.handle(route, eventInfo); err != nil {
+				failed = true
+				requeue = requeue || err != errInvalidPayload
+			}
+		}
+	}
+	if !handled {
+		router.logger.Debug().Str("event", eventInfo.Name).Msg("No handler registered for the event.")
+	}
+	if failed {
+		eventInfo.Nack(requeue)
+		return
+	}
+	eventInfo.Ack()
+}
+
+func (router *Router) handle(route *route, eventInfo *monitor.EventInfo) (err error) {
+	token, tokenErr := microappSecurity.GetTokenFromRawAuthHeader(router.app.Config, eventInfo.RawToken)
+	if tokenErr != nil && eventInfo.RawToken != "" {
+		router.logger.Warn().Err(tokenErr).Str("event", eventInfo.Name).Msg("Unable to parse the token of the event.")
+	}
+	context := router.app.NewExecutionContext(token, eventInfo.CorelationID, route.action, router.app.DB != nil, false)
+	uow := context.GetUOW()
+	if uow != nil {
+		defer uow.Complete()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while handling event: %v", r)
+			context.Logger(microappLog.EventTypeUnexpectedErr, microappLog.EventCodeUnknown).Error().Str("stack", string(debug.Stack())).Err(err).Str("event", eventInfo.Name).Msg("Recovered from panic while handling the event.")
+		}
+	}()
+
+	completed := false
+	if router.processed != nil && eventInfo.MessageID != "" {
+		isNew, markErr := router.processed.TryMarkProcessed(uow, route.action, eventInfo.MessageID)
+		if markErr != nil {
+			return markErr
+		}
+		if !isNew {
+			context.GetDefaultLogger().Debug().Str("event", eventInfo.Name).Str("messageId", eventInfo.MessageID).Msg("Skipping the event as it is already processed.")
+			return nil
+		}
+		// Unmark if the handler fails or panics, so that the event is handled again on redelivery
+		defer func() {
+			if !completed {
+				if unmarkErr := router.processed.Unmark(uow, route.action, eventInfo.MessageID); unmarkErr != nil {
+					context.GetDefaultLogger().Warn().Err(unmarkErr).Str("messageId", eventInfo.MessageID).Msg("Failed to unmark the processed event.")
+				}
+			}
+		}()
+	}
+
+	var payload interface{}
+	if route.payloadType != nil {
+		payload = reflect.New(route.payloadType).Interface()
+		if err := json.Unmarshal([]byte(eventInfo.Payload), payload); err != nil {
+			context.LogJSONParseError(err)
+			return errInvalidPayload
+		}
+	}
+
+	if err = route.handler(context, eventInfo, payload); err != nil {
+		context.GetDefaultLogger().Warn().Err(err).Str("event", eventInfo.Name).Msg("Failed to handle the event.")
+		return err
+	}
+	if uow != nil {
+		if err = uow.Commit(); err != nil {
+			context.GetDefaultLogger().Warn().Err(err).Str("event", eventInfo.Name).Msg("Failed to commit the event.")
+			return err
+		}
+	}
+	completed = true
+	if router.processed != nil && eventInfo.MessageID != "" {
+		// The event is handled, if the mark can not be completed it expires and a redelivery is handled again
+		if markErr := router.processed.MarkCompleted(uow, route.action, eventInfo.MessageID); markErr != nil {
+			context.GetDefaultLogger().Warn().Err(markErr).Str("messageId", eventInfo.MessageID).Msg("Failed to mark the event as processed.")
+		}
+	}
+	return nil
+}
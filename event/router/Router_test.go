@@ -0,0 +1,189 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/islax/microapp"
+	"github.com/islax/microapp/config"
+	microappCtx "github.com/islax/microapp/context"
+	"github.com/islax/microapp/event/idempotency"
+	"github.com/islax/microapp/event/monitor"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// acknowledgement records the acks and nacks of an event
+type acknowledgement struct {
+	mutex sync.Mutex
+	acks  int
+	nacks []bool
+}
+
+func (ack *acknowledgement) counts() (int, []bool) {
+	ack.mutex.Lock()
+	defer ack.mutex.Unlock()
+	return ack.acks, ack.nacks
+}
+
+func newTestEvent(name string, payload string, messageID string) (*monitor.EventInfo, *acknowledgement) {
+	ack := &acknowledgement{}
+	eventInfo := &monitor.EventInfo{Name: name, Payload: payload, MessageID: messageID}
+	eventInfo.SetAcknowledgement(func() error {
+		ack.mutex.Lock()
+		defer ack.mutex.Unlock()
+		ack.acks++
+		return nil
+	}, func(requeue bool) error {
+		ack.mutex.Lock()
+		defer ack.mutex.Unlock()
+		ack.nacks = append(ack.nacks, requeue)
+		return nil
+	})
+	return eventInfo, ack
+}
+
+func newTestRouter(db *gorm.DB) *Router {
+	return NewRouter(microapp.New("test", map[string]interface{}{config.EvSuffixForEventRouterWorkers: 4}, zerolog.Nop(), db, nil, nil))
+}
+
+// routeEvents routes the events and waits till all of them are handled
+func routeEvents(router *Router, events ...*monitor.EventInfo) {
+	eventChannel := make(chan *monitor.EventInfo)
+	routed := make(chan struct{})
+	go func() {
+		defer close(routed)
+		router.Start(eventChannel)
+	}()
+	for _, eventInfo := range events {
+		eventChannel <- eventInfo
+	}
+	close(eventChannel)
+	<-routed
+}
+
+func TestRouterOrdersEventsPerKey(t *testing.T) {
+	router := newTestRouter(nil)
+	var mutex sync.Mutex
+	handled := make(map[string][]int)
+	router.Handle("order.*", "test", func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo) error {
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+		sequence, _ := strconv.Atoi(eventInfo.Payload)
+		mutex.Lock()
+		defer mutex.Unlock()
+		handled[eventInfo.Name] = append(handled[eventInfo.Name], sequence)
+		return nil
+	})
+
+	var events []*monitor.EventInfo
+	for sequence := 0; sequence < 20; sequence++ {
+		for key := 0; key < 8; key++ {
+			eventInfo, _ := newTestEvent(fmt.Sprintf("order.%v", key), strconv.Itoa(sequence), "")
+			events = append(events, eventInfo)
+		}
+	}
+	routeEvents(router, events...)
+
+	if len(handled) != 8 {
+		t.Fatalf("Expected events of [8] keys to be handled, Actual [%v]", len(handled))
+	}
+	for key, sequences := range handled {
+		for i, sequence := range sequences {
+			if sequence != i {
+				t.Errorf("Expected events of [%v] to be handled in order, Actual %v", key, sequences)
+				break
+			}
+		}
+	}
+}
+
+func TestRouterNacksEventOnPanic(t *testing.T) {
+	router := newTestRouter(nil)
+	router.Handle("tenant.created", "test", func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo) error {
+		panic("handler failed")
+	})
+
+	eventInfo, ack := newTestEvent("tenant.created", "{}", "")
+	routeEvents(router, eventInfo)
+
+	if acks, nacks := ack.counts(); acks != 0 || len(nacks) != 1 || !nacks[0] {
+		t.Errorf("Expected the event to be nacked with requeue, Actual acks [%v] nacks %v", acks, nacks)
+	}
+}
+
+func TestRouterAcknowledgesEventOnceForAllRoutes(t *testing.T) {
+	router := newTestRouter(nil)
+	var mutex sync.Mutex
+	handled := make(map[string]int)
+	handler := func(action string, err error) Handler {
+		return func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			handled[action+":"+eventInfo.Name]++
+			if eventInfo.Name == "tenant.deleted" {
+				return err
+			}
+			return nil
+		}
+	}
+	router.Handle("tenant.*", "first", handler("first", errors.New("first failed")))
+	router.Handle("tenant.#", "second", handler("second", nil))
+
+	created, createdAck := newTestEvent("tenant.created", "{}", "")
+	deleted, deletedAck := newTestEvent("tenant.deleted", "{}", "")
+	routeEvents(router, created, deleted)
+
+	if acks, nacks := createdAck.counts(); acks != 1 || len(nacks) != 0 {
+		t.Errorf("Expected the event handled by all the routes to be acked once, Actual acks [%v] nacks %v", acks, nacks)
+	}
+	if acks, nacks := deletedAck.counts(); acks != 0 || len(nacks) != 1 || !nacks[0] {
+		t.Errorf("Expected the event failed by a route to be nacked once with requeue, Actual acks [%v] nacks %v", acks, nacks)
+	}
+	for _, key := range []string{"first:tenant.created", "second:tenant.created", "first:tenant.deleted", "second:tenant.deleted"} {
+		if handled[key] != 1 {
+			t.Errorf("Expected [%v] to be handled once, Actual [%v]", key, handled[key])
+		}
+	}
+}
+
+func TestRouterSkipsProcessedEvents(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Each connection opens a new in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	if err = idempotency.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRouter(db)
+	router.SetProcessedMessageStore(idempotency.NewDBProcessedMessageStore())
+	var handled []string
+	router.Handle("tenant.created", "test", func(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo) error {
+		handled = append(handled, eventInfo.MessageID)
+		return nil
+	})
+
+	first, firstAck := newTestEvent("tenant.created", "{}", "1")
+	duplicate, duplicateAck := newTestEvent("tenant.created", "{}", "1")
+	second, _ := newTestEvent("tenant.created", "{}", "2")
+	routeEvents(router, first, duplicate, second)
+
+	if len(handled) != 2 || handled[0] != "1" || handled[1] != "2" {
+		t.Errorf("Expected the duplicate event to be skipped, Actual handled %v", handled)
+	}
+	for _, ack := range []*acknowledgement{firstAck, duplicateAck} {
+		if acks, nacks := ack.counts(); acks != 1 || len(nacks) != 0 {
+			t.Errorf("Expected the event and its duplicate to be acked, Actual acks [%v] nacks %v", acks, nacks)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Registry holds the JSON Schemas of the event payloads per topic and version
+type Registry struct {
+	mutex   sync.RWMutex
+	schemas map[string]*Schema
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// NewRegistry creates a new schema registry
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]*Schema)}
+}
+
+// DefaultRegistry returns the schema registry shared by the dispatcher and the consumers of the process
+func DefaultRegistry() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+	})
+	return defaultRegistry
+}
+
+// Register registers the JSON Schema for the topic and version, version can be empty for unversioned events
+func (registry *Registry) Register(topic string, version string, schemaJSON []byte) error {
+	schema, err := Parse(schemaJSON)
+	if err != nil {
+		return fmt.Errorf("invalid schema for topic [%v] version [%v]: %w", topic, version, err)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.schemas[registryKey(topic, version)] = schema
+	return nil
+}
+
+// RegisterFile registers the JSON Schema in the file for the topic and version
+func (registry *Registry) RegisterFile(topic string, version string, schemaFilePath string) error {
+	schemaJSON, err := ioutil.ReadFile(schemaFilePath)
+	if err != nil {
+		return err
+	}
+	return registry.Register(topic, version, schemaJSON)
+}
+
+// Get returns the schema registered for the topic and version
+func (registry *Registry) Get(topic string, version string) (*Schema, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	schema, ok := registry.schemas[registryKey(topic, version)]
+	return schema, ok
+}
+
+// Validate validates the payload against the schema registered for the topic and version, payloads of the topics without schema are considered valid
+func (registry *Registry) Validate(topic string, version string, payload []byte) error {
+	schema, ok := registry.Get(topic, version)
+	if !ok {
+		return nil
+	}
+	return schema.Validate(payload)
+}
+
+func registryKey(topic string, version string) string {
+	return strings.ReplaceAll(topic, "_", ".") + "@" + version
+}
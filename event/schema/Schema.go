@@ -0,0 +1,256 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	microappError "github.com/islax/microapp/error"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// ErrorCodeTypeMismatch error code for value not matching the type defined by the schema
+	ErrorCodeTypeMismatch = "Key_TypeMismatch"
+	// ErrorCodeUnknownField error code for field not defined by the schema which does not allow additional properties
+	ErrorCodeUnknownField = "Key_UnknownField"
+)
+
+// Schema represents a JSON Schema. The keywords type, properties, required, additionalProperties (boolean), items, enum,
+// minLength, maxLength, pattern, format (date-time, email, uuid), minimum, maximum, minItems and maxItems are supported.
+type Schema struct {
+	Type                 interface{}        `json:"type"` // Either a type name or a list of type names
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"-"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Format               string             `json:"format"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+var emailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+// Parse parses the JSON Schema
+func Parse(schemaJSON []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		return nil, err
+	}
+	if err := schema.compile(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// UnmarshalJSON unmarshals the schema, additionalProperties is honoured only if it is a boolean
+func (schema *Schema) UnmarshalJSON(data []byte) error {
+	type schemaAlias Schema
+	var alias schemaAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	var keywords struct {
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return err
+	}
+	*schema = Schema(alias)
+	var additionalProperties bool
+	if json.Unmarshal(keywords.AdditionalProperties, &additionalProperties) == nil {
+		schema.AdditionalProperties = &additionalProperties
+	}
+	return nil
+}
+
+func (schema *Schema) compile() error {
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern [%v]: %w", schema.Pattern, err)
+		}
+		schema.pattern = pattern
+	}
+	for _, property := range schema.Properties {
+		if err := property.compile(); err != nil {
+			return err
+		}
+	}
+	if schema.Items != nil {
+		return schema.Items.compile()
+	}
+	return nil
+}
+
+// Validate validates the JSON document against the schema, ValidationError with the path of the invalid fields is returned if the document is not valid
+func (schema *Schema) Validate(document []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return microappError.NewInvalidRequestPayloadError(microappError.ErrorCodeInvalidJSON)
+	}
+
+	errors := make(map[string]string)
+	schema.validate(value, "", errors)
+	if len(errors) > 0 {
+		return microappError.NewInvalidFieldsError(errors)
+	}
+	return nil
+}
+
+func (schema *Schema) validate(value interface{}, path string, errors map[string]string) {
+	if !schema.matchesType(value) {
+		errors[fieldPath(path)] = ErrorCodeTypeMismatch
+		return
+	}
+
+	if len(schema.Enum) > 0 && !schema.inEnum(value) {
+		errors[fieldPath(path)] = microappError.ErrorCodeInvalidValue
+		return
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for _, requiredField := range schema.Required {
+			if _, ok := typedValue[requiredField]; !ok {
+				errors[joinPath(path, requiredField)] = microappError.ErrorCodeRequired
+			}
+		}
+		for field, fieldValue := range typedValue {
+			if property, ok := schema.Properties[field]; ok {
+				property.validate(fieldValue, joinPath(path, field), errors)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				errors[joinPath(path, field)] = ErrorCodeUnknownField
+			}
+		}
+	case []interface{}:
+		if (schema.MinItems != nil && len(typedValue) < *schema.MinItems) || (schema.MaxItems != nil && len(typedValue) > *schema.MaxItems) {
+			errors[fieldPath(path)] = microappError.ErrorCodeInvalidValue
+		}
+		if schema.Items != nil {
+			for i, item := range typedValue {
+				schema.Items.validate(item, fmt.Sprintf("%v[%v]", path, i), errors)
+			}
+		}
+	case string:
+		if !schema.isValidString(typedValue) {
+			errors[fieldPath(path)] = microappError.ErrorCodeInvalidValue
+		}
+	case json.Number:
+		number, _ := typedValue.Float64()
+		if (schema.Minimum != nil && number < *schema.Minimum) || (schema.Maximum != nil && number > *schema.Maximum) {
+			errors[fieldPath(path)] = microappError.ErrorCodeInvalidValue
+		}
+	}
+}
+
+func (schema *Schema) isValidString(value string) bool {
+	length := len([]rune(value))
+	if (schema.MinLength != nil && length < *schema.MinLength) || (schema.MaxLength != nil && length > *schema.MaxLength) {
+		return false
+	}
+	if schema.pattern != nil && !schema.pattern.MatchString(value) {
+		return false
+	}
+	switch schema.Format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "email":
+		return emailRegex.MatchString(value)
+	case "uuid":
+		_, err := uuid.FromString(value)
+		return err == nil
+	}
+	return true
+}
+
+func (schema *Schema) matchesType(value interface{}) bool {
+	var types []string
+	switch schemaType := schema.Type.(type) {
+	case string:
+		types = []string{schemaType}
+	case []interface{}:
+		for _, typeName := range schemaType {
+			if typeNameAsString, ok := typeName.(string); ok {
+				types = append(types, typeNameAsString)
+			}
+		}
+	default:
+		return true
+	}
+
+	for _, typeName := range types {
+		if matchesType(typeName, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(typeName string, value interface{}) bool {
+	switch typeName {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		floatValue, err := number.Float64()
+		return err == nil && floatValue == math.Trunc(floatValue)
+	}
+	return false
+}
+
+func (schema *Schema) inEnum(value interface{}) bool {
+	for _, enumValue := range schema.Enum {
+		if fmt.Sprint(enumValue) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path string, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "payload"
+	}
+	return strings.TrimPrefix(path, ".")
+}
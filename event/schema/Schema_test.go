@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"testing"
+
+	microappError "github.com/islax/microapp/error"
+)
+
+const tenantSchema = `{
+	"type": "object",
+	"required": ["id", "displayName"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"displayName": {"type": "string", "minLength": 1, "maxLength": 10},
+		"status": {"enum": ["active", "inactive"]},
+		"users": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]+$"}}
+	}
+}`
+
+var payloadCombinations = []struct {
+	payload string
+	errors  map[string]string
+}{
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme"}`, nil},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "status": "active", "users": 3, "tags": ["a", "b"]}`, nil},
+	{`{"displayName": "acme"}`, map[string]string{"id": "Key_Required"}},
+	{`{"id": "invalid", "displayName": ""}`, map[string]string{"id": "Key_InvalidValue", "displayName": "Key_InvalidValue"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": 1}`, map[string]string{"displayName": "Key_TypeMismatch"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "extra": true}`, map[string]string{"extra": "Key_UnknownField"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "status": "deleted"}`, map[string]string{"status": "Key_InvalidValue"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "users": 1.5}`, map[string]string{"users": "Key_TypeMismatch"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "users": -1}`, map[string]string{"users": "Key_InvalidValue"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "tags": ["a", "B"]}`, map[string]string{"tags[1]": "Key_InvalidValue"}},
+	{`{"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "displayName": "acme", "tags": ["a", "b", "c"]}`, map[string]string{"tags": "Key_InvalidValue"}},
+	{`[]`, map[string]string{"payload": "Key_TypeMismatch"}},
+}
+
+func TestValidate(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Register("tenant_added", "1", []byte(tenantSchema)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, combination := range payloadCombinations {
+		t.Run(combination.payload, func(t *testing.T) {
+			err := registry.Validate("tenant.added", "1", []byte(combination.payload))
+			if combination.errors == nil {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			validationError, ok := err.(microappError.ValidationError)
+			if !ok {
+				t.Fatalf("got %v, want validation error %v", err, combination.errors)
+			}
+			for field, errorCode := range combination.errors {
+				if got := validationError.Errors[field]; got != errorCode {
+					t.Errorf("got %v for %v, want %v", got, field, errorCode)
+				}
+			}
+		})
+	}
+
+	if err := registry.Validate("tenant.added", "2", []byte(`[]`)); err != nil {
+		t.Errorf("got %v, want no error for topic without schema", err)
+	}
+}
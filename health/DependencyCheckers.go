@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"gorm.io/gorm"
+)
+
+// ConnectionStateProvider should be implemented by the components which maintain a connection to an external system, e.g. RabbitMQ
+type ConnectionStateProvider interface {
+	IsConnected() bool
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (checker *checkerFunc) Name() string {
+	return checker.name
+}
+
+func (checker *checkerFunc) Check(ctx context.Context) error {
+	return checker.check(ctx)
+}
+
+// NewCheckerFunc creates a new health checker with the given name which invokes the given function
+func NewCheckerFunc(name string, check func(ctx context.Context) error) HealthChecker {
+	return &checkerFunc{name: name, check: check}
+}
+
+// NewDBChecker creates a new health checker which pings the database
+func NewDBChecker(db *gorm.DB) HealthChecker {
+	return NewCheckerFunc("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// NewMemcachedChecker creates a new health checker which pings the memcached servers
+func NewMemcachedChecker(client *memcache.Client) HealthChecker {
+	return NewCheckerFunc("memcached", func(ctx context.Context) error {
+		return client.Ping()
+	})
+}
+
+// NewConnectionChecker creates a new health checker which reports the connection state of the given provider
+func NewConnectionChecker(name string, provider ConnectionStateProvider) HealthChecker {
+	return NewCheckerFunc(name, func(ctx context.Context) error {
+		if !provider.IsConnected() {
+			return errors.New("not connected")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// StatusUp indicates that the component is healthy
+	StatusUp = "UP"
+	// StatusDown indicates that the component is not healthy
+	StatusDown = "DOWN"
+)
+
+// HealthChecker should be implemented by the components whose health needs to be reported by the health endpoints
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// ComponentReport represents the result of the latest health check of a component
+type ComponentReport struct {
+	Status      string     `json:"status"`
+	Latency     int64      `json:"latency"` // Latency of the check in milliseconds
+	Error       string     `json:"error,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorOn *time.Time `json:"lastErrorOn,omitempty"`
+}
+
+// Report represents the overall health along with health of each component
+type Report struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentReport `json:"components"`
+}
+
+// IsUp returns whether all the components are healthy
+func (report *Report) IsUp() bool {
+	return report.Status == StatusUp
+}
+
+type lastError struct {
+	message string
+	on      time.Time
+}
+
+// Registry holds the liveness and readiness health checkers
+type Registry struct {
+	timeout           time.Duration
+	mutex             sync.RWMutex
+	livenessCheckers  []HealthChecker
+	readinessCheckers []HealthChecker
+	lastErrors        map[string]*lastError
+}
+
+var (
+	defaultRegistry      *Registry
+	defaultRegistryMutex sync.Mutex
+)
+
+// SetDefaultRegistry sets the registry used by the health endpoints created without a registry, the App sets its registry as the default
+func SetDefaultRegistry(registry *Registry) {
+	defaultRegistryMutex.Lock()
+	defer defaultRegistryMutex.Unlock()
+	defaultRegistry = registry
+}
+
+// DefaultRegistry returns the default registry, nil if not set
+func DefaultRegistry() *Registry {
+	defaultRegistryMutex.Lock()
+	defer defaultRegistryMutex.Unlock()
+	return defaultRegistry
+}
+
+// NewRegistry creates a new health checker registry, each check is cancelled if it does not complete within the given timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout, lastErrors: make(map[string]*lastError)}
+}
+
+// RegisterLivenessChecker registers a checker whose failure indicates that the application needs to be restarted
+func (registry *Registry) RegisterLivenessChecker(checker HealthChecker) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.livenessCheckers = append(registry.livenessCheckers, checker)
+}
+
+// RegisterReadinessChecker registers a checker whose failure indicates that the application can not serve the requests
+func (registry *Registry) RegisterReadinessChecker(checker HealthChecker) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.readinessCheckers = append(registry.readinessCheckers, checker)
+}
+
+// CheckLiveness executes the liveness checkers and returns the report
+func (registry *Registry) CheckLiveness(ctx context.Context) *Report {
+	registry.mutex.RLock()
+	checkers := append([]HealthChecker{}, registry.livenessCheckers...)
+	registry.mutex.RUnlock()
+	return registry.check(ctx, checkers)
+}
+
+// CheckReadiness executes the liveness and readiness checkers and returns the report
+func (registry *Registry) CheckReadiness(ctx context.Context) *Report {
+	registry.mutex.RLock()
+	checkers := append(append([]HealthChecker{}, registry.livenessCheckers...), registry.readinessCheckers...)
+	registry.mutex.RUnlock()
+	return registry.check(ctx, checkers)
+}
+
+func (registry *Registry) check(ctx context.Context, checkers []HealthChecker) *Report {
+	report := &Report{Status: StatusUp, Components: make(map[string]*ComponentReport)}
+	reports := make([]*ComponentReport, len(checkers))
+
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker HealthChecker) {
+			defer wg.Done()
+			reports[i] = registry.checkComponent(ctx, checker)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	for i, checker := range checkers {
+		report.Components[checker.Name()] = reports[i]
+		if reports[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (registry *Registry) checkComponent(ctx context.Context, checker HealthChecker) *ComponentReport {
+	checkCtx, cancel := context.WithTimeout(ctx, registry.timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := runCheck(checkCtx, checker)
+	componentReport := &ComponentReport{Status: StatusUp, Latency: time.Since(startTime).Milliseconds()}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if err != nil {
+		componentReport.Status = StatusDown
+		componentReport.Error = err.Error()
+		registry.lastErrors[checker.Name()] = &lastError{message: err.Error(), on: time.Now().UTC()}
+	}
+	if lastErr, ok := registry.lastErrors[checker.Name()]; ok {
+		lastErrorOn := lastErr.on
+		componentReport.LastError = lastErr.message
+		componentReport.LastErrorOn = &lastErrorOn
+	}
+	return componentReport
+}
+
+// runCheck executes the checker and returns once it completes or the context is done, whichever happens first
+func runCheck(ctx context.Context, checker HealthChecker) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- checker.Check(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryAggregatesComponents(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	var databaseErr error
+	registry.RegisterLivenessChecker(NewCheckerFunc("self", func(ctx context.Context) error { return nil }))
+	registry.RegisterReadinessChecker(NewCheckerFunc("database", func(ctx context.Context) error { return databaseErr }))
+
+	if report := registry.CheckReadiness(context.Background()); !report.IsUp() || len(report.Components) != 2 {
+		t.Fatalf("Expected UP with both the components, Actual [%v] %v", report.Status, report.Components)
+	}
+
+	databaseErr = errors.New("connection refused")
+	report := registry.CheckReadiness(context.Background())
+	if report.IsUp() || report.Components["self"].Status != StatusUp || report.Components["database"].Status != StatusDown {
+		t.Fatalf("Expected DOWN due to the database, Actual [%v] %v", report.Status, report.Components)
+	}
+	if report.Components["database"].Error != "connection refused" {
+		t.Errorf("Expected the error of the check, Actual [%v]", report.Components["database"].Error)
+	}
+	if liveness := registry.CheckLiveness(context.Background()); !liveness.IsUp() || len(liveness.Components) != 1 {
+		t.Errorf("Expected liveness to ignore the readiness checkers, Actual [%v] %v", liveness.Status, liveness.Components)
+	}
+
+	databaseErr = nil
+	databaseReport := registry.CheckReadiness(context.Background()).Components["database"]
+	if databaseReport.Status != StatusUp || databaseReport.Error != "" || databaseReport.LastError != "connection refused" || databaseReport.LastErrorOn == nil {
+		t.Errorf("Expected UP along with the last error, Actual %+v", databaseReport)
+	}
+}
+
+func TestRegistryCheckTimeout(t *testing.T) {
+	registry := NewRegistry(50 * time.Millisecond)
+	registry.RegisterReadinessChecker(NewCheckerFunc("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second) // Ignores the context, the registry must not wait for it
+		return nil
+	}))
+
+	startTime := time.Now()
+	report := registry.CheckReadiness(context.Background())
+	if elapsed := time.Since(startTime); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the check to be abandoned after the timeout, Actual [%v]", elapsed)
+	}
+	if report.IsUp() || report.Components["slow"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected DOWN due to the timeout, Actual [%v] %+v", report.Status, report.Components["slow"])
+	}
+}
@@ -0,0 +1,223 @@
+package microapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/event/monitor"
+	"github.com/islax/microapp/health"
+)
+
+// Priorities of the stop hooks registered by the app itself. Hooks are executed in ascending order of priority,
+// so the http server stops accepting requests first and the database pool is closed last.
+const (
+	// LifecyclePriorityHTTPServer priority of the http server shutdown hook
+	LifecyclePriorityHTTPServer = 100
+	// LifecyclePriorityEventMonitor priority of the event monitor stop hooks
+	LifecyclePriorityEventMonitor = 200
+	// LifecyclePriorityWorker priority for the background workers
+	LifecyclePriorityWorker = 300
+	// LifecyclePriorityEventDispatcher priority of the event dispatcher flush hook
+	LifecyclePriorityEventDispatcher = 400
+	// LifecyclePriorityRabbitMQ priority of the RabbitMQ connection close hook
+	LifecyclePriorityRabbitMQ = 450
+	// LifecyclePriorityDB priority of the database connection pool close hook
+	LifecyclePriorityDB = 600
+)
+
+// LifecycleHook is invoked while starting or stopping the app. The context is cancelled when the hook timeout elapses.
+type LifecycleHook func(ctx context.Context) error
+
+type lifecycleHook struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	hook     LifecycleHook
+}
+
+type lifecycle struct {
+	mutex      sync.Mutex
+	startHooks []*lifecycleHook
+	stopHooks  []*lifecycleHook
+	stopOnce   sync.Once
+}
+
+// OnStart registers a hook to be executed by Run before the http server starts listening.
+// Hooks are executed in ascending order of priority, hooks with same priority are executed in the order of registration.
+// If timeout is zero, the SHUTDOWN_HOOK_TIMEOUT config value is used.
+func (app *App) OnStart(name string, priority int, timeout time.Duration, hook LifecycleHook) {
+	app.lifecycle.mutex.Lock()
+	defer app.lifecycle.mutex.Unlock()
+	app.lifecycle.startHooks = appendLifecycleHook(app.lifecycle.startHooks, &lifecycleHook{name: name, priority: priority, timeout: timeout, hook: hook})
+}
+
+// OnStop registers a hook to be executed when the app stops.
+// Hooks are executed in ascending order of priority, hooks with same priority are executed in the order of registration.
+// If timeout is zero, the SHUTDOWN_HOOK_TIMEOUT config value is used.
+func (app *App) OnStop(name string, priority int, timeout time.Duration, hook LifecycleHook) {
+	app.lifecycle.mutex.Lock()
+	defer app.lifecycle.mutex.Unlock()
+	app.lifecycle.stopHooks = appendLifecycleHook(app.lifecycle.stopHooks, &lifecycleHook{name: name, priority: priority, timeout: timeout, hook: hook})
+}
+
+// RegisterEventMonitor registers the given event monitor to be stopped when the app stops and its connection state to be reported by the readiness endpoint
+func (app *App) RegisterEventMonitor(name string, eventMonitor monitor.EventMonitor) {
+	if app.healthRegistry != nil {
+		app.healthRegistry.RegisterReadinessChecker(health.NewConnectionChecker(name, eventMonitor))
+	}
+	app.OnStop(name, LifecyclePriorityEventMonitor, 0, func(ctx context.Context) error {
+		eventMonitor.Stop()
+		return nil
+	})
+}
+
+// Run executes the start hooks, starts the http server and blocks till SIGINT / SIGTERM is received or the server fails.
+// The app is then stopped gracefully by executing the stop hooks, the process exits with a non-zero code if the server failed.
+func (app *App) Run() {
+	if err := app.executeLifecycleHooks("start", app.lifecycle.startHooks, true); err != nil {
+		app.log.Error().Err(err).Msg("Failed to start the application, stopping!")
+		app.Stop()
+		os.Exit(1)
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- app.listenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var serverErr error
+	select {
+	case receivedSignal := <-signals:
+		app.log.Info().Msgf("Received signal [%v], stopping the application...", receivedSignal)
+	case serverErr = <-serverErrors:
+		if serverErr != nil {
+			app.log.Error().Err(serverErr).Msg("Unable to start server or server stopped, stopping the application!")
+		}
+	}
+
+	app.Stop()
+	if serverErr != nil {
+		os.Exit(1)
+	}
+}
+
+func (app *App) listenAndServe() error {
+	var err error
+	if app.Config.GetBool(config.EvSuffixForEnableTLS) {
+		tlsCert := app.Config.GetString(config.EvSuffixForTLSCert)
+		tlsKey := app.Config.GetString(config.EvSuffixForTLSKey)
+		if tlsCert == "" {
+			return errors.New("TLS_CRT is not defined or empty")
+		}
+		if tlsKey == "" {
+			return errors.New("TLS_KEY is not defined or empty")
+		}
+		err = app.server.ListenAndServeTLS(tlsCert, tlsKey)
+	} else {
+		err = app.server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// registerDefaultStopHooks registers stop hooks for the resources owned by the app
+func (app *App) registerDefaultStopHooks() {
+	if stopper, ok := app.eventDispatcher.(event.Stopper); ok {
+		app.OnStop("event-dispatcher", LifecyclePriorityEventDispatcher, 0, stopper.Stop)
+	}
+
+	if app.rabbitMQ != nil {
+		app.OnStop("rabbitmq", LifecyclePriorityRabbitMQ, 0, func(ctx context.Context) error {
+			return app.rabbitMQ.Close()
+		})
+	}
+
+	if app.DBResolver != nil {
+		app.OnStop("database-replicas", LifecyclePriorityDB, 0, func(ctx context.Context) error {
+			return app.DBResolver.Stop()
+		})
+	}
+
+	if app.DB != nil {
+		app.OnStop("database", LifecyclePriorityDB, 0, func(ctx context.Context) error {
+			sqlDB, err := app.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		})
+	}
+}
+
+func (app *App) executeLifecycleHooks(phase string, hooks []*lifecycleHook, stopOnError bool) error {
+	app.lifecycle.mutex.Lock()
+	hooksToExecute := make([]*lifecycleHook, len(hooks))
+	copy(hooksToExecute, hooks)
+	app.lifecycle.mutex.Unlock()
+
+	defaultTimeout := time.Duration(app.Config.GetInt(config.EvSuffixForShutdownHookTimeout)) * time.Second
+	for _, hook := range hooksToExecute {
+		timeout := hook.timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		startTime := time.Now()
+		err := executeLifecycleHook(hook, timeout)
+		logger := app.log.With().Str("phase", phase).Str("hook", hook.name).Int("priority", hook.priority).Dur("duration", time.Since(startTime)).Logger()
+		if err != nil {
+			logger.Error().Err(err).Msg("Lifecycle hook failed.")
+			if stopOnError {
+				return fmt.Errorf("%v hook [%v] failed: %w", phase, hook.name, err)
+			}
+			continue
+		}
+		logger.Debug().Msg("Lifecycle hook completed.")
+	}
+	return nil
+}
+
+func executeLifecycleHook(hook *lifecycleHook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- hook.hook(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
+func appendLifecycleHook(hooks []*lifecycleHook, hook *lifecycleHook) []*lifecycleHook {
+	hooks = append(hooks, hook)
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].priority < hooks[j].priority
+	})
+	return hooks
+}
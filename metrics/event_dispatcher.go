@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// EventDispatcherStats holds the counters of the events handled by the event dispatcher
+type EventDispatcherStats struct {
+	Confirmed prometheus.Counter // The number of events confirmed by the broker.
+	Spooled   prometheus.Counter // The number of events written to the spool as they could not be published.
+	Replayed  prometheus.Counter // The number of events read from the spool for publishing.
+	Dropped   prometheus.Counter // The number of events which could neither be published nor spooled.
+}
+
+var (
+	eventDispatcherStats     *EventDispatcherStats
+	eventDispatcherStatsOnce sync.Once
+)
+
+// GetEventDispatcherStats returns the event dispatcher counters, the counters are registered on first use
+func GetEventDispatcherStats() *EventDispatcherStats {
+	eventDispatcherStatsOnce.Do(func() {
+		eventDispatcherStats = &EventDispatcherStats{
+			Confirmed: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "event_dispatcher_confirmed_total",
+				Help: "The number of events confirmed by the broker.",
+			}),
+			Spooled: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "event_dispatcher_spooled_total",
+				Help: "The number of events written to the spool as they could not be published.",
+			}),
+			Replayed: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "event_dispatcher_replayed_total",
+				Help: "The number of events read from the spool for publishing.",
+			}),
+			Dropped: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "event_dispatcher_dropped_total",
+				Help: "The number of events which could neither be published nor spooled.",
+			}),
+		}
+		for _, collector := range []prometheus.Collector{eventDispatcherStats.Confirmed, eventDispatcherStats.Spooled, eventDispatcherStats.Replayed, eventDispatcherStats.Dropped} {
+			_ = prometheus.Register(collector)
+		}
+	})
+	return eventDispatcherStats
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Operations recorded in the audit log
+const (
+	AuditOperationCreate = "create"
+	AuditOperationUpdate = "update"
+	AuditOperationDelete = "delete"
+)
+
+// Auditable is implemented by the models whose creates, updates and deletes are recorded in the audit log when the audit is enabled.
+// Fields tagged with `audit:"-"` are not recorded.
+type Auditable interface {
+	IsAuditable() bool
+}
+
+// AuditLog represents a change of an auditable entity made in a unit of work, attributed to the token and the action of the execution context
+type AuditLog struct {
+	ID            uuid.UUID `gorm:"type:varchar(36);primary_key;"`
+	TenantID      uuid.UUID `gorm:"type:varchar(36);column:tenantId;index:audit_tenantid"`
+	UserID        uuid.UUID `gorm:"type:varchar(36);column:userId"`
+	UserName      string    `gorm:"column:userName;type:varchar(255)"`
+	CorrelationID string    `gorm:"column:correlationId;type:varchar(64);index:audit_correlationid"`
+	Action        string    `gorm:"column:action;type:varchar(255)"`
+	Operation     string    `gorm:"column:operation;type:varchar(16)"`
+	EntityType    string    `gorm:"column:entityType;type:varchar(255);index:audit_entity"`
+	EntityID      string    `gorm:"column:entityId;type:varchar(64);index:audit_entity"`
+	Changes       string    `gorm:"column:changes;type:longtext"` // JSON object of column -> {"old": value, "new": value}
+	CreatedAt     time.Time `gorm:"column:createdOn;index:audit_createdon"`
+}
+
+// TableName returns the name of the audit log table
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
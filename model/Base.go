@@ -8,14 +8,19 @@ import (
 
 	microappError "github.com/islax/microapp/error"
 	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
 )
 
-// Base contains common columns for all tables.
+// Base contains common columns for all tables. Deletes are soft deletes, see Repository.DeletePermanent and Repository.Restore.
+//
+// Breaking change: DeletedAt used to be a *time.Time, which gorm does not treat as a soft delete column, so Repository.Delete
+// removed the rows of models embedding Base. It now only sets deletedOn; use Repository.DeletePermanent where the row must be
+// removed, e.g. when a row with the same id can be created again.
 type Base struct {
-	ID        uuid.UUID  `gorm:"type:varchar(36);primary_key;"`
-	CreatedAt time.Time  `gorm:"column:createdOn"`
-	UpdatedAt time.Time  `gorm:"column:modifiedOn"`
-	DeletedAt *time.Time `sql:"index" gorm:"column:deletedOn"`
+	ID        uuid.UUID      `gorm:"type:varchar(36);primary_key;"`
+	CreatedAt time.Time      `gorm:"column:createdOn"`
+	UpdatedAt time.Time      `gorm:"column:modifiedOn"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deletedOn;index:deletedon"`
 }
 
 //FieldData represents the data associated with a field
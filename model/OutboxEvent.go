@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// OutboxEvent represents an event stored in the outbox table as part of a unit of work, the outbox relay publishes it after the unit of work is committed
+type OutboxEvent struct {
+	ID            uuid.UUID  `gorm:"type:varchar(36);primary_key;"`
+	Topic         string     `gorm:"column:topic;type:varchar(255)"`
+	Token         string     `gorm:"column:token;type:text"`
+	CorrelationID string     `gorm:"column:correlationId;type:varchar(64)"`
+	TenantID      string     `gorm:"column:tenantId;type:varchar(36)"`
+	MessageID     string     `gorm:"column:messageId;type:varchar(64)"`    // Message id of the published event, the outbox event id if empty
+	EventVersion  string     `gorm:"column:eventVersion;type:varchar(32)"` // Schema version of the payload
+	Headers       string     `gorm:"column:headers;type:text"`             // Custom headers of the published event as JSON
+	Payload       string     `gorm:"column:payload;type:longtext"`
+	Attempts      int        `gorm:"column:attempts"`
+	LastError     string     `gorm:"column:lastError;type:text"`
+	CreatedAt     time.Time  `gorm:"column:createdOn;index:outbox_createdon"`
+	PublishedAt   *time.Time `gorm:"column:publishedOn;index:outbox_publishedon"`
+	NextAttemptAt *time.Time `gorm:"column:nextAttemptOn"` // Time after which the failed event is retried
+	ClaimedUntil  *time.Time `gorm:"column:claimedUntil"`  // Time till which the event is being published by a relay
+	DeadAt        *time.Time `gorm:"column:deadOn"`        // Time at which the attempts were exhausted, dead events are not retried till they are replayed
+}
+
+// TableName returns the name of the outbox table
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// ProcessedMessage records a message processed by a consumer, it is used to skip the redelivered messages
+type ProcessedMessage struct {
+	Consumer    string    `gorm:"column:consumer;type:varchar(255);primary_key"`
+	MessageID   string    `gorm:"column:messageId;type:varchar(255);primary_key"`
+	ProcessedAt time.Time `gorm:"column:processedOn;index:processedmessage_processedon"`
+}
+
+// TableName returns the name of the processed message table
+func (ProcessedMessage) TableName() string {
+	return "processed_message"
+}
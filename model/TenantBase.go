@@ -7,11 +7,11 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
-// TenantBase contains common columns for all tables that are tenant specific.
+// TenantBase contains common columns for all tables that are tenant specific. Deletes are soft deletes, see Repository.DeletePermanent and Repository.Restore.
 type TenantBase struct {
 	ID        uuid.UUID      `gorm:"type:varchar(36);primary_key;"`
 	TenantID  uuid.UUID      `gorm:"type:varchar(36);column:tenantId;index:tenantid"`
 	CreatedAt time.Time      `gorm:"column:createdOn;index:createdon"`
 	UpdatedAt time.Time      `gorm:"column:modifiedOn"`
-	DeletedAt gorm.DeletedAt `sql:"index" gorm:"column:deletedOn"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deletedOn;index:deletedon"`
 }
@@ -0,0 +1,23 @@
+package model
+
+// Versioned contains the version column for optimistic locking, embed it along with Base or TenantBase.
+// The repository increments the version on update and fails the update with ConflictError if the row has a different version.
+type Versioned struct {
+	Version int64 `gorm:"column:version;not null;default:0"`
+}
+
+// VersionedEntity is implemented by the models embedding Versioned
+type VersionedEntity interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+// GetVersion returns the version of the entity
+func (versioned *Versioned) GetVersion() int64 {
+	return versioned.Version
+}
+
+// SetVersion sets the version of the entity, e.g. from the If-Match header
+func (versioned *Versioned) SetVersion(version int64) {
+	versioned.Version = version
+}
@@ -0,0 +1,292 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/model"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migrate creates or updates the outbox table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&model.OutboxEvent{})
+}
+
+const (
+	publishTimeout      = time.Minute        // max time to publish a batch, the events not confirmed by then are retried
+	claimDuration       = 2 * publishTimeout // time for which the claimed events are skipped by the other relays, e.g. if the relay claiming them exits
+	initialRetryDelay   = time.Second        // delay before the first retry of a failed event, doubled on every subsequent failure
+	maxRetryDelay       = 10 * time.Minute   // max delay between the retries of a failed event
+	defaultPollInterval = time.Second        // used if OUTBOX_POLL_INTERVAL is not positive
+	defaultBatchSize    = 100                // used if OUTBOX_BATCH_SIZE is not positive
+)
+
+// Relay periodically publishes the committed outbox events using the event dispatcher.
+// Events are published at least once, a failed event is retried with exponential backoff till it is published or the max attempts are exhausted.
+// The event is then marked dead, dead events are reported and are not retried till they are replayed using ReplayDeadEvents.
+// Each batch is claimed in a short transaction using SELECT ... FOR UPDATE SKIP LOCKED (requires MySQL 8), so that the relays of the replicas publish
+// different events, and is published outside of the transaction so that no lock is held while waiting for the broker.
+// An event is marked published only after the broker confirms it, if the dispatcher implements event.ConfirmingDispatcher.
+// Events are published with the outbox event id as message id, so that the consumers can drop the duplicates.
+// Published events are removed once the retention period elapses.
+type Relay struct {
+	db           *gorm.DB
+	dispatcher   event.Dispatcher
+	logger       *zerolog.Logger
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	retention    time.Duration
+	startOnce    sync.Once
+	stopOnce     sync.Once
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewRelay creates a new outbox relay, the default poll interval and batch size are used if the configured ones are not positive
+func NewRelay(db *gorm.DB, dispatcher event.Dispatcher, logger *zerolog.Logger, appConfig *config.Config) *Relay {
+	pollInterval := time.Duration(appConfig.GetInt(config.EvSuffixForOutboxPollInterval)) * time.Millisecond
+	if pollInterval <= 0 {
+		logger.Warn().Dur("interval", pollInterval).Dur("defaultInterval", defaultPollInterval).Msgf("Invalid %v, using the default interval.", config.EvSuffixForOutboxPollInterval)
+		pollInterval = defaultPollInterval
+	}
+	batchSize := appConfig.GetInt(config.EvSuffixForOutboxBatchSize)
+	if batchSize <= 0 {
+		logger.Warn().Int("batchSize", batchSize).Int("defaultBatchSize", defaultBatchSize).Msgf("Invalid %v, using the default batch size.", config.EvSuffixForOutboxBatchSize)
+		batchSize = defaultBatchSize
+	}
+	return &Relay{
+		db:           db,
+		dispatcher:   dispatcher,
+		logger:       logger,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  appConfig.GetInt(config.EvSuffixForOutboxMaxAttempts),
+		retention:    time.Duration(appConfig.GetInt(config.EvSuffixForOutboxRetention)) * time.Hour,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start starts publishing the outbox events in background
+func (relay *Relay) Start() {
+	relay.startOnce.Do(func() {
+		go relay.run()
+	})
+}
+
+// Stop stops the relay after the batch being published is completed or the context is done
+func (relay *Relay) Stop(ctx context.Context) error {
+	relay.stopOnce.Do(func() {
+		close(relay.stop)
+	})
+	select {
+	case <-relay.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (relay *Relay) run() {
+	defer close(relay.done)
+	ticker := time.NewTicker(relay.pollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		select {
+		case <-relay.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// Keep publishing till the outbox is drained, so that bursts are not throttled by the poll interval
+		for {
+			published, err := relay.publishBatch()
+			if err != nil {
+				relay.logger.Error().Err(err).Msg("Failed to publish outbox events.")
+			}
+			if err != nil || published < relay.batchSize {
+				break
+			}
+			select {
+			case <-relay.stop:
+				return
+			default:
+			}
+		}
+
+		if time.Since(lastCleanup) >= time.Hour {
+			if err := relay.cleanup(); err != nil {
+				relay.logger.Error().Err(err).Msg("Failed to remove published outbox events.")
+			}
+			relay.reportDeadEvents()
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+// publishBatch claims and publishes the oldest pending events and returns the number of claimed events
+func (relay *Relay) publishBatch() (int, error) {
+	outboxEvents, err := relay.claimBatch()
+	if err != nil || len(outboxEvents) == 0 {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	publishErrors := make([]error, len(outboxEvents))
+	for i := range outboxEvents {
+		publishErrors[i] = relay.publish(ctx, &outboxEvents[i])
+	}
+	return len(outboxEvents), relay.completeBatch(outboxEvents, publishErrors)
+}
+
+// claimBatch claims the pending events whose retry delay elapsed, the attempts are incremented along with the claim
+func (relay *Relay) claimBatch() ([]model.OutboxEvent, error) {
+	var outboxEvents []model.OutboxEvent
+	now := time.Now().UTC()
+	err := relay.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("publishedOn IS NULL AND deadOn IS NULL AND (nextAttemptOn IS NULL OR nextAttemptOn <= ?) AND (claimedUntil IS NULL OR claimedUntil < ?)", now, now).
+			Order("createdOn").Limit(relay.batchSize).Find(&outboxEvents).Error; err != nil {
+			return err
+		}
+		if len(outboxEvents) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(outboxEvents))
+		for i := range outboxEvents {
+			ids[i] = outboxEvents[i].ID.String()
+			outboxEvents[i].Attempts++
+		}
+		return tx.Model(&model.OutboxEvent{}).Where("id IN ?", ids).Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "claimedUntil": now.Add(claimDuration)}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outboxEvents, nil
+}
+
+// completeBatch marks the published events and schedules the retry of the failed events, the events whose attempts are exhausted are marked dead
+func (relay *Relay) completeBatch(outboxEvents []model.OutboxEvent, publishErrors []error) error {
+	now := time.Now().UTC()
+	// If this transaction fails, the claim expires and the batch is published again which is acceptable for at least once delivery
+	return relay.db.Transaction(func(tx *gorm.DB) error {
+		for i, outboxEvent := range outboxEvents {
+			updates := map[string]interface{}{"claimedUntil": nil}
+			if publishErr := publishErrors[i]; publishErr == nil {
+				updates["publishedOn"] = now
+			} else {
+				logger := relay.logger.With().Str("topic", outboxEvent.Topic).Str("outboxEventId", outboxEvent.ID.String()).Int("attempt", outboxEvent.Attempts).Logger()
+				updates["lastError"] = publishErr.Error()
+				if relay.maxAttempts > 0 && outboxEvent.Attempts >= relay.maxAttempts {
+					updates["deadOn"] = now
+					logger.Error().Err(publishErr).Msg("Failed to publish outbox event, attempts exhausted. The event is dead till it is replayed.")
+				} else {
+					updates["nextAttemptOn"] = now.Add(retryDelay(outboxEvent.Attempts))
+					logger.Warn().Err(publishErr).Msg("Failed to publish outbox event, retrying.")
+				}
+			}
+			if err := tx.Model(&model.OutboxEvent{}).Where("id = ? AND publishedOn IS NULL", outboxEvent.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// retryDelay returns the delay before retrying the event which failed the given number of attempts
+func retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// publish dispatches the event and waits for the broker to confirm it if the dispatcher supports confirmations
+func (relay *Relay) publish(ctx context.Context, outboxEvent *model.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while dispatching event: %v", r)
+		}
+	}()
+	options, err := dispatchOptions(outboxEvent)
+	if err != nil {
+		return err
+	}
+	switch dispatcher := relay.dispatcher.(type) {
+	case event.ConfirmingDispatcher:
+		return dispatcher.DispatchEventAndWait(ctx, outboxEvent.Topic, []byte(outboxEvent.Payload), options)
+	case event.OptionsDispatcher:
+		dispatcher.DispatchEventWithOptions(outboxEvent.Topic, []byte(outboxEvent.Payload), options)
+	default:
+		relay.dispatcher.DispatchEvent(outboxEvent.Token, outboxEvent.CorrelationID, outboxEvent.Topic, []byte(outboxEvent.Payload))
+	}
+	return nil
+}
+
+// dispatchOptions returns the options with the metadata stored along with the outbox event, the outbox event id is the message id unless it was set while enqueuing
+func dispatchOptions(outboxEvent *model.OutboxEvent) (*event.DispatchOptions, error) {
+	options := &event.DispatchOptions{Token: outboxEvent.Token, CorrelationID: outboxEvent.CorrelationID, TenantID: outboxEvent.TenantID, MessageID: outboxEvent.MessageID, Version: outboxEvent.EventVersion}
+	if options.MessageID == "" {
+		options.MessageID = outboxEvent.ID.String()
+	}
+	if outboxEvent.Headers != "" {
+		if err := json.Unmarshal([]byte(outboxEvent.Headers), &options.Headers); err != nil {
+			return nil, fmt.Errorf("invalid headers: %w", err)
+		}
+	}
+	return options, nil
+}
+
+func (relay *Relay) cleanup() error {
+	return relay.db.Where("publishedOn IS NOT NULL AND publishedOn < ?", time.Now().UTC().Add(-relay.retention)).Delete(&model.OutboxEvent{}).Error
+}
+
+// reportDeadEvents logs the number of dead events, so that they are noticed and replayed
+func (relay *Relay) reportDeadEvents() {
+	count, err := CountDeadEvents(relay.db)
+	if err != nil {
+		relay.logger.Error().Err(err).Msg("Failed to count dead outbox events.")
+	} else if count > 0 {
+		relay.logger.Error().Int64("count", count).Msg("Outbox has dead events whose attempts are exhausted, replay them once the cause is fixed.")
+	}
+}
+
+// CountDeadEvents returns the number of the outbox events whose attempts are exhausted
+func CountDeadEvents(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&model.OutboxEvent{}).Where("deadOn IS NOT NULL AND publishedOn IS NULL").Count(&count).Error
+	return count, err
+}
+
+// ReplayDeadEvents makes the dead outbox events with the given ids, or all of them if no id is given, pending again with fresh attempts.
+// It returns the number of the replayed events.
+func ReplayDeadEvents(db *gorm.DB, ids ...uuid.UUID) (int64, error) {
+	query := db.Model(&model.OutboxEvent{}).Where("deadOn IS NOT NULL AND publishedOn IS NULL")
+	if len(ids) > 0 {
+		idStrings := make([]string, len(ids))
+		for i, id := range ids {
+			idStrings[i] = id.String()
+		}
+		query = query.Where("id IN ?", idStrings)
+	}
+	result := query.Updates(map[string]interface{}{"deadOn": nil, "nextAttemptOn": nil, "attempts": 0})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,210 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/log"
+	"github.com/islax/microapp/model"
+	"github.com/islax/microapp/repository"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type confirmingDispatcher struct {
+	confirmed map[string]string // message id to topic
+	reject    string            // topic rejected by the broker
+	options   []*event.DispatchOptions
+}
+
+func (dispatcher *confirmingDispatcher) DispatchEvent(token string, corelationID string, topic string, payload interface{}) {
+	panic("events must be published using DispatchEventAndWait")
+}
+
+func (dispatcher *confirmingDispatcher) DispatchEventAndWait(ctx context.Context, topic string, payload interface{}, options *event.DispatchOptions) error {
+	if topic == dispatcher.reject {
+		return errors.New("event rejected by the broker")
+	}
+	dispatcher.confirmed[options.MessageID] = topic
+	dispatcher.options = append(dispatcher.options, options)
+	return nil
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Each connection opens a new in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRelayPublishBatch(t *testing.T) {
+	db := newTestDB(t)
+	published := model.OutboxEvent{ID: uuid.NewV4(), Topic: "user.created", Payload: "{}", CreatedAt: time.Now().Add(-time.Minute)}
+	rejected := model.OutboxEvent{ID: uuid.NewV4(), Topic: "user.deleted", Payload: "{}", CreatedAt: time.Now()}
+	if err := db.Create([]model.OutboxEvent{published, rejected}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := &confirmingDispatcher{confirmed: map[string]string{}, reject: "user.deleted"}
+	nopLogger := zerolog.Nop()
+	relay := &Relay{db: db, dispatcher: dispatcher, logger: &nopLogger, batchSize: 10, maxAttempts: 3}
+
+	count, err := relay.publishBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 events to be read, Actual [%v]", count)
+	}
+	if topic := dispatcher.confirmed[published.ID.String()]; topic != published.Topic || len(dispatcher.confirmed) != 1 {
+		t.Errorf("Expected the event to be published with the outbox event id as message id, Actual %v", dispatcher.confirmed)
+	}
+
+	var outboxEvent model.OutboxEvent
+	db.First(&outboxEvent, "id = ?", published.ID)
+	if outboxEvent.PublishedAt == nil || outboxEvent.Attempts != 1 || outboxEvent.ClaimedUntil != nil {
+		t.Errorf("Expected the confirmed event to be marked published, Actual publishedOn [%v] attempts [%v] claimedUntil [%v]", outboxEvent.PublishedAt, outboxEvent.Attempts, outboxEvent.ClaimedUntil)
+	}
+	var rejectedEvent model.OutboxEvent
+	db.First(&rejectedEvent, "id = ?", rejected.ID)
+	if rejectedEvent.PublishedAt != nil || rejectedEvent.Attempts != 1 || rejectedEvent.LastError == "" || rejectedEvent.ClaimedUntil != nil {
+		t.Errorf("Expected the rejected event to remain pending with the error, Actual publishedOn [%v] attempts [%v] lastError [%v] claimedUntil [%v]", rejectedEvent.PublishedAt, rejectedEvent.Attempts, rejectedEvent.LastError, rejectedEvent.ClaimedUntil)
+	}
+	if rejectedEvent.NextAttemptAt == nil || !rejectedEvent.NextAttemptAt.After(time.Now()) {
+		t.Errorf("Expected the retry of the rejected event to be delayed, Actual nextAttemptOn [%v]", rejectedEvent.NextAttemptAt)
+	}
+
+	if count, err = relay.publishBatch(); err != nil || count != 0 {
+		t.Errorf("Expected the rejected event not to be retried before its retry delay elapses, Actual count [%v] error [%v]", count, err)
+	}
+	db.Model(&model.OutboxEvent{}).Where("id = ?", rejected.ID).Update("nextAttemptOn", time.Now().UTC().Add(-time.Second))
+	if count, err = relay.publishBatch(); err != nil || count != 1 {
+		t.Errorf("Expected only the rejected event to be retried, Actual count [%v] error [%v]", count, err)
+	}
+}
+
+func TestRelayMarksEventDeadOnceAttemptsAreExhausted(t *testing.T) {
+	db := newTestDB(t)
+	rejected := model.OutboxEvent{ID: uuid.NewV4(), Topic: "user.deleted", Payload: "{}", CreatedAt: time.Now()}
+	if err := db.Create(&rejected).Error; err != nil {
+		t.Fatal(err)
+	}
+	dispatcher := &confirmingDispatcher{confirmed: map[string]string{}, reject: "user.deleted"}
+	nopLogger := zerolog.Nop()
+	relay := &Relay{db: db, dispatcher: dispatcher, logger: &nopLogger, batchSize: 10, maxAttempts: 2}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		db.Model(&model.OutboxEvent{}).Where("id = ?", rejected.ID).Update("nextAttemptOn", time.Now().UTC().Add(-time.Second))
+		if count, err := relay.publishBatch(); err != nil || count != 1 {
+			t.Fatalf("Expected the event to be attempted, Actual count [%v] error [%v]", count, err)
+		}
+	}
+	db.Model(&model.OutboxEvent{}).Where("id = ?", rejected.ID).Update("nextAttemptOn", time.Now().UTC().Add(-time.Second))
+	if count, err := relay.publishBatch(); err != nil || count != 0 {
+		t.Errorf("Expected the dead event not to be retried, Actual count [%v] error [%v]", count, err)
+	}
+	if count, err := CountDeadEvents(db); err != nil || count != 1 {
+		t.Errorf("Expected 1 dead event, Actual [%v] error [%v]", count, err)
+	}
+
+	if replayed, err := ReplayDeadEvents(db, rejected.ID); err != nil || replayed != 1 {
+		t.Fatalf("Expected the dead event to be replayed, Actual [%v] error [%v]", replayed, err)
+	}
+	dispatcher.reject = ""
+	if count, err := relay.publishBatch(); err != nil || count != 1 {
+		t.Errorf("Expected the replayed event to be published, Actual count [%v] error [%v]", count, err)
+	}
+	if count, _ := CountDeadEvents(db); count != 0 || dispatcher.confirmed[rejected.ID.String()] == "" {
+		t.Errorf("Expected the replayed event to be published, Actual dead events [%v] confirmed %v", count, dispatcher.confirmed)
+	}
+}
+
+func TestRelaySkipsClaimedEvents(t *testing.T) {
+	db := newTestDB(t)
+	claimedUntil := time.Now().UTC().Add(time.Minute)
+	claimed := model.OutboxEvent{ID: uuid.NewV4(), Topic: "user.created", Payload: "{}", CreatedAt: time.Now(), ClaimedUntil: &claimedUntil}
+	if err := db.Create(&claimed).Error; err != nil {
+		t.Fatal(err)
+	}
+	nopLogger := zerolog.Nop()
+	relay := &Relay{db: db, dispatcher: &confirmingDispatcher{confirmed: map[string]string{}}, logger: &nopLogger, batchSize: 10, maxAttempts: 3}
+
+	if count, err := relay.publishBatch(); err != nil || count != 0 {
+		t.Errorf("Expected the event claimed by another relay to be skipped, Actual count [%v] error [%v]", count, err)
+	}
+	db.Model(&model.OutboxEvent{}).Where("id = ?", claimed.ID).Update("claimedUntil", time.Now().UTC().Add(-time.Second))
+	if count, err := relay.publishBatch(); err != nil || count != 1 {
+		t.Errorf("Expected the event to be published once the claim expires, Actual count [%v] error [%v]", count, err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	delays := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 10: 512 * time.Second, 11: maxRetryDelay, 1000: maxRetryDelay}
+	for attempts, expected := range delays {
+		if delay := retryDelay(attempts); delay != expected {
+			t.Errorf("Attempts [%v]: Expected delay [%v], Actual [%v]", attempts, expected, delay)
+		}
+	}
+}
+
+func TestRelayPublishesMetadataOfEnqueuedEvent(t *testing.T) {
+	db := newTestDB(t)
+	tenantID := uuid.NewV4()
+	uow := repository.NewUnitOfWork(db, false, zerolog.Nop(), log.Config{})
+	uow.SetEventContext("token", "correlation")
+	uow.SetEventTenant(tenantID)
+	if err := uow.Enqueue("user.created", map[string]string{"id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := uow.EnqueueWithOptions("user.updated", map[string]string{"id": "1"}, &event.DispatchOptions{MessageID: "message", Version: "2", Headers: map[string]interface{}{"X-Trace": "trace"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := uow.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := &confirmingDispatcher{confirmed: map[string]string{}}
+	nopLogger := zerolog.Nop()
+	relay := &Relay{db: db, dispatcher: dispatcher, logger: &nopLogger, batchSize: 10, maxAttempts: 3}
+	if count, err := relay.publishBatch(); err != nil || count != 2 {
+		t.Fatalf("Expected 2 events to be published, Actual count [%v] error [%v]", count, err)
+	}
+	for _, options := range dispatcher.options {
+		if options.Token != "token" || options.CorrelationID != "correlation" || options.TenantID != tenantID.String() {
+			t.Errorf("Expected token, correlation id and tenant id of the unit of work, Actual %+v", options)
+		}
+	}
+	if topic := dispatcher.confirmed["message"]; topic != "user.updated" {
+		t.Errorf("Expected the event to be published with the message id of the options, Actual %v", dispatcher.confirmed)
+	}
+	for _, options := range dispatcher.options {
+		if options.MessageID == "message" && (options.Version != "2" || options.Headers["X-Trace"] != "trace") {
+			t.Errorf("Expected version and headers of the options, Actual %+v", options)
+		}
+	}
+}
+
+func TestNewRelayWithInvalidConfig(t *testing.T) {
+	nopLogger := zerolog.Nop()
+	appConfig := config.NewConfig(nil)
+	appConfig.Set(config.EvSuffixForOutboxPollInterval, 0)
+	appConfig.Set(config.EvSuffixForOutboxBatchSize, -1)
+	relay := NewRelay(nil, &confirmingDispatcher{}, &nopLogger, appConfig)
+	if relay.pollInterval != defaultPollInterval || relay.batchSize != defaultBatchSize {
+		t.Errorf("Expected default poll interval and batch size, Actual [%v, %v]", relay.pollInterval, relay.batchSize)
+	}
+}
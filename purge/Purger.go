@@ -0,0 +1,142 @@
+package purge
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/islax/microapp/repository"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// Policy is the retention of the soft deleted rows of a model
+type Policy struct {
+	Model         interface{} // model embedding model.Base or model.TenantBase
+	RetentionDays int         // soft deleted rows older than these many days are removed permanently, PURGE_RETENTION if zero
+}
+
+// Purger periodically removes the soft deleted rows older than the retention of their model permanently.
+// Rows are removed in batches, each in its own statement, to avoid holding locks for long.
+type Purger struct {
+	db               *gorm.DB
+	policies         []Policy
+	logger           *zerolog.Logger
+	interval         time.Duration
+	batchSize        int
+	defaultRetention int
+	startOnce        sync.Once
+	stopOnce         sync.Once
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+const (
+	defaultInterval  = 24 * time.Hour // used if PURGE_INTERVAL is not positive
+	defaultBatchSize = 500            // used if PURGE_BATCH_SIZE is not positive
+)
+
+// NewPurger creates a new purger for the policies, the default interval and batch size are used if the configured ones are not positive
+func NewPurger(db *gorm.DB, policies []Policy, logger *zerolog.Logger, appConfig *config.Config) *Purger {
+	interval := time.Duration(appConfig.GetInt(config.EvSuffixForPurgeInterval)) * time.Hour
+	if interval <= 0 {
+		logger.Warn().Dur("interval", interval).Dur("defaultInterval", defaultInterval).Msgf("Invalid %v, using the default interval.", config.EvSuffixForPurgeInterval)
+		interval = defaultInterval
+	}
+	batchSize := appConfig.GetInt(config.EvSuffixForPurgeBatchSize)
+	if batchSize <= 0 {
+		logger.Warn().Int("batchSize", batchSize).Int("defaultBatchSize", defaultBatchSize).Msgf("Invalid %v, using the default batch size.", config.EvSuffixForPurgeBatchSize)
+		batchSize = defaultBatchSize
+	}
+	return &Purger{
+		db:               db.WithContext(repository.WithAuditActor(context.Background(), repository.AuditActor{Action: "purge"})),
+		policies:         policies,
+		logger:           logger,
+		interval:         interval,
+		batchSize:        batchSize,
+		defaultRetention: appConfig.GetInt(config.EvSuffixForPurgeRetention),
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start starts purging in background, the first purge runs immediately
+func (purger *Purger) Start() {
+	purger.startOnce.Do(func() {
+		go purger.run()
+	})
+}
+
+// Stop stops the purger after the batch being removed is completed or the context is done
+func (purger *Purger) Stop(ctx context.Context) error {
+	purger.stopOnce.Do(func() {
+		close(purger.stop)
+	})
+	select {
+	case <-purger.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (purger *Purger) run() {
+	defer close(purger.done)
+	ticker := time.NewTicker(purger.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, policy := range purger.policies {
+			purged, err := purger.purge(policy)
+			modelName := reflect.Indirect(reflect.ValueOf(policy.Model)).Type().Name()
+			if err != nil {
+				purger.logger.Error().Err(err).Str("model", modelName).Msg("Failed to purge soft deleted rows.")
+			} else if purged > 0 {
+				purger.logger.Info().Str("model", modelName).Int64("purged", purged).Msg("Purged soft deleted rows.")
+			}
+		}
+
+		select {
+		case <-purger.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge removes the soft deleted rows of the model older than its retention and returns the number of removed rows
+func (purger *Purger) purge(policy Policy) (int64, error) {
+	retentionDays := policy.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = purger.defaultRetention
+	}
+	modelType := reflect.Indirect(reflect.ValueOf(policy.Model)).Type()
+	deletedBefore := time.Now().UTC().AddDate(0, 0, -retentionDays)
+
+	var purged int64
+	for {
+		var ids []string
+		if err := purger.db.Unscoped().Model(reflect.New(modelType).Interface()).Where("deletedOn IS NOT NULL AND deletedOn < ?", deletedBefore).Limit(purger.batchSize).Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+		result := purger.db.Unscoped().Where("id IN ?", ids).Delete(reflect.New(modelType).Interface())
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+		if len(ids) < purger.batchSize {
+			return purged, nil
+		}
+
+		select {
+		case <-purger.stop:
+			return purged, nil
+		default:
+		}
+	}
+}
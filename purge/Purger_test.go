@@ -0,0 +1,130 @@
+package purge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/config"
+	"github.com/islax/microapp/model"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type purgeItem struct {
+	model.Base
+	Name string
+}
+
+type tenantPurgeItem struct {
+	model.TenantBase
+	Name string
+}
+
+// newTestDB opens an in-memory sqlite database and creates the tables of the models
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Each connection opens a new in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestNewPurgerWithInvalidConfig(t *testing.T) {
+	nopLogger := zerolog.Nop()
+	appConfig := config.NewConfig(nil)
+	appConfig.Set(config.EvSuffixForPurgeInterval, 0)
+	appConfig.Set(config.EvSuffixForPurgeBatchSize, -1)
+	purger := NewPurger(newTestDB(t), nil, &nopLogger, appConfig)
+	if purger.interval != defaultInterval || purger.batchSize != defaultBatchSize {
+		t.Errorf("Expected default interval and batch size, Actual [%v, %v]", purger.interval, purger.batchSize)
+	}
+}
+
+func newTestPurger(db *gorm.DB, policies []Policy, retentionDays, batchSize int) *Purger {
+	nopLogger := zerolog.Nop()
+	appConfig := config.NewConfig(nil)
+	appConfig.Set(config.EvSuffixForPurgeInterval, 1)
+	appConfig.Set(config.EvSuffixForPurgeBatchSize, batchSize)
+	appConfig.Set(config.EvSuffixForPurgeRetention, retentionDays)
+	return NewPurger(db, policies, &nopLogger, appConfig)
+}
+
+// deletedDaysAgo returns the deletedOn of a row soft deleted the given number of days ago, a zero day returns a row that is not deleted
+func deletedDaysAgo(days int) gorm.DeletedAt {
+	if days == 0 {
+		return gorm.DeletedAt{}
+	}
+	return gorm.DeletedAt{Time: time.Now().UTC().AddDate(0, 0, -days), Valid: true}
+}
+
+func TestPurgeRetention(t *testing.T) {
+	db := newTestDB(t, &purgeItem{})
+	items := []purgeItem{
+		{Base: model.Base{ID: uuid.NewV4(), DeletedAt: deletedDaysAgo(40)}, Name: "expired"},
+		{Base: model.Base{ID: uuid.NewV4(), DeletedAt: deletedDaysAgo(10)}, Name: "retained"},
+		{Base: model.Base{ID: uuid.NewV4()}, Name: "active"},
+	}
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	purger := newTestPurger(db, []Policy{{Model: &purgeItem{}}}, 30, 10)
+	if purged, err := purger.purge(purger.policies[0]); err != nil || purged != 1 {
+		t.Fatalf("Expected 1 row to be purged, Actual [%v] [%v]", purged, err)
+	}
+	var names []string
+	db.Unscoped().Model(&purgeItem{}).Order("name").Pluck("name", &names)
+	if len(names) != 2 || names[0] != "active" || names[1] != "retained" {
+		t.Errorf("Expected rows [active retained] to be kept, Actual %v", names)
+	}
+}
+
+func TestPurgeInBatches(t *testing.T) {
+	db := newTestDB(t, &purgeItem{})
+	items := make([]purgeItem, 5)
+	for i := range items {
+		items[i] = purgeItem{Base: model.Base{ID: uuid.NewV4(), DeletedAt: deletedDaysAgo(40)}}
+	}
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	var deletes int
+	db.Callback().Delete().After("gorm:delete").Register("test:count_deletes", func(*gorm.DB) { deletes++ })
+
+	purger := newTestPurger(db, []Policy{{Model: &purgeItem{}}}, 30, 2)
+	if purged, err := purger.purge(purger.policies[0]); err != nil || purged != 5 {
+		t.Fatalf("Expected 5 rows to be purged, Actual [%v] [%v]", purged, err)
+	}
+	if deletes != 3 {
+		t.Errorf("Expected rows to be purged in [3] batches of at most 2 rows, Actual [%v]", deletes)
+	}
+	var count int64
+	db.Unscoped().Model(&purgeItem{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no rows to be left, Actual [%v]", count)
+	}
+}
+
+func TestPurgeRetentionPerModel(t *testing.T) {
+	db := newTestDB(t, &tenantPurgeItem{})
+	if err := db.Create(&tenantPurgeItem{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: uuid.NewV4(), DeletedAt: deletedDaysAgo(10)}}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	purger := newTestPurger(db, []Policy{{Model: &tenantPurgeItem{}}, {Model: &tenantPurgeItem{}, RetentionDays: 5}}, 30, 10)
+	if purged, err := purger.purge(purger.policies[0]); err != nil || purged != 0 {
+		t.Errorf("Expected the row within the default retention to be kept, Actual [%v] [%v]", purged, err)
+	}
+	if purged, err := purger.purge(purger.policies[1]); err != nil || purged != 1 {
+		t.Errorf("Expected the row older than the retention of the model to be purged, Actual [%v] [%v]", purged, err)
+	}
+}
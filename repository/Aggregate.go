@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	microappError "github.com/islax/microapp/error"
+	"gorm.io/gorm"
+)
+
+// Aggregate functions supported by the metrics
+const (
+	AggregateCount = "count"
+	AggregateSum   = "sum"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+)
+
+var aggregateFunctions = map[string]string{
+	AggregateCount: "COUNT",
+	AggregateSum:   "SUM",
+	AggregateAvg:   "AVG",
+	AggregateMin:   "MIN",
+	AggregateMax:   "MAX",
+}
+
+// Metric is an aggregate of a column, e.g. SUM(amount) AS sum_amount
+type Metric struct {
+	Function string // one of Aggregate*
+	Column   string // db column, optional for count
+	Alias    string // name of the result column, function or function_column if empty
+}
+
+// Select restricts the columns retrieved by the query
+func Select(fields ...string) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, microappError.DatabaseError) {
+		return db.Select(fields), nil
+	}
+}
+
+// GroupBy groups the results by the columns
+func GroupBy(columns ...string) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, microappError.DatabaseError) {
+		for _, column := range columns {
+			db = db.Group(column)
+		}
+		return db, nil
+	}
+}
+
+// Having filters the grouped results
+func Having(condition string, args ...interface{}) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, microappError.DatabaseError) {
+		return db.Having(condition, args...), nil
+	}
+}
+
+// Metrics selects the group by columns and the metrics, to be used with Aggregate along with GroupBy
+func Metrics(groupByColumns []string, metrics ...Metric) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, microappError.DatabaseError) {
+		fields := make([]string, 0, len(groupByColumns)+len(metrics))
+		fields = append(fields, groupByColumns...)
+		for _, metric := range metrics {
+			field, err := metric.selectExpression()
+			if err != nil {
+				return db, microappError.NewDatabaseError(err)
+			}
+			fields = append(fields, field)
+		}
+		return db.Select(strings.Join(fields, ", ")), nil
+	}
+}
+
+func (metric Metric) selectExpression() (string, error) {
+	function, ok := aggregateFunctions[strings.ToLower(metric.Function)]
+	if !ok {
+		return "", fmt.Errorf("unsupported aggregate function [%v]", metric.Function)
+	}
+	column := metric.Column
+	if column == "" {
+		if function != "COUNT" {
+			return "", fmt.Errorf("column is required for aggregate function [%v]", metric.Function)
+		}
+		column = "*"
+	}
+	return fmt.Sprintf("%v(%v) AS %v", function, column, metric.alias()), nil
+}
+
+func (metric Metric) alias() string {
+	if metric.Alias != "" {
+		return metric.Alias
+	}
+	if metric.Column == "" {
+		return strings.ToLower(metric.Function)
+	}
+	return strings.ToLower(metric.Function) + "_" + metric.Column
+}
+
+// AggregateRepository is implemented by the repositories which can retrieve the aggregates, GormRepository implements it
+type AggregateRepository interface {
+	Aggregate(uow *UnitOfWork, model interface{}, out interface{}, queryProcessors []QueryProcessor) microappError.DatabaseError
+}
+
+// Aggregate retrieves the aggregates of the model as per the query processors, e.g. Metrics, GroupBy and Having, into out.
+// Out can be a slice of structs with the fields matching the result columns or *[]map[string]interface{}.
+func (repository *GormRepository) Aggregate(uow *UnitOfWork, model interface{}, out interface{}, queryProcessors []QueryProcessor) microappError.DatabaseError {
+	db := uow.DB.Model(model)
+	for _, queryProcessor := range queryProcessors {
+		var err microappError.DatabaseError
+		if db, err = queryProcessor(db, out); err != nil {
+			return err
+		}
+	}
+	if err := db.Scan(out).Error; err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+	// The text columns are scanned as bytes into the maps, convert them so that they are marshalled as strings
+	if rows, ok := out.(*[]map[string]interface{}); ok {
+		for _, row := range *rows {
+			for key, value := range row {
+				if bytes, isBytes := value.([]byte); isBytes {
+					row[key] = string(bytes)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// AddAggregationFromQueryParams parses the 'groupBy' and 'metrics' query params and creates the processors to be used with Aggregate,
+// e.g. groupBy=status,type&metrics=count,sum:amount,avg:duration. The metrics are function or function:attribute, count by default.
+// Only the given attributes can be used, they are mapped to the db column, the attribute is used if the column is empty.
+// The result columns are the group by attributes followed by the metrics named function or function_attribute.
+func AddAggregationFromQueryParams(r *http.Request, groupByAttributes map[string]string, metricAttributes map[string]string) ([]QueryProcessor, error) {
+	queryParams := r.URL.Query()
+
+	groupByColumns := make([]string, 0)
+	groupBySelects := make([]string, 0)
+	for _, attribute := range splitQueryParam(queryParams.Get("groupBy")) {
+		column, ok := groupByAttributes[attribute]
+		if !ok {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"groupBy": "Key_InvalidAttribute"})
+		}
+		groupBySelect := attribute
+		if column == "" {
+			column = attribute
+		} else if column != attribute {
+			groupBySelect = column + " AS " + attribute
+		}
+		groupByColumns = append(groupByColumns, column)
+		groupBySelects = append(groupBySelects, groupBySelect)
+	}
+
+	metrics := make([]Metric, 0)
+	for _, metricParam := range splitQueryParam(queryParams.Get("metrics")) {
+		functionAndAttribute := strings.SplitN(metricParam, ":", 2)
+		function := strings.ToLower(functionAndAttribute[0])
+		if _, ok := aggregateFunctions[function]; !ok {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"metrics": "Key_InvalidOperator"})
+		}
+		metric := Metric{Function: function, Alias: function}
+		if len(functionAndAttribute) == 2 {
+			attribute := functionAndAttribute[1]
+			column, ok := metricAttributes[attribute]
+			if !ok {
+				return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"metrics": "Key_InvalidAttribute"})
+			}
+			if column == "" {
+				column = attribute
+			}
+			metric.Column, metric.Alias = column, function+"_"+attribute
+		} else if function != AggregateCount {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"metrics": "Key_InvalidValue"})
+		}
+		metrics = append(metrics, metric)
+	}
+	if len(metrics) == 0 {
+		metrics = append(metrics, Metric{Function: AggregateCount})
+	}
+
+	queryProcessors := []QueryProcessor{Metrics(groupBySelects, metrics...)}
+	if len(groupByColumns) > 0 {
+		queryProcessors = append(queryProcessors, GroupBy(groupByColumns...))
+	}
+	return queryProcessors, nil
+}
+
+func splitQueryParam(value string) []string {
+	values := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type aggregatedEntity struct {
+	ID     string
+	Status string
+	Amount int
+}
+
+func TestAddAggregationFromQueryParams(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pwd@tcp(localhost:3306)/db", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupByAttributes := map[string]string{"status": "", "createdDate": "DATE(createdOn)"}
+	metricAttributes := map[string]string{"amount": ""}
+	tests := []struct {
+		name    string
+		query   string
+		wantSQL string
+		wantErr bool
+	}{
+		{"Default", "", "SELECT COUNT(*) AS count FROM `aggregated_entities`", false},
+		{"GroupBy", "groupBy=status,createdDate&metrics=count,sum:amount", "SELECT status, DATE(createdOn) AS createdDate, COUNT(*) AS count, SUM(amount) AS sum_amount FROM `aggregated_entities` GROUP BY `status`,DATE(createdOn)", false},
+		{"InvalidGroupBy", "groupBy=name", "", true},
+		{"InvalidFunction", "metrics=median:amount", "", true},
+		{"InvalidMetricAttribute", "metrics=sum:status", "", true},
+		{"MissingMetricAttribute", "metrics=sum", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queryProcessors, err := AddAggregationFromQueryParams(httptest.NewRequest("GET", "/?"+test.query, nil), groupByAttributes, metricAttributes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error [%v], Actual [%v]", test.wantErr, err)
+			}
+			if test.wantErr {
+				return
+			}
+			query := db.Model(&aggregatedEntity{})
+			for _, queryProcessor := range queryProcessors {
+				query, _ = queryProcessor(query, nil)
+			}
+			var rows []map[string]interface{}
+			if sql := query.Scan(&rows).Statement.SQL.String(); sql != test.wantSQL {
+				t.Errorf("Expected SQL [%v], Actual [%v]", test.wantSQL, sql)
+			}
+		})
+	}
+}
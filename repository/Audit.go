@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/model"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	auditPluginName = "microapp:audit"
+	auditBeforeKey  = "microapp:audit_before"
+	// maxAuditedRows is the max number of rows of an update or delete for which the changes are recorded
+	maxAuditedRows = 1000
+)
+
+type auditActorKey struct{}
+
+// AuditActor identifies who made the changes recorded in the audit log
+type AuditActor struct {
+	TenantID      uuid.UUID
+	UserID        uuid.UUID
+	UserName      string
+	CorrelationID string
+	Action        string
+}
+
+// AuditChange represents the old and the new value of a column
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// MigrateAuditLog creates or updates the audit log table
+func MigrateAuditLog(db *gorm.DB) error {
+	return db.AutoMigrate(&model.AuditLog{})
+}
+
+// WithAuditActor returns a context in which the changes of the auditable models are attributed to the actor
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// SetAuditActor attributes the changes of the auditable models made in the unit of work to the actor
+func (uow *UnitOfWork) SetAuditActor(actor AuditActor) {
+	uow.DB = uow.DB.WithContext(WithAuditActor(statementContext(uow.DB), actor))
+}
+
+// AuditTrail filters the audit logs of the entity, to be used with GetAll on []model.AuditLog
+func AuditTrail(entityType string, entityID string) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, microappError.DatabaseError) {
+		return db.Where("entityType = ? AND entityId = ?", entityType, entityID).Order("createdOn"), nil
+	}
+}
+
+// AuditPlugin is a gorm plugin which records the creates, updates and deletes of the models implementing model.Auditable in the audit log,
+// within the transaction of the unit of work. Updates and deletes read the affected rows before and after the change to record the changed columns,
+// changes of at most 1000 rows are recorded per statement. Raw and Exec queries are not recorded.
+type AuditPlugin struct {
+	auditableModels sync.Map // model type -> isAuditable
+}
+
+// NewAuditPlugin creates a new audit plugin, register it using gorm.DB.Use
+func NewAuditPlugin() *AuditPlugin {
+	return &AuditPlugin{}
+}
+
+// Name implements gorm.Plugin
+func (plugin *AuditPlugin) Name() string {
+	return auditPluginName
+}
+
+// Initialize implements gorm.Plugin
+func (plugin *AuditPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register(auditPluginName+":create", plugin.recordCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register(auditPluginName+":before_update", plugin.loadBefore); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register(auditPluginName+":update", plugin.recordUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register(auditPluginName+":before_delete", plugin.loadBefore); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register(auditPluginName+":delete", plugin.recordDelete)
+}
+
+func (plugin *AuditPlugin) isAuditable(modelSchema *schema.Schema) bool {
+	if modelSchema == nil || len(modelSchema.PrimaryFields) != 1 {
+		return false
+	}
+	if isAuditable, ok := plugin.auditableModels.Load(modelSchema.ModelType); ok {
+		return isAuditable.(bool)
+	}
+	auditable, ok := reflect.New(modelSchema.ModelType).Interface().(model.Auditable)
+	isAuditable := ok && auditable.IsAuditable()
+	plugin.auditableModels.Store(modelSchema.ModelType, isAuditable)
+	return isAuditable
+}
+
+func (plugin *AuditPlugin) recordCreate(db *gorm.DB) {
+	if db.Error != nil || !plugin.isAuditable(db.Statement.Schema) {
+		return
+	}
+	entries := make([]model.AuditLog, 0)
+	forEachEntity(db.Statement.ReflectValue, func(entity reflect.Value) {
+		entries = append(entries, newAuditLog(db, model.AuditOperationCreate, entity, auditChanges(db.Statement.Schema, reflect.Value{}, entity)))
+	})
+	writeAuditLogs(db, entries)
+}
+
+// loadBefore reads the rows to be updated or deleted by the statement
+func (plugin *AuditPlugin) loadBefore(db *gorm.DB) {
+	if db.Error != nil || !plugin.isAuditable(db.Statement.Schema) {
+		return
+	}
+	conditions := make([]clause.Expression, 0)
+	if where, ok := db.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conditions = append(conditions, where)
+	}
+	primaryField := db.Statement.Schema.PrioritizedPrimaryField
+	if primaryField == nil {
+		primaryField = db.Statement.Schema.PrimaryFields[0]
+	}
+	primaryKeys := make([]interface{}, 0)
+	forEachEntity(db.Statement.ReflectValue, func(entity reflect.Value) {
+		if primaryKey, isZero := primaryField.ValueOf(entity); !isZero {
+			primaryKeys = append(primaryKeys, primaryKey)
+		}
+	})
+	if len(primaryKeys) > 0 {
+		conditions = append(conditions, clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: primaryField.DBName}, Values: primaryKeys})
+	}
+	if len(conditions) == 0 {
+		return // gorm fails the statement without conditions
+	}
+
+	before := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	query := db.Session(&gorm.Session{NewDB: true}).Model(reflect.New(db.Statement.Schema.ModelType).Interface())
+	if db.Statement.Unscoped {
+		query = query.Unscoped()
+	}
+	if err := query.Clauses(conditions...).Limit(maxAuditedRows).Find(before.Interface()).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	db.Statement.Settings.Store(auditBeforeKey, before.Elem())
+}
+
+func (plugin *AuditPlugin) recordUpdate(db *gorm.DB) {
+	before, ok := loadedBefore(db)
+	if !ok {
+		return
+	}
+	primaryField := db.Statement.Schema.PrioritizedPrimaryField
+	if primaryField == nil {
+		primaryField = db.Statement.Schema.PrimaryFields[0]
+	}
+	primaryKeys := make([]interface{}, before.Len())
+	for i := 0; i < before.Len(); i++ {
+		primaryKeys[i], _ = primaryField.ValueOf(before.Index(i))
+	}
+	after := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	query := db.Session(&gorm.Session{NewDB: true}).Model(reflect.New(db.Statement.Schema.ModelType).Interface()).Unscoped()
+	if err := query.Clauses(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: primaryField.DBName}, Values: primaryKeys}).Find(after.Interface()).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	afterByKey := make(map[string]reflect.Value, after.Elem().Len())
+	for i := 0; i < after.Elem().Len(); i++ {
+		primaryKey, _ := primaryField.ValueOf(after.Elem().Index(i))
+		afterByKey[fmt.Sprint(primaryKey)] = after.Elem().Index(i)
+	}
+
+	entries := make([]model.AuditLog, 0, before.Len())
+	for i := 0; i < before.Len(); i++ {
+		afterEntity, ok := afterByKey[fmt.Sprint(primaryKeys[i])]
+		if !ok {
+			continue
+		}
+		if changes := auditChanges(db.Statement.Schema, before.Index(i), afterEntity); len(changes) > 0 {
+			entries = append(entries, newAuditLog(db, model.AuditOperationUpdate, afterEntity, changes))
+		}
+	}
+	writeAuditLogs(db, entries)
+}
+
+func (plugin *AuditPlugin) recordDelete(db *gorm.DB) {
+	before, ok := loadedBefore(db)
+	if !ok {
+		return
+	}
+	entries := make([]model.AuditLog, 0, before.Len())
+	for i := 0; i < before.Len(); i++ {
+		entries = append(entries, newAuditLog(db, model.AuditOperationDelete, before.Index(i), auditChanges(db.Statement.Schema, before.Index(i), reflect.Value{})))
+	}
+	writeAuditLogs(db, entries)
+}
+
+func loadedBefore(db *gorm.DB) (reflect.Value, bool) {
+	value, ok := db.Statement.Settings.Load(auditBeforeKey)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return reflect.Value{}, false
+	}
+	before := value.(reflect.Value)
+	return before, before.Len() > 0
+}
+
+// auditChanges returns the changed columns, before or after is invalid for create and delete respectively.
+// The modification time and the columns tagged with `audit:"-"` are not recorded.
+func auditChanges(modelSchema *schema.Schema, before reflect.Value, after reflect.Value) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	for _, field := range modelSchema.Fields {
+		if field.DBName == "" || field.AutoUpdateTime > 0 || field.Tag.Get("audit") == "-" {
+			continue
+		}
+		var oldValue, newValue interface{}
+		isZero := true // zero values are not recorded for create and delete
+		if before.IsValid() {
+			oldValue, isZero = field.ValueOf(before)
+		}
+		if after.IsValid() {
+			var newIsZero bool
+			newValue, newIsZero = field.ValueOf(after)
+			isZero = isZero && newIsZero
+		}
+		if isZero || reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes[field.DBName] = AuditChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+func newAuditLog(db *gorm.DB, operation string, entity reflect.Value, changes map[string]AuditChange) model.AuditLog {
+	auditLog := model.AuditLog{ID: uuid.NewV4(), Operation: operation, EntityType: db.Statement.Schema.Name, CreatedAt: time.Now().UTC()}
+	if actor, ok := statementContext(db).Value(auditActorKey{}).(AuditActor); ok {
+		auditLog.TenantID, auditLog.UserID, auditLog.UserName = actor.TenantID, actor.UserID, actor.UserName
+		auditLog.CorrelationID, auditLog.Action = actor.CorrelationID, actor.Action
+	}
+	if tenantField := db.Statement.Schema.LookUpField("TenantID"); tenantField != nil {
+		if tenantID, ok := tenantField.ReflectValueOf(entity).Interface().(uuid.UUID); ok && tenantID != uuid.Nil {
+			auditLog.TenantID = tenantID
+		}
+	}
+	primaryKey, _ := db.Statement.Schema.PrimaryFields[0].ValueOf(entity)
+	auditLog.EntityID = fmt.Sprint(primaryKey)
+	if body, err := json.Marshal(changes); err == nil {
+		auditLog.Changes = string(body)
+	} else {
+		db.AddError(err)
+	}
+	return auditLog
+}
+
+// writeAuditLogs stores the audit logs in the transaction of the statement, the statement fails if they cannot be stored
+func writeAuditLogs(db *gorm.DB, entries []model.AuditLog) {
+	if len(entries) == 0 || db.Error != nil {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+		db.AddError(err)
+	}
+}
+
+func forEachEntity(value reflect.Value, apply func(entity reflect.Value)) {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			apply(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		apply(value)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/islax/microapp/model"
+	"gorm.io/gorm/schema"
+)
+
+type auditedEntity struct {
+	model.Base
+	Name   string
+	Secret string `audit:"-"`
+	Count  int
+}
+
+func (auditedEntity) IsAuditable() bool { return true }
+
+func TestAuditChanges(t *testing.T) {
+	entitySchema, err := schema.Parse(&auditedEntity{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		before *auditedEntity
+		after  *auditedEntity
+		want   map[string]AuditChange
+	}{
+		{"Create", nil, &auditedEntity{Name: "a", Secret: "s"}, map[string]AuditChange{"name": {nil, "a"}}},
+		{"Update", &auditedEntity{Name: "a", Count: 1}, &auditedEntity{Name: "b", Secret: "s", Count: 1}, map[string]AuditChange{"name": {"a", "b"}}},
+		{"UpdateToZero", &auditedEntity{Name: "a", Count: 1}, &auditedEntity{Name: "a"}, map[string]AuditChange{"count": {1, 0}}},
+		{"Delete", &auditedEntity{Count: 2}, nil, map[string]AuditChange{"count": {2, nil}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var before, after reflect.Value
+			if test.before != nil {
+				before = reflect.ValueOf(test.before).Elem()
+			}
+			if test.after != nil {
+				after = reflect.ValueOf(test.after).Elem()
+			}
+			if changes := auditChanges(entitySchema, before, after); !reflect.DeepEqual(changes, test.want) {
+				t.Errorf("Expected changes %v, Actual %v", test.want, changes)
+			}
+		})
+	}
+}
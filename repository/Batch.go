@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+
+	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultBatchSize number of entities inserted per statement by AddBatch and UpsertBatch if the batch size is not specified
+const DefaultBatchSize = 100
+
+// UpsertOptions options of UpsertBatch
+type UpsertOptions struct {
+	ConflictColumns []string // columns of the unique key identifying the existing rows, primary key if empty. Ignored by MySQL which uses all the unique keys
+	UpdateColumns   []string // columns updated for the existing rows, all columns except the primary key, the creation time and the conflict columns if empty. The version of the models embedding model.Versioned is incremented instead
+	BatchSize       int      // number of entities per statement, DefaultBatchSize if zero
+}
+
+// BatchRepository is implemented by the repositories supporting the batch inserts and set based updates, GormRepository implements it
+type BatchRepository interface {
+	AddBatch(uow *UnitOfWork, entities interface{}, batchSize int) (int64, microappError.DatabaseError)
+	UpsertBatch(uow *UnitOfWork, entities interface{}, options UpsertOptions) (int64, microappError.DatabaseError)
+	UpdateWhere(uow *UnitOfWork, model interface{}, values interface{}, queryProcessors []QueryProcessor) (int64, microappError.DatabaseError)
+}
+
+// AddBatch inserts the slice of entities using multi-row inserts of the batch size and returns the number of inserted rows
+func (repository *GormRepository) AddBatch(uow *UnitOfWork, entities interface{}, batchSize int) (int64, microappError.DatabaseError) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	result := uow.DB.CreateInBatches(entities, batchSize)
+	if result.Error != nil {
+		return 0, microappError.NewDatabaseError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// UpsertBatch inserts the slice of entities and updates the existing rows in a single statement per batch, using ON DUPLICATE KEY UPDATE for MySQL
+// and ON CONFLICT for the other databases, so that concurrent upserts do not race. It returns the number of affected rows as reported by the database,
+// MySQL reports 1 for each inserted row and 2 for each updated row. The version of the existing rows of the models embedding model.Versioned is incremented.
+func (repository *GormRepository) UpsertBatch(uow *UnitOfWork, entities interface{}, options UpsertOptions) (int64, microappError.DatabaseError) {
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	statement := &gorm.Statement{DB: uow.DB}
+	if err := statement.Parse(entities); err != nil {
+		return 0, microappError.NewDatabaseError(err)
+	}
+	conflictColumns := options.ConflictColumns
+	if len(conflictColumns) == 0 {
+		conflictColumns = statement.Schema.PrimaryFieldDBNames
+	}
+	updateColumns := options.UpdateColumns
+	if len(updateColumns) == 0 {
+		updateColumns = upsertUpdateColumns(statement.Schema, conflictColumns)
+	}
+
+	_, versioned := reflect.New(statement.Schema.ModelType).Interface().(model.VersionedEntity)
+	if versioned {
+		updateColumns = withoutColumn(updateColumns, "version")
+	}
+
+	onConflict := clause.OnConflict{DoUpdates: clause.AssignmentColumns(updateColumns)}
+	if versioned {
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{Column: clause.Column{Name: "version"}, Value: clause.Expr{SQL: "? + 1", Vars: []interface{}{clause.Column{Name: "version"}}}})
+	}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+
+	result := uow.DB.Clauses(onConflict).CreateInBatches(entities, batchSize)
+	if result.Error != nil {
+		return 0, microappError.NewDatabaseError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// upsertUpdateColumns returns the columns updated for the existing rows by default, i.e. all the columns except the primary key, the creation time and the conflict columns
+func upsertUpdateColumns(modelSchema *schema.Schema, conflictColumns []string) []string {
+	excludedColumns := map[string]bool{"createdOn": true}
+	for _, column := range conflictColumns {
+		excludedColumns[column] = true
+	}
+	columns := make([]string, 0, len(modelSchema.DBNames))
+	for _, field := range modelSchema.Fields {
+		if field.DBName == "" || !field.Creatable || field.PrimaryKey || field.AutoCreateTime != 0 || excludedColumns[field.DBName] {
+			continue
+		}
+		columns = append(columns, field.DBName)
+	}
+	return columns
+}
+
+func withoutColumn(columns []string, excludedColumn string) []string {
+	filteredColumns := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column != excludedColumn {
+			filteredColumns = append(filteredColumns, column)
+		}
+	}
+	return filteredColumns
+}
+
+// UpdateWhere updates the rows of the model matching the query processors with the values in a single statement and returns the number of updated rows.
+// Values can be a map of column to value or a struct, zero values of the struct are not updated. Updating without any query processor is not allowed,
+// the condition added by the tenant scope would otherwise allow updating all the rows of the tenant.
+func (repository *GormRepository) UpdateWhere(uow *UnitOfWork, entity interface{}, values interface{}, queryProcessors []QueryProcessor) (int64, microappError.DatabaseError) {
+	if len(queryProcessors) == 0 {
+		return 0, microappError.NewDatabaseError(errors.New("update without condition is not allowed"))
+	}
+	db := uow.DB.Model(entity)
+	var err microappError.DatabaseError
+	for _, queryProcessor := range queryProcessors {
+		db, err = queryProcessor(db, entity)
+		if err != nil {
+			return 0, err
+		}
+	}
+	result := db.Updates(values)
+	if result.Error != nil {
+		return 0, microappError.NewDatabaseError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/islax/microapp/model"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+)
+
+type batchItem struct {
+	model.Base
+	Code string `gorm:"uniqueIndex"`
+	Name string
+}
+
+func TestAddBatch(t *testing.T) {
+	db := newTestDB(t, nil, &batchItem{})
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+
+	items := []batchItem{{Base: model.Base{ID: uuid.NewV4()}, Code: "a"}, {Base: model.Base{ID: uuid.NewV4()}, Code: "b"}, {Base: model.Base{ID: uuid.NewV4()}, Code: "c"}}
+	if added, err := NewRepository().(BatchRepository).AddBatch(uow, &items, 2); err != nil || added != 3 {
+		t.Fatalf("Expected 3 rows to be added, Actual [%v] [%v]", added, err)
+	}
+	var count int64
+	uow.DB.Model(&batchItem{}).Count(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 rows, Actual [%v]", count)
+	}
+}
+
+func TestUpsertBatch(t *testing.T) {
+	db := newTestDB(t, nil, &batchItem{})
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+	repository := NewRepository().(BatchRepository)
+
+	createdOn := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	existing := batchItem{Base: model.Base{ID: uuid.NewV4(), CreatedAt: createdOn}, Code: "a", Name: "old"}
+	if err := uow.DB.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("PrimaryKey", func(t *testing.T) {
+		items := []batchItem{
+			{Base: model.Base{ID: existing.ID}, Code: "a", Name: "new"},
+			{Base: model.Base{ID: uuid.NewV4()}, Code: "b", Name: "added"},
+		}
+		if _, err := repository.UpsertBatch(uow, &items, UpsertOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		var updated batchItem
+		uow.DB.First(&updated, "id = ?", existing.ID)
+		if updated.Name != "new" || !updated.CreatedAt.Equal(createdOn) {
+			t.Errorf("Expected the name to be updated and the creation time to be kept, Actual [%v] [%v]", updated.Name, updated.CreatedAt)
+		}
+		var count int64
+		uow.DB.Model(&batchItem{}).Count(&count)
+		if count != 2 {
+			t.Errorf("Expected the new item to be added, Actual count [%v]", count)
+		}
+	})
+
+	t.Run("ConflictColumns", func(t *testing.T) {
+		items := []batchItem{{Base: model.Base{ID: uuid.NewV4()}, Code: "a", Name: "by code"}}
+		if _, err := repository.UpsertBatch(uow, &items, UpsertOptions{ConflictColumns: []string{"code"}}); err != nil {
+			t.Fatal(err)
+		}
+		var updated batchItem
+		uow.DB.First(&updated, "code = ?", "a")
+		if updated.ID != existing.ID || updated.Name != "by code" || !updated.CreatedAt.Equal(createdOn) {
+			t.Errorf("Expected the name to be updated and the id and creation time to be kept, Actual [%v] [%v] [%v]", updated.ID, updated.Name, updated.CreatedAt)
+		}
+	})
+}
+
+func TestUpdateWhere(t *testing.T) {
+	db := newTestDB(t, nil, &batchItem{})
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+	repository := NewRepository().(BatchRepository)
+
+	items := []batchItem{{Base: model.Base{ID: uuid.NewV4()}, Code: "a"}, {Base: model.Base{ID: uuid.NewV4()}, Code: "b"}, {Base: model.Base{ID: uuid.NewV4()}, Code: "c"}}
+	if err := uow.DB.Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repository.UpdateWhere(uow, &batchItem{}, map[string]interface{}{"name": "x"}, []QueryProcessor{Filter("code IN ?", []string{"a", "b"})})
+	if err != nil || updated != 2 {
+		t.Fatalf("Expected 2 rows to be updated, Actual [%v] [%v]", updated, err)
+	}
+	var count int64
+	uow.DB.Model(&batchItem{}).Where("name = ?", "x").Count(&count)
+	if count != 2 {
+		t.Errorf("Expected only the matching rows to be updated, Actual [%v]", count)
+	}
+
+	for _, queryProcessors := range [][]QueryProcessor{nil, {}} {
+		if _, err = repository.UpdateWhere(uow, &batchItem{}, map[string]interface{}{"name": "y"}, queryProcessors); err == nil {
+			t.Errorf("Expected the update without condition %#v to fail", queryProcessors)
+		}
+	}
+}
+
+func TestUpdateWhereWithTenantScope(t *testing.T) {
+	db := newTestDB(t, []gorm.Plugin{NewTenantScopePlugin()}, &tenantItem{})
+	tenantID := uuid.NewV4()
+	if err := db.Create(&tenantItem{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: tenantID}, Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+	uow.SetTenant(tenantID)
+
+	if _, err := NewRepository().(BatchRepository).UpdateWhere(uow, &tenantItem{}, map[string]interface{}{"name": "x"}, []QueryProcessor{}); err == nil {
+		t.Error("Expected the update without condition to fail even though the tenant scope adds one")
+	}
+	var count int64
+	uow.DB.Model(&tenantItem{}).Where("name = ?", "x").Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no rows to be updated, Actual [%v]", count)
+	}
+}
+
+func TestUpsertBatchIncrementsVersion(t *testing.T) {
+	db := newTestDB(t, nil, &versionedItem{})
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+
+	existing := versionedItem{Base: model.Base{ID: uuid.NewV4()}, Versioned: model.Versioned{Version: 3}, Name: "old"}
+	if err := uow.DB.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range []UpsertOptions{{}, {UpdateColumns: []string{"name", "version"}}} {
+		items := []versionedItem{{Base: model.Base{ID: existing.ID}, Name: "new"}, {Base: model.Base{ID: uuid.NewV4()}, Name: "added"}}
+		if _, err := NewRepository().(BatchRepository).UpsertBatch(uow, &items, options); err != nil {
+			t.Fatal(err)
+		}
+		var updated versionedItem
+		uow.DB.First(&updated, "id = ?", existing.ID)
+		if updated.Name != "new" || updated.Version != existing.Version+1 {
+			t.Errorf("Expected the name to be updated and the version to be incremented to [%v], Actual [%v] [%v]", existing.Version+1, updated.Name, updated.Version)
+		}
+		existing.Version = updated.Version
+		var added versionedItem
+		uow.DB.First(&added, "id = ?", items[1].ID)
+		if added.Version != 0 {
+			t.Errorf("Expected the added row to have the initial version [0], Actual [%v]", added.Version)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	microappError "github.com/islax/microapp/error"
+	"gorm.io/gorm"
+)
+
+const (
+	// CountNone does not count the total records
+	CountNone = "none"
+	// CountExact counts the total records matching the query processors
+	CountExact = "exact"
+	// CountEstimated uses the estimated number of rows in the table from the table statistics, the query processors are not considered
+	CountEstimated = "estimated"
+
+	// DefaultCursorPageSize is the page size used when the limit is not specified
+	DefaultCursorPageSize = 50
+	// MaxCursorPageSize is the maximum page size accepted by CursorPaginateForWeb
+	MaxCursorPageSize = 1000
+)
+
+// CursorPagination represents keyset pagination of the records. The records are ordered by the sort columns followed by id as the tiebreaker,
+// the cursor encodes the values of these columns of the last (next) or first (previous) record of the page.
+// The sort columns must not be nullable and must be the columns of the table of the entity.
+type CursorPagination struct {
+	SortColumns []SortColumn
+	Limit       int
+	Cursor      string // Cursor returned as NextCursor or PrevCursor of the previous page, empty for the first page
+	CountMode   string // CountNone (default), CountExact or CountEstimated
+
+	NextCursor            string // Populated by GetAllWithCursor, empty if there is no next page
+	PrevCursor            string // Populated by GetAllWithCursor, empty if there is no previous page
+	TotalCount            *int64 // Populated by GetAllWithCursor if count mode is other than CountNone
+	IsTotalCountEstimated bool
+
+	onComplete func(pagination *CursorPagination)
+}
+
+type cursor struct {
+	Columns  []string      `json:"c"`
+	Values   []cursorValue `json:"v"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// cursorValue retains the type of the time values, which would otherwise be compared as strings
+type cursorValue struct {
+	Time  *time.Time  `json:"t,omitempty"`
+	Value interface{} `json:"v,omitempty"`
+}
+
+// NewCursorPagination creates a new cursor pagination
+func NewCursorPagination(sortColumns []SortColumn, limit int, cursor string) *CursorPagination {
+	return &CursorPagination{SortColumns: sortColumns, Limit: limit, Cursor: cursor, CountMode: CountNone}
+}
+
+// CursorPaginateForWeb takes limit, cursor and count (none, exact or estimated) parameters from URL.
+// Once the page is retrieved using GetAllWithCursor, Link header (RFC 5988) with next / prev links, X-Next-Cursor, X-Prev-Cursor and
+// X-Total-Count or X-Estimated-Total-Count headers are set in the response.
+func CursorPaginateForWeb(w http.ResponseWriter, r *http.Request, sortColumns []SortColumn) (*CursorPagination, error) {
+	queryParams := r.URL.Query()
+
+	limit := DefaultCursorPageSize
+	if limitParam := queryParams.Get("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 || limit > MaxCursorPageSize {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"limit": "Key_InvalidValue"})
+		}
+	}
+
+	pagination := NewCursorPagination(sortColumns, limit, queryParams.Get("cursor"))
+	switch countMode := strings.ToLower(queryParams.Get("count")); countMode {
+	case "", CountNone, "false":
+	case CountExact, "true":
+		pagination.CountMode = CountExact
+	case CountEstimated:
+		pagination.CountMode = CountEstimated
+	default:
+		return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"count": "Key_InvalidValue"})
+	}
+	if _, err := pagination.decodeCursor(); err != nil {
+		return nil, err
+	}
+
+	pagination.onComplete = func(pagination *CursorPagination) {
+		exposedHeaders := []string{"Link", "X-Next-Cursor", "X-Prev-Cursor"}
+		links := make([]string, 0, 2)
+		if pagination.NextCursor != "" {
+			links = append(links, fmt.Sprintf(`<%v>; rel="next"`, cursorURL(r, pagination.NextCursor)))
+			w.Header().Set("X-Next-Cursor", pagination.NextCursor)
+		}
+		if pagination.PrevCursor != "" {
+			links = append(links, fmt.Sprintf(`<%v>; rel="prev"`, cursorURL(r, pagination.PrevCursor)))
+			w.Header().Set("X-Prev-Cursor", pagination.PrevCursor)
+		}
+		if len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+		if pagination.TotalCount != nil {
+			countHeader := "X-Total-Count"
+			if pagination.IsTotalCountEstimated {
+				countHeader = "X-Estimated-Total-Count"
+			}
+			exposedHeaders = append(exposedHeaders, countHeader)
+			w.Header().Set(countHeader, strconv.FormatInt(*pagination.TotalCount, 10))
+		}
+		w.Header().Add("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+	}
+	return pagination, nil
+}
+
+func cursorURL(r *http.Request, cursor string) string {
+	queryParams := r.URL.Query()
+	queryParams.Set("cursor", cursor)
+	return r.URL.Path + "?" + queryParams.Encode()
+}
+
+// CursorRepository is implemented by the repositories supporting the cursor pagination, GormRepository implements it
+type CursorRepository interface {
+	GetAllWithCursor(uow *UnitOfWork, out interface{}, pagination *CursorPagination, queryProcessors []QueryProcessor) microappError.DatabaseError
+}
+
+// GetAllWithCursor retrieves a page of the records for the specified entity as per the cursor pagination and populates the cursors of next and previous pages.
+// The query processors must not order or paginate the records.
+func (repository *GormRepository) GetAllWithCursor(uow *UnitOfWork, out interface{}, pagination *CursorPagination, queryProcessors []QueryProcessor) microappError.DatabaseError {
+	db := uow.DB
+
+	if queryProcessors != nil {
+		var err error
+		for _, queryProcessor := range queryProcessors {
+			db, err = queryProcessor(db, out)
+			if err != nil {
+				return microappError.NewDatabaseError(err)
+			}
+		}
+	}
+
+	if err := pagination.count(db, out); err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+
+	currentCursor, err := pagination.decodeCursor()
+	if err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+	sortColumns := pagination.sortColumnsWithTiebreaker()
+	backward := currentCursor != nil && currentCursor.Backward
+	if currentCursor != nil {
+		condition, values := keysetCondition(sortColumns, currentCursor.Values, backward)
+		db = db.Where(condition, values...)
+	}
+	for _, sortColumn := range sortColumns {
+		db = db.Order(orderExpression(sortColumn, backward))
+	}
+
+	limit := pagination.Limit
+	if limit <= 0 {
+		limit = DefaultCursorPageSize
+	}
+	// An extra record is retrieved to find whether there are more records
+	if err := db.Limit(limit + 1).Find(out).Error; err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+
+	records := reflect.ValueOf(out)
+	for records.Kind() == reflect.Ptr {
+		records = records.Elem()
+	}
+	if records.Kind() != reflect.Slice {
+		return microappError.NewDatabaseError(errors.New("cursor pagination requires a pointer to slice"))
+	}
+	hasMore := records.Len() > limit
+	if hasMore {
+		records.Set(records.Slice(0, limit))
+	}
+	if backward { // Records before the cursor are retrieved in reverse order
+		swap := reflect.Swapper(records.Interface())
+		for i, j := 0, records.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	pagination.NextCursor, pagination.PrevCursor = "", ""
+	if records.Len() > 0 {
+		if hasMore || backward {
+			if pagination.NextCursor, err = pagination.encodeCursor(db, records.Index(records.Len()-1), false); err != nil {
+				return microappError.NewDatabaseError(err)
+			}
+		}
+		if (backward && hasMore) || (!backward && currentCursor != nil) {
+			if pagination.PrevCursor, err = pagination.encodeCursor(db, records.Index(0), true); err != nil {
+				return microappError.NewDatabaseError(err)
+			}
+		}
+	}
+
+	if pagination.onComplete != nil {
+		pagination.onComplete(pagination)
+	}
+	return nil
+}
+
+// count populates the total count as per the count mode
+func (pagination *CursorPagination) count(db *gorm.DB, out interface{}) error {
+	var totalCount int64
+	switch pagination.CountMode {
+	case CountExact:
+		if err := db.Session(&gorm.Session{}).Model(out).Count(&totalCount).Error; err != nil {
+			return err
+		}
+	case CountEstimated:
+		statement := &gorm.Statement{DB: db}
+		if err := statement.Parse(out); err != nil {
+			return err
+		}
+		if err := db.Session(&gorm.Session{NewDB: true}).Raw("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", statement.Schema.Table).Scan(&totalCount).Error; err != nil {
+			return err
+		}
+		pagination.IsTotalCountEstimated = true
+	default:
+		return nil
+	}
+	pagination.TotalCount = &totalCount
+	return nil
+}
+
+func (pagination *CursorPagination) sortColumnsWithTiebreaker() []SortColumn {
+	sortColumns := make([]SortColumn, 0, len(pagination.SortColumns)+1)
+	tiebreakerDirection := "ASC"
+	for _, sortColumn := range pagination.SortColumns {
+		if sortColumn.Column == "id" {
+			return append(sortColumns, sortColumn) // Records are unique by id, the columns after it do not affect the order
+		}
+		sortColumns = append(sortColumns, sortColumn)
+		if sortColumn.IsDescending() {
+			tiebreakerDirection = "DESC"
+		} else {
+			tiebreakerDirection = "ASC"
+		}
+	}
+	return append(sortColumns, SortColumn{Column: "id", Direction: tiebreakerDirection})
+}
+
+func (pagination *CursorPagination) columnNames() []string {
+	sortColumns := pagination.sortColumnsWithTiebreaker()
+	columns := make([]string, len(sortColumns))
+	for i, sortColumn := range sortColumns {
+		columns[i] = sortColumn.Column
+	}
+	return columns
+}
+
+// decodeCursor returns the decoded cursor, nil if the cursor is empty
+func (pagination *CursorPagination) decodeCursor() (*cursor, error) {
+	if pagination.Cursor == "" {
+		return nil, nil
+	}
+	invalidCursorError := microappError.NewValidationError("Key_InvalidFields", map[string]string{"cursor": "Key_InvalidValue"})
+
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(pagination.Cursor)
+	if err != nil {
+		return nil, invalidCursorError
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(cursorJSON)))
+	decoder.UseNumber()
+	decodedCursor := &cursor{}
+	if err := decoder.Decode(decodedCursor); err != nil {
+		return nil, invalidCursorError
+	}
+
+	// The cursor must have been created for the same order
+	columns := pagination.columnNames()
+	if len(decodedCursor.Columns) != len(columns) || len(decodedCursor.Values) != len(columns) {
+		return nil, invalidCursorError
+	}
+	for i, column := range columns {
+		if decodedCursor.Columns[i] != column {
+			return nil, invalidCursorError
+		}
+	}
+	return decodedCursor, nil
+}
+
+// encodeCursor creates the cursor from the sort column values of the given record
+func (pagination *CursorPagination) encodeCursor(db *gorm.DB, record reflect.Value, backward bool) (string, error) {
+	statement := &gorm.Statement{DB: db}
+	if err := statement.Parse(record.Interface()); err != nil {
+		return "", err
+	}
+	for record.Kind() == reflect.Ptr {
+		record = record.Elem()
+	}
+
+	columns := pagination.columnNames()
+	newCursor := &cursor{Columns: columns, Values: make([]cursorValue, len(columns)), Backward: backward}
+	for i, column := range columns {
+		fieldName := column
+		if index := strings.LastIndex(fieldName, "."); index != -1 { // table.column
+			fieldName = fieldName[index+1:]
+		}
+		field := statement.Schema.LookUpField(fieldName)
+		if field == nil {
+			return "", fmt.Errorf("sort column [%v] not found in [%v]", column, statement.Schema.Name)
+		}
+		value, _ := field.ValueOf(record)
+		switch typedValue := value.(type) {
+		case time.Time:
+			newCursor.Values[i] = cursorValue{Time: &typedValue}
+		case *time.Time:
+			newCursor.Values[i] = cursorValue{Time: typedValue}
+		default:
+			newCursor.Values[i] = cursorValue{Value: value}
+		}
+	}
+
+	cursorJSON, err := json.Marshal(newCursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJSON), nil
+}
+
+// keysetCondition creates the condition (c1 > v1) OR (c1 = v1 AND c2 > v2) ... for the records after (or before, if backward) the cursor values
+func keysetCondition(sortColumns []SortColumn, cursorValues []cursorValue, backward bool) (string, []interface{}) {
+	conditions := make([]string, 0, len(sortColumns))
+	values := make([]interface{}, 0)
+	for i, sortColumn := range sortColumns {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, sortColumns[j].Column+" = ?")
+			values = append(values, cursorValues[j].value())
+		}
+		operator := ">"
+		if sortColumn.IsDescending() != backward {
+			operator = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%v %v ?", sortColumn.Column, operator))
+		values = append(values, cursorValues[i].value())
+		conditions = append(conditions, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", values
+}
+
+func orderExpression(sortColumn SortColumn, backward bool) string {
+	if sortColumn.IsDescending() != backward {
+		return sortColumn.Column + " DESC"
+	}
+	return sortColumn.Column + " ASC"
+}
+
+func (value cursorValue) value() interface{} {
+	if value.Time != nil {
+		return value.Time.UTC()
+	}
+	if number, ok := value.Value.(json.Number); ok {
+		return number.String()
+	}
+	return value.Value
+}
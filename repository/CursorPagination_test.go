@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeysetCondition(t *testing.T) {
+	createdOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		sortColumns    []SortColumn
+		cursorValues   []cursorValue
+		backward       bool
+		wantCondition  string
+		wantValueCount int
+	}{
+		{"IdOnly", []SortColumn{{Column: "id"}}, []cursorValue{{Value: "a"}}, false, "((id > ?))", 1},
+		{"IdOnlyBackward", []SortColumn{{Column: "id"}}, []cursorValue{{Value: "a"}}, true, "((id < ?))", 1},
+		{"DescendingWithTiebreaker", []SortColumn{{Column: "createdOn", Direction: "DESC"}, {Column: "id", Direction: "DESC"}}, []cursorValue{{Time: &createdOn}, {Value: "a"}}, false, "((createdOn < ?) OR (createdOn = ? AND id < ?))", 3},
+		{"MixedBackward", []SortColumn{{Column: "name"}, {Column: "createdOn", Direction: "DESC"}, {Column: "id", Direction: "DESC"}}, []cursorValue{{Value: "n"}, {Time: &createdOn}, {Value: "a"}}, true, "((name < ?) OR (name = ? AND createdOn > ?) OR (name = ? AND createdOn = ? AND id > ?))", 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			condition, values := keysetCondition(test.sortColumns, test.cursorValues, test.backward)
+			if condition != test.wantCondition {
+				t.Errorf("Expected condition [%v], Actual [%v]", test.wantCondition, condition)
+			}
+			if len(values) != test.wantValueCount {
+				t.Errorf("Expected [%v] values, Actual [%v]", test.wantValueCount, len(values))
+			}
+		})
+	}
+}
+
+func TestSortColumnsWithTiebreaker(t *testing.T) {
+	tests := []struct {
+		name        string
+		sortColumns []SortColumn
+		want        []SortColumn
+	}{
+		{"NoSortColumns", nil, []SortColumn{{Column: "id", Direction: "ASC"}}},
+		{"Descending", []SortColumn{{Column: "name", Direction: "DESC"}}, []SortColumn{{Column: "name", Direction: "DESC"}, {Column: "id", Direction: "DESC"}}},
+		{"IdInBetween", []SortColumn{{Column: "id"}, {Column: "name"}}, []SortColumn{{Column: "id"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NewCursorPagination(test.sortColumns, 10, "").sortColumnsWithTiebreaker()
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %v, Actual %v", test.want, got)
+			}
+		})
+	}
+}
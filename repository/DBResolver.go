@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+const replicaPingTimeout = 2 * time.Second
+
+// DBResolverOptions options of the DBResolver
+type DBResolverOptions struct {
+	HealthCheckInterval  time.Duration // interval of the replica health checks, 10 seconds if zero
+	ReadYourWritesWindow time.Duration // duration for which the reads are routed to the primary after a write, zero disables it
+}
+
+// DBResolver routes the reads to the healthy read replicas in round-robin, falling back to the primary if none is healthy.
+// Replicas failing the health check are ejected till they pass it again.
+type DBResolver struct {
+	primary  *gorm.DB
+	replicas []*replica
+	next     uint32
+	options  DBResolverOptions
+	logger   zerolog.Logger
+
+	pinMutex sync.Mutex
+	pins     map[string]time.Time // pin key -> pinned till
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type replica struct {
+	db      *gorm.DB
+	name    string
+	healthy int32
+}
+
+// NewDBResolver creates a resolver for the primary and the read replicas, the replicas are considered unhealthy till Start checks them
+func NewDBResolver(primary *gorm.DB, replicas map[string]*gorm.DB, options DBResolverOptions, logger zerolog.Logger) *DBResolver {
+	if options.HealthCheckInterval <= 0 {
+		options.HealthCheckInterval = 10 * time.Second
+	}
+	resolver := &DBResolver{primary: primary, options: options, logger: logger, pins: make(map[string]time.Time), stop: make(chan struct{})}
+	for name, db := range replicas {
+		resolver.replicas = append(resolver.replicas, &replica{db: db, name: name})
+	}
+	return resolver
+}
+
+// Primary returns the primary database
+func (resolver *DBResolver) Primary() *gorm.DB {
+	return resolver.primary
+}
+
+// Replicas returns all the read replicas, healthy or not
+func (resolver *DBResolver) Replicas() []*gorm.DB {
+	replicas := make([]*gorm.DB, len(resolver.replicas))
+	for i, replica := range resolver.replicas {
+		replicas[i] = replica.db
+	}
+	return replicas
+}
+
+// ReadDB returns the database to be used for reads, the primary if the pin key is pinned or no replica is healthy
+func (resolver *DBResolver) ReadDB(pinKey string) *gorm.DB {
+	if resolver.isPinned(pinKey) {
+		return resolver.primary
+	}
+	count := uint32(len(resolver.replicas))
+	for i := uint32(0); i < count; i++ {
+		replica := resolver.replicas[(atomic.AddUint32(&resolver.next, 1)-1)%count]
+		if atomic.LoadInt32(&replica.healthy) == 1 {
+			return replica.db
+		}
+	}
+	return resolver.primary
+}
+
+// PinToPrimary routes the reads of the pin key, e.g. the correlation id of a request, to the primary for the read-your-writes window
+func (resolver *DBResolver) PinToPrimary(pinKey string) {
+	if pinKey == "" || resolver.options.ReadYourWritesWindow <= 0 || len(resolver.replicas) == 0 {
+		return
+	}
+	resolver.pinMutex.Lock()
+	defer resolver.pinMutex.Unlock()
+	resolver.pins[pinKey] = time.Now().Add(resolver.options.ReadYourWritesWindow)
+}
+
+func (resolver *DBResolver) isPinned(pinKey string) bool {
+	if pinKey == "" || resolver.options.ReadYourWritesWindow <= 0 {
+		return false
+	}
+	resolver.pinMutex.Lock()
+	defer resolver.pinMutex.Unlock()
+	pinnedTill, ok := resolver.pins[pinKey]
+	if ok && time.Now().After(pinnedTill) {
+		delete(resolver.pins, pinKey)
+		return false
+	}
+	return ok
+}
+
+// Start checks the health of the replicas and keeps checking them in background till Stop is called
+func (resolver *DBResolver) Start() {
+	if len(resolver.replicas) == 0 {
+		return
+	}
+	resolver.checkReplicas()
+	go func() {
+		ticker := time.NewTicker(resolver.options.HealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-resolver.stop:
+				return
+			case <-ticker.C:
+				resolver.checkReplicas()
+				resolver.removeExpiredPins()
+			}
+		}
+	}()
+}
+
+// Stop stops the health checks and closes the connection pools of the replicas
+func (resolver *DBResolver) Stop() error {
+	var err error
+	resolver.stopOnce.Do(func() {
+		close(resolver.stop)
+		for _, replica := range resolver.replicas {
+			sqlDB, dbErr := replica.db.DB()
+			if dbErr == nil {
+				dbErr = sqlDB.Close()
+			}
+			if dbErr != nil && err == nil {
+				err = dbErr
+			}
+		}
+	})
+	return err
+}
+
+func (resolver *DBResolver) checkReplicas() {
+	for _, replica := range resolver.replicas {
+		err := pingDB(replica.db)
+		if err != nil && atomic.SwapInt32(&replica.healthy, 0) == 1 {
+			resolver.logger.Warn().Err(err).Str("replica", replica.name).Msg("Read replica is unhealthy, routing its reads to other replicas.")
+		} else if err == nil && atomic.SwapInt32(&replica.healthy, 1) == 0 {
+			resolver.logger.Info().Str("replica", replica.name).Msg("Read replica is healthy.")
+		}
+	}
+}
+
+func (resolver *DBResolver) removeExpiredPins() {
+	resolver.pinMutex.Lock()
+	defer resolver.pinMutex.Unlock()
+	now := time.Now()
+	for pinKey, pinnedTill := range resolver.pins {
+		if now.After(pinnedTill) {
+			delete(resolver.pins, pinKey)
+		}
+	}
+}
+
+func pingDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func TestDBResolverReadDB(t *testing.T) {
+	primary, replica1, replica2 := &gorm.DB{}, &gorm.DB{}, &gorm.DB{}
+	resolver := NewDBResolver(primary, map[string]*gorm.DB{"replica1": replica1, "replica2": replica2}, DBResolverOptions{ReadYourWritesWindow: time.Minute}, zerolog.Nop())
+
+	if db := resolver.ReadDB(""); db != primary {
+		t.Error("Expected the primary when no replica is healthy")
+	}
+
+	for _, replica := range resolver.replicas {
+		replica.healthy = 1
+	}
+	first, second := resolver.ReadDB(""), resolver.ReadDB("")
+	if first == primary || second == primary || first == second {
+		t.Error("Expected the reads to be distributed to the replicas in round-robin")
+	}
+
+	resolver.replicas[0].healthy = 0
+	for i := 0; i < 3; i++ {
+		if db := resolver.ReadDB(""); db != resolver.replicas[1].db {
+			t.Error("Expected the reads to be routed to the healthy replica")
+		}
+	}
+
+	resolver.PinToPrimary("correlation-id")
+	if db := resolver.ReadDB("correlation-id"); db != primary {
+		t.Error("Expected the primary for the pinned correlation id")
+	}
+	if db := resolver.ReadDB("other-correlation-id"); db == primary {
+		t.Error("Expected a replica for the other correlation id")
+	}
+}
@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	microappError "github.com/islax/microapp/error"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Types of the filter attributes
+const (
+	FilterTypeString   = "string"
+	FilterTypeInt      = "int"
+	FilterTypeFloat    = "float"
+	FilterTypeBool     = "bool"
+	FilterTypeDateTime = "datetime"
+	FilterTypeUUID     = "uuid"
+)
+
+const (
+	maxFilterComparisons = 50
+	maxFilterDepth       = 10
+)
+
+// FilterAttribute maps an API attribute which can be used in the filter query to the db column
+type FilterAttribute struct {
+	Column string // db column, the API attribute is used if empty
+	Type   string // one of FilterType*, string if empty
+}
+
+// filterOperators maps the RSQL / FIQL comparison operators to SQL
+var filterOperators = map[string]string{
+	"==":       "=",
+	"!=":       "<>",
+	"=lt=":     "<",
+	"<":        "<",
+	"=le=":     "<=",
+	"<=":       "<=",
+	"=gt=":     ">",
+	">":        ">",
+	"=ge=":     ">=",
+	">=":       ">=",
+	"=in=":     "IN",
+	"=out=":    "NOT IN",
+	"=like=":   "LIKE",
+	"=isnull=": "IS NULL",
+}
+
+// AddFilterQueryFromQueryParams parses the 'filter' query param and creates the db filter, see ParseFilterQuery for the syntax
+func AddFilterQueryFromQueryParams(r *http.Request, attributes map[string]FilterAttribute) ([]QueryProcessor, error) {
+	filterQuery := r.URL.Query().Get("filter")
+	if strings.TrimSpace(filterQuery) == "" {
+		return []QueryProcessor{}, nil
+	}
+	queryProcessor, err := ParseFilterQuery(filterQuery, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return []QueryProcessor{queryProcessor}, nil
+}
+
+// ParseFilterQuery parses the RSQL / FIQL style filter query and creates the db filter.
+// Comparisons are 'attribute operator value', ';' is AND, ',' is OR (AND takes precedence) and comparisons can be grouped using parentheses,
+// e.g. status==active;(createdOn=ge=2024-01-01,name=like=foo*).
+// Supported operators are ==, !=, =lt= (<), =le= (<=), =gt= (>), =ge= (>=), =in=, =out=, =like= ('*' as wildcard, strings only) and =isnull= (true / false).
+// =in= and =out= take a list of values, e.g. status=in=(active,disabled). Values containing reserved characters must be quoted using ' or ".
+// Only the given attributes can be used, their values are validated as per the attribute type and passed as query parameters.
+func ParseFilterQuery(filterQuery string, attributes map[string]FilterAttribute) (QueryProcessor, error) {
+	parser := &filterParser{input: filterQuery, attributes: attributes}
+	condition, args, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	parser.skipSpaces()
+	if parser.pos < len(parser.input) {
+		return nil, invalidFilterFormatError()
+	}
+	return Filter(condition, args...), nil
+}
+
+type filterParser struct {
+	input       string
+	pos         int
+	comparisons int
+	depth       int
+	attributes  map[string]FilterAttribute
+}
+
+func invalidFilterFormatError() error {
+	return microappError.NewValidationError("Key_InvalidFields", map[string]string{"filter": "Key_InvalidFormat"})
+}
+
+// parseOr parses comparisons separated by ','
+func (parser *filterParser) parseOr() (string, []interface{}, error) {
+	return parser.parseList(',', " OR ", parser.parseAnd)
+}
+
+// parseAnd parses comparisons separated by ';'
+func (parser *filterParser) parseAnd() (string, []interface{}, error) {
+	return parser.parseList(';', " AND ", parser.parseConstraint)
+}
+
+func (parser *filterParser) parseList(separator byte, sqlOperator string, parseItem func() (string, []interface{}, error)) (string, []interface{}, error) {
+	conditions := make([]string, 0, 1)
+	args := make([]interface{}, 0)
+	for {
+		condition, itemArgs, err := parseItem()
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, itemArgs...)
+
+		parser.skipSpaces()
+		if parser.pos >= len(parser.input) || parser.input[parser.pos] != separator {
+			break
+		}
+		parser.pos++
+	}
+	if len(conditions) == 1 {
+		return conditions[0], args, nil
+	}
+	return "(" + strings.Join(conditions, sqlOperator) + ")", args, nil
+}
+
+// parseConstraint parses a group in parentheses or a comparison
+func (parser *filterParser) parseConstraint() (string, []interface{}, error) {
+	parser.skipSpaces()
+	if parser.pos < len(parser.input) && parser.input[parser.pos] == '(' {
+		parser.depth++
+		if parser.depth > maxFilterDepth {
+			return "", nil, invalidFilterFormatError()
+		}
+		parser.pos++
+		condition, args, err := parser.parseOr()
+		parser.depth--
+		if err != nil {
+			return "", nil, err
+		}
+		parser.skipSpaces()
+		if parser.pos >= len(parser.input) || parser.input[parser.pos] != ')' {
+			return "", nil, invalidFilterFormatError()
+		}
+		parser.pos++
+		return condition, args, nil
+	}
+	return parser.parseComparison()
+}
+
+func (parser *filterParser) parseComparison() (string, []interface{}, error) {
+	parser.comparisons++
+	if parser.comparisons > maxFilterComparisons {
+		return "", nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"filter": "Key_TooManyComparisons"})
+	}
+
+	selector := parser.readUnquoted()
+	if selector == "" {
+		return "", nil, invalidFilterFormatError()
+	}
+	attribute, ok := parser.attributes[selector]
+	if !ok {
+		return "", nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"filter": "Key_InvalidAttribute"})
+	}
+	column := attribute.Column
+	if column == "" {
+		column = selector
+	}
+	attributeType := attribute.Type
+	if attributeType == "" {
+		attributeType = FilterTypeString
+	}
+
+	operator, err := parser.readOperator()
+	if err != nil {
+		return "", nil, err
+	}
+	sqlOperator := filterOperators[operator]
+	invalidOperatorError := microappError.NewValidationError("Key_InvalidFields", map[string]string{selector: "Key_InvalidOperator"})
+	invalidValueError := microappError.NewValidationError("Key_InvalidFields", map[string]string{selector: "Key_InvalidValue"})
+
+	values, err := parser.readArguments()
+	if err != nil {
+		return "", nil, err
+	}
+	isList := sqlOperator == "IN" || sqlOperator == "NOT IN"
+	if !isList && len(values) != 1 {
+		return "", nil, invalidValueError
+	}
+
+	switch sqlOperator {
+	case "IS NULL":
+		isNull, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return "", nil, invalidValueError
+		}
+		if isNull {
+			return column + " IS NULL", nil, nil
+		}
+		return column + " IS NOT NULL", nil, nil
+	case "LIKE":
+		if attributeType != FilterTypeString {
+			return "", nil, invalidOperatorError
+		}
+		return column + " LIKE ?", []interface{}{likePattern(values[0])}, nil
+	case "<", "<=", ">", ">=":
+		if attributeType == FilterTypeBool || attributeType == FilterTypeUUID {
+			return "", nil, invalidOperatorError
+		}
+	}
+
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		if args[i], err = convertFilterValue(value, attributeType); err != nil {
+			return "", nil, invalidValueError
+		}
+	}
+	if isList {
+		return fmt.Sprintf("%v %v ?", column, sqlOperator), []interface{}{args}, nil
+	}
+	return fmt.Sprintf("%v %v ?", column, sqlOperator), args, nil
+}
+
+// readOperator reads one of the operators, ==, !=, <, <=, >, >= or =name=
+func (parser *filterParser) readOperator() (string, error) {
+	remaining := parser.input[parser.pos:]
+	operator := ""
+	switch {
+	case strings.HasPrefix(remaining, "=="), strings.HasPrefix(remaining, "!="), strings.HasPrefix(remaining, "<="), strings.HasPrefix(remaining, ">="):
+		operator = remaining[:2]
+	case strings.HasPrefix(remaining, "<"), strings.HasPrefix(remaining, ">"):
+		operator = remaining[:1]
+	case strings.HasPrefix(remaining, "="):
+		if end := strings.IndexByte(remaining[1:], '='); end != -1 {
+			operator = remaining[:end+2]
+		}
+	}
+	if _, ok := filterOperators[operator]; !ok {
+		return "", microappError.NewValidationError("Key_InvalidFields", map[string]string{"filter": "Key_InvalidOperator"})
+	}
+	parser.pos += len(operator)
+	return operator, nil
+}
+
+// readArguments reads a value or a list of values in parentheses
+func (parser *filterParser) readArguments() ([]string, error) {
+	if parser.pos < len(parser.input) && parser.input[parser.pos] == '(' {
+		parser.pos++
+		values := make([]string, 0)
+		for {
+			parser.skipSpaces()
+			value, err := parser.readValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			parser.skipSpaces()
+			if parser.pos >= len(parser.input) {
+				return nil, invalidFilterFormatError()
+			}
+			if parser.input[parser.pos] == ')' {
+				parser.pos++
+				return values, nil
+			}
+			if parser.input[parser.pos] != ',' {
+				return nil, invalidFilterFormatError()
+			}
+			parser.pos++
+		}
+	}
+	value, err := parser.readValue()
+	if err != nil {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// readValue reads a quoted or an unquoted value, quoted values can contain the escaped quote
+func (parser *filterParser) readValue() (string, error) {
+	if parser.pos >= len(parser.input) {
+		return "", invalidFilterFormatError()
+	}
+	quote := parser.input[parser.pos]
+	if quote != '\'' && quote != '"' {
+		value := parser.readUnquoted()
+		if value == "" {
+			return "", invalidFilterFormatError()
+		}
+		return value, nil
+	}
+
+	var value strings.Builder
+	for parser.pos++; parser.pos < len(parser.input); parser.pos++ {
+		char := parser.input[parser.pos]
+		if char == '\\' && parser.pos+1 < len(parser.input) {
+			parser.pos++
+			value.WriteByte(parser.input[parser.pos])
+			continue
+		}
+		if char == quote {
+			parser.pos++
+			return value.String(), nil
+		}
+		value.WriteByte(char)
+	}
+	return "", invalidFilterFormatError()
+}
+
+func (parser *filterParser) readUnquoted() string {
+	start := parser.pos
+	for parser.pos < len(parser.input) && !strings.ContainsRune("\"'();,=!<> ", rune(parser.input[parser.pos])) {
+		parser.pos++
+	}
+	return parser.input[start:parser.pos]
+}
+
+func (parser *filterParser) skipSpaces() {
+	for parser.pos < len(parser.input) && parser.input[parser.pos] == ' ' {
+		parser.pos++
+	}
+}
+
+// likePattern escapes the LIKE wildcards in the value and converts '*' to '%'
+func likePattern(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return strings.ReplaceAll(value, "*", "%")
+}
+
+func convertFilterValue(value string, attributeType string) (interface{}, error) {
+	switch attributeType {
+	case FilterTypeInt:
+		return strconv.ParseInt(value, 10, 64)
+	case FilterTypeFloat:
+		return strconv.ParseFloat(value, 64)
+	case FilterTypeBool:
+		return strconv.ParseBool(value)
+	case FilterTypeDateTime:
+		if dateTime, err := time.Parse(time.RFC3339, value); err == nil {
+			return dateTime.UTC(), nil
+		}
+		return time.Parse("2006-01-02", value)
+	case FilterTypeUUID:
+		return uuid.FromString(value)
+	case FilterTypeString:
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported filter type [%v]", attributeType)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	microappError "github.com/islax/microapp/error"
+)
+
+func TestFilterQueryParser(t *testing.T) {
+	attributes := map[string]FilterAttribute{
+		"status":    {},
+		"name":      {Column: "displayName"},
+		"createdOn": {Type: FilterTypeDateTime},
+		"priority":  {Type: FilterTypeInt},
+		"enabled":   {Type: FilterTypeBool},
+		"deletedOn": {Type: FilterTypeDateTime},
+	}
+	createdOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		filterQuery   string
+		wantCondition string
+		wantArgs      []interface{}
+		wantErrors    map[string]string
+	}{
+		{"Equal", "status==active", "status = ?", []interface{}{"active"}, nil},
+		{"AndOr", "status==active;createdOn=ge=2024-01-01,name=like=foo*", "((status = ? AND createdOn >= ?) OR displayName LIKE ?)", []interface{}{"active", createdOn, "foo%"}, nil},
+		{"Group", "status!=deleted;(priority<3,enabled==true)", "(status <> ? AND (priority < ? OR enabled = ?))", []interface{}{"deleted", int64(3), true}, nil},
+		{"In", "status=in=(active, 'on hold')", "status IN ?", []interface{}{[]interface{}{"active", "on hold"}}, nil},
+		{"Out", "priority=out=(1,2)", "priority NOT IN ?", []interface{}{[]interface{}{int64(1), int64(2)}}, nil},
+		{"IsNull", "deletedOn=isnull=true", "deletedOn IS NULL", nil, nil},
+		{"IsNotNull", "deletedOn=isnull=false", "deletedOn IS NOT NULL", nil, nil},
+		{"QuotedValue", `name=="a;b \"c\""`, "displayName = ?", []interface{}{`a;b "c"`}, nil},
+		{"LikeEscapesWildcards", "name=like=100%_*", "displayName LIKE ?", []interface{}{`100\%\_%`}, nil},
+		{"UnknownAttribute", "password==x", "", nil, map[string]string{"filter": "Key_InvalidAttribute"}},
+		{"UnknownOperator", "status=foo=x", "", nil, map[string]string{"filter": "Key_InvalidOperator"}},
+		{"InvalidValue", "priority==high", "", nil, map[string]string{"priority": "Key_InvalidValue"}},
+		{"LikeOnNonString", "priority=like=1*", "", nil, map[string]string{"priority": "Key_InvalidOperator"}},
+		{"RangeOnBool", "enabled=gt=true", "", nil, map[string]string{"enabled": "Key_InvalidOperator"}},
+		{"MultipleValues", "status==(a,b)", "", nil, map[string]string{"status": "Key_InvalidValue"}},
+		{"UnbalancedParentheses", "(status==active", "", nil, map[string]string{"filter": "Key_InvalidFormat"}},
+		{"TrailingInput", "status==active)", "", nil, map[string]string{"filter": "Key_InvalidFormat"}},
+		{"MissingValue", "status==", "", nil, map[string]string{"filter": "Key_InvalidFormat"}},
+		{"MaxNesting", strings.Repeat("(", 10) + "status==active" + strings.Repeat(")", 10), "status = ?", []interface{}{"active"}, nil},
+		{"TooDeeplyNested", strings.Repeat("(", 11) + "status==active" + strings.Repeat(")", 11), "", nil, map[string]string{"filter": "Key_InvalidFormat"}},
+		{"DeeplyNestedWithoutComparison", strings.Repeat("(", 100000), "", nil, map[string]string{"filter": "Key_InvalidFormat"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := &filterParser{input: test.filterQuery, attributes: attributes}
+			condition, args, err := parser.parseOr()
+			if err == nil && parser.pos < len(parser.input) {
+				err = invalidFilterFormatError()
+			}
+
+			if test.wantErrors != nil {
+				var validationError microappError.ValidationError
+				if !errors.As(err, &validationError) {
+					t.Fatalf("Expected validation error, Actual [%v]", err)
+				}
+				if !reflect.DeepEqual(validationError.Errors, test.wantErrors) {
+					t.Errorf("Expected errors %v, Actual %v", test.wantErrors, validationError.Errors)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error [%v]", err)
+			}
+			if condition != test.wantCondition {
+				t.Errorf("Expected condition [%v], Actual [%v]", test.wantCondition, condition)
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) && !(len(args) == 0 && len(test.wantArgs) == 0) {
+				t.Errorf("Expected args %v, Actual %v", test.wantArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+
+	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/event"
+	"github.com/islax/microapp/model"
+	uuid "github.com/satori/go.uuid"
+)
+
+// SetEventContext sets the token and correlation id to be used while publishing the events enqueued in this unit of work
+func (uow *UnitOfWork) SetEventContext(rawToken string, correlationID string) {
+	uow.rawToken = rawToken
+	uow.correlationID = correlationID
+}
+
+// SetEventTenant sets the tenant id to be used while publishing the events enqueued in this unit of work
+func (uow *UnitOfWork) SetEventTenant(tenantID uuid.UUID) {
+	uow.eventTenantID = tenantID.String()
+}
+
+// Enqueue stores the event in the outbox table within the transaction of the unit of work.
+// The event is published by the outbox relay only if the unit of work is committed.
+func (uow *UnitOfWork) Enqueue(topic string, payload interface{}) error {
+	return uow.EnqueueWithOptions(topic, payload, nil)
+}
+
+// EnqueueWithOptions stores the event along with the token, correlation id, tenant id, message id, version and headers of the options in the outbox table,
+// the token, correlation id and tenant id of the unit of work are used unless they are set in the options.
+// The event is published by the outbox relay only if the unit of work is committed.
+func (uow *UnitOfWork) EnqueueWithOptions(topic string, payload interface{}, options *event.DispatchOptions) error {
+	if uow.readOnly {
+		return microappError.NewUnexpectedError(microappError.ErrorCodeInternalError, errors.New("cannot enqueue event in a read only unit of work"))
+	}
+
+	body, isByteMessage := payload.([]byte)
+	if !isByteMessage {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return microappError.NewUnexpectedError(microappError.ErrorCodeInternalError, err)
+		}
+	}
+
+	outboxEvent := &model.OutboxEvent{ID: uuid.NewV4(), Topic: topic, Token: uow.rawToken, CorrelationID: uow.correlationID, TenantID: uow.eventTenantID, Payload: string(body)}
+	if options != nil {
+		if options.Token != "" {
+			outboxEvent.Token = options.Token
+		}
+		if options.CorrelationID != "" {
+			outboxEvent.CorrelationID = options.CorrelationID
+		}
+		if options.TenantID != "" {
+			outboxEvent.TenantID = options.TenantID
+		}
+		outboxEvent.MessageID = options.MessageID
+		outboxEvent.EventVersion = options.Version
+		if len(options.Headers) > 0 {
+			headers, err := json.Marshal(options.Headers)
+			if err != nil {
+				return microappError.NewUnexpectedError(microappError.ErrorCodeInternalError, err)
+			}
+			outboxEvent.Headers = string(headers)
+		}
+	}
+	if err := uow.DB.Create(outboxEvent).Error; err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"net/http"
+
+	microappError "github.com/islax/microapp/error"
+)
+
+// AddFieldsFromQueryParams parses the 'fields' query param and creates the Select of the requested columns, e.g. fields=id,name,status.
+// Only the given attributes can be used, they are mapped to the db column, the attribute is used if the column is empty.
+// The key columns are always selected, e.g. the primary key and the foreign keys needed to preload the expanded associations.
+// All the columns are selected if the param is missing, see web.RespondJSONWithFields to trim the response to the requested fields.
+func AddFieldsFromQueryParams(r *http.Request, fieldAttributes map[string]string, keyColumns ...string) ([]QueryProcessor, error) {
+	attributes := splitQueryParam(r.URL.Query().Get("fields"))
+	if len(attributes) == 0 {
+		return []QueryProcessor{}, nil
+	}
+	columns := append(make([]string, 0, len(keyColumns)+len(attributes)), keyColumns...)
+	for _, attribute := range attributes {
+		column, ok := fieldAttributes[attribute]
+		if !ok {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"fields": "Key_InvalidAttribute"})
+		}
+		if column == "" {
+			column = attribute
+		}
+		if !Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	return []QueryProcessor{Select(columns...)}, nil
+}
+
+// AddExpandFromQueryParams parses the 'expand' query param and creates the preload of the requested associations, e.g. expand=owner,items.product.
+// Only the given attributes can be used, they are mapped to the association, e.g. "items.product" to "Items.Product".
+func AddExpandFromQueryParams(r *http.Request, expandAttributes map[string]string) ([]QueryProcessor, error) {
+	attributes := splitQueryParam(r.URL.Query().Get("expand"))
+	if len(attributes) == 0 {
+		return []QueryProcessor{}, nil
+	}
+	associations := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		association, ok := expandAttributes[attribute]
+		if !ok {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"expand": "Key_InvalidAttribute"})
+		}
+		if association == "" {
+			association = attribute
+		}
+		if !Contains(associations, association) {
+			associations = append(associations, association)
+		}
+	}
+	return []QueryProcessor{PreloadAssociations(associations)}, nil
+}
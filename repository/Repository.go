@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -45,34 +46,104 @@ type Repository interface {
 	ReplaceAssociations(uow *UnitOfWork, out interface{}, associationName string, associations ...interface{}) microappError.DatabaseError
 }
 
+// TrashRepository is implemented by the repositories supporting the listing and restore of the soft deleted records, GormRepository implements it
+type TrashRepository interface {
+	GetDeleted(uow *UnitOfWork, out interface{}, queryProcessors []QueryProcessor) microappError.DatabaseError
+	Restore(uow *UnitOfWork, out interface{}, where ...interface{}) (int64, microappError.DatabaseError)
+}
+
 // UnitOfWork represents a connection
 type UnitOfWork struct {
-	DB        *gorm.DB
-	committed bool
-	readOnly  bool
+	DB            *gorm.DB
+	committed     bool
+	readOnly      bool
+	rawToken      string
+	correlationID string
+	eventTenantID string
+	tenantID      uuid.UUID
+	savePoint     string // savepoint of the nested unit of work, empty for the outermost unit of work
+	savePoints    *int   // number of savepoints created in the transaction, shared by the nested units of work
+	hooks         *transactionHooks
 }
 
 // NewUnitOfWork creates new UnitOfWork
 func NewUnitOfWork(db *gorm.DB, readOnly bool, logger zerolog.Logger, logConfig log.Config) *UnitOfWork {
+	return NewUnitOfWorkWithContext(context.Background(), db, readOnly, logger, logConfig)
+}
+
+// NewUnitOfWorkWithContext creates new UnitOfWork whose statements are run with the context, e.g. of the http request,
+// so that they are cancelled along with it. The transaction is rolled back if the context is cancelled before it is committed.
+func NewUnitOfWorkWithContext(ctx context.Context, db *gorm.DB, readOnly bool, logger zerolog.Logger, logConfig log.Config) *UnitOfWork {
+	session := db.Session(&gorm.Session{NewDB: true, FullSaveAssociations: true, Context: ctx, Logger: log.NewGormLogger(logger, logConfig)})
 	if readOnly {
-		return &UnitOfWork{DB: db.Session(&gorm.Session{NewDB: true, FullSaveAssociations: true, Logger: log.NewGormLogger(logger, logConfig)}), committed: false, readOnly: true}
+		return &UnitOfWork{DB: session, committed: false, readOnly: true, hooks: newTransactionHooks(nil, &logger)}
+	}
+	return &UnitOfWork{DB: session.Begin(), committed: false, readOnly: false, hooks: newTransactionHooks(nil, &logger)}
+}
+
+// Nested creates a unit of work nested in the transaction of this unit of work, backed by a savepoint.
+// Complete of the nested unit of work without Commit rolls back only the changes made after it was created,
+// Commit releases the savepoint and the changes are committed or rolled back along with this unit of work.
+// The commit hooks of the nested unit of work run once this unit of work is committed.
+func (uow *UnitOfWork) Nested() (*UnitOfWork, error) {
+	if uow.savePoints == nil {
+		uow.savePoints = new(int)
+	}
+	nestedUOW := *uow
+	nestedUOW.committed = false
+	nestedUOW.hooks = newTransactionHooks(uow.hooks, nil)
+	if uow.readOnly {
+		return &nestedUOW, nil
+	}
+
+	*uow.savePoints++
+	nestedUOW.savePoint = fmt.Sprintf("sp_%d", *uow.savePoints)
+	if err := uow.DB.Exec("SAVEPOINT " + nestedUOW.savePoint).Error; err != nil {
+		return nil, microappError.NewDatabaseError(err)
 	}
-	return &UnitOfWork{DB: db.Session(&gorm.Session{NewDB: true, FullSaveAssociations: true, Logger: log.NewGormLogger(logger, logConfig)}).Begin(), committed: false, readOnly: false}
+	return &nestedUOW, nil
 }
 
-// Complete marks end of unit of work
+// Complete marks end of unit of work, the transaction is rolled back and the rollback hooks are run if it was not committed
 func (uow *UnitOfWork) Complete() {
-	if !uow.committed && !uow.readOnly {
-		uow.DB.Rollback()
+	if uow.committed {
+		return
 	}
+	if !uow.readOnly {
+		if uow.savePoint != "" {
+			uow.DB.Exec("ROLLBACK TO SAVEPOINT " + uow.savePoint)
+		} else {
+			uow.DB.Rollback()
+		}
+	}
+	uow.committed = true
+	uow.hooks.rolledBack()
 }
 
-// Commit the transaction
-func (uow *UnitOfWork) Commit() {
+// Commit the transaction, for the nested unit of work the savepoint is released.
+// The commit hooks are run once the transaction is committed, if the commit fails the rollback hooks are run and the error is returned.
+func (uow *UnitOfWork) Commit() error {
+	if uow.committed {
+		return nil
+	}
+	var err error
 	if !uow.readOnly {
-		uow.DB.Commit()
+		if uow.savePoint != "" {
+			err = uow.DB.Exec("RELEASE SAVEPOINT " + uow.savePoint).Error
+		} else {
+			err = uow.DB.Commit().Error
+		}
 	}
 	uow.committed = true
+	if err != nil {
+		if uow.savePoint != "" {
+			uow.DB.Exec("ROLLBACK TO SAVEPOINT " + uow.savePoint)
+		}
+		uow.hooks.rolledBack()
+		return microappError.NewDatabaseError(err)
+	}
+	uow.hooks.committed()
+	return nil
 }
 
 // GormRepository implements Repository
@@ -331,6 +402,11 @@ func (repository *GormRepository) GetAllUnscopedForTenant(uow *UnitOfWork, out i
 	return repository.GetAllUnscoped(uow, out, queryProcessors)
 }
 
+// GetDeleted retrieves the soft deleted records (trash) for a specified entity
+func (repository *GormRepository) GetDeleted(uow *UnitOfWork, out interface{}, queryProcessors []QueryProcessor) microappError.DatabaseError {
+	return repository.GetAllUnscoped(uow, out, append(queryProcessors, Filter("deletedOn IS NOT NULL")))
+}
+
 // GetCount gets count of the given entity type
 func (repository *GormRepository) GetCount(uow *UnitOfWork, count *int64, entity interface{}, queryProcessors []QueryProcessor) microappError.DatabaseError {
 	db := uow.DB
@@ -386,12 +462,9 @@ func (repository *GormRepository) AddWithOmit(uow *UnitOfWork, entity interface{
 	return nil
 }
 
-// Update specified Entity
+// Update specified Entity, the entity embedding model.Versioned is updated only if it has the version of the row
 func (repository *GormRepository) Update(uow *UnitOfWork, entity interface{}) microappError.DatabaseError {
-	if err := uow.DB.Model(entity).Updates(entity).Error; err != nil {
-		return microappError.NewDatabaseError(err)
-	}
-	return nil
+	return updateEntity(uow.DB, entity, nil)
 }
 
 // Update or insert if not found
@@ -420,18 +493,15 @@ func (repository *GormRepository) Upsert(uow *UnitOfWork, entity interface{}, qu
 	return nil
 }
 
-// UpdateWithOmit updates specified Entity by omitting passed fields
+// UpdateWithOmit updates specified Entity by omitting passed fields, the entity embedding model.Versioned is updated only if it has the version of the row
 func (repository *GormRepository) UpdateWithOmit(uow *UnitOfWork, entity interface{}, omitFields []string) microappError.DatabaseError {
-	if err := uow.DB.Model(entity).Omit(omitFields...).Updates(entity).Error; err != nil {
-		return microappError.NewDatabaseError(err)
-	}
-	return nil
+	return updateEntity(uow.DB, entity, omitFields)
 }
 
-// CheckVersionAndUpdate specified Entity after checking for version change
+// CheckVersionAndUpdate specified Entity after checking for version change, returns ConflictError if the row was modified.
+// The version column is used for the entity embedding model.Versioned, modifiedOn otherwise.
 func (repository *GormRepository) CheckVersionAndUpdate(uow *UnitOfWork, entity interface{}, queryProcessors []QueryProcessor) microappError.DatabaseError {
 	db := uow.DB
-	queryProcessors = append(queryProcessors, Filter("modifiedOn = ?", reflect.ValueOf(entity).Elem().FieldByName("Base").Interface().(model.Base).UpdatedAt))
 	var err error
 	for _, queryProcessor := range queryProcessors {
 		db, err = queryProcessor(db, entity)
@@ -439,18 +509,58 @@ func (repository *GormRepository) CheckVersionAndUpdate(uow *UnitOfWork, entity
 			return microappError.NewDatabaseError(err)
 		}
 	}
+	if _, ok := entity.(model.VersionedEntity); ok {
+		return updateEntity(db, entity, nil)
+	}
 
-	updateResponse := db.Model(entity).Updates(entity)
+	updatedAt := reflect.Indirect(reflect.ValueOf(entity)).FieldByName("UpdatedAt")
+	if !updatedAt.IsValid() {
+		return microappError.NewDatabaseError(errors.New("entity does not have version or modifiedOn"))
+	}
+	updateResponse := db.Model(entity).Where("modifiedOn = ?", updatedAt.Interface()).Updates(entity)
 	if updateResponse.Error != nil {
 		return microappError.NewDatabaseError(updateResponse.Error)
 	}
 	if updateResponse.RowsAffected == 0 {
-		return microappError.NewDatabaseError(errors.New("row data modified"))
+		return newConflictError(entity)
 	}
 	return nil
 }
 
-// Delete specified Entity
+// updateEntity updates the entity, the versioned entity is updated only if the row has the same version and its version is incremented
+func updateEntity(db *gorm.DB, entity interface{}, omitFields []string) microappError.DatabaseError {
+	versionedEntity, ok := entity.(model.VersionedEntity)
+	if !ok {
+		if err := db.Model(entity).Omit(omitFields...).Updates(entity).Error; err != nil {
+			return microappError.NewDatabaseError(err)
+		}
+		return nil
+	}
+
+	version := versionedEntity.GetVersion()
+	versionedEntity.SetVersion(version + 1)
+	updateResponse := db.Model(entity).Where("version = ?", version).Omit(omitFields...).Updates(entity)
+	if updateResponse.Error != nil {
+		versionedEntity.SetVersion(version)
+		return microappError.NewDatabaseError(updateResponse.Error)
+	}
+	if updateResponse.RowsAffected == 0 {
+		versionedEntity.SetVersion(version)
+		return newConflictError(entity)
+	}
+	return nil
+}
+
+func newConflictError(entity interface{}) microappError.ConflictError {
+	entityValue := reflect.Indirect(reflect.ValueOf(entity))
+	resourceValue := ""
+	if id := entityValue.FieldByName("ID"); id.IsValid() {
+		resourceValue = fmt.Sprint(id.Interface())
+	}
+	return microappError.NewConflictError(entityValue.Type().Name(), resourceValue)
+}
+
+// Delete specified Entity, soft deletes models embedding model.Base or model.TenantBase
 func (repository *GormRepository) Delete(uow *UnitOfWork, entity interface{}, where ...interface{}) microappError.DatabaseError {
 	if err := uow.DB.Delete(entity, where...).Error; err != nil {
 		return microappError.NewDatabaseError(err)
@@ -474,6 +584,19 @@ func (repository *GormRepository) DeletePermanent(uow *UnitOfWork, entity interf
 	return nil
 }
 
+// Restore restores the soft deleted record(s) of specified entity / entity type matching the conditions and returns the number of restored records
+func (repository *GormRepository) Restore(uow *UnitOfWork, entity interface{}, where ...interface{}) (int64, microappError.DatabaseError) {
+	db := uow.DB.Unscoped().Model(entity)
+	if len(where) > 0 {
+		db = db.Where(where[0], where[1:]...)
+	}
+	result := db.Where("deletedOn IS NOT NULL").Update("deletedOn", nil)
+	if result.Error != nil {
+		return 0, microappError.NewDatabaseError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
 // AddAssociations adds associations to the given out entity
 func (repository *GormRepository) AddAssociations(uow *UnitOfWork, out interface{}, associationName string, associations ...interface{}) microappError.DatabaseError {
 	if err := uow.DB.Model(out).Association(associationName).Append(associations...); err != nil {
@@ -559,12 +682,43 @@ func AddFiltersFromQueryParamsWithOR(r *http.Request, filterDetails ...string) (
 	return filters, nil
 }
 
+// SortColumn represents a db column of the order by clause
+type SortColumn struct {
+	Column    string
+	Direction string // ASC, DESC or empty for the default (ascending) order
+}
+
+// IsDescending returns whether the column is sorted in descending order
+func (sortColumn SortColumn) IsDescending() bool {
+	return sortColumn.Direction == "DESC"
+}
+
 // GetOrderBy creates order by query processor
 // orderByAttrs - ["column1:0", "column2:1"], validOrderByAttrs - ["column1", "column2", "column3"],
 // orderByAttrAndDBCloum - {"cloumn3": ["dbColunm4", "dbColumn5"]}
 func GetOrderBy(orderByAttrs []string, validOrderByAttrs []string, orderByAttrAndDBCloum map[string][]string, reorder bool) (QueryProcessor, error) {
+	sortColumns, err := ParseOrderBy(orderByAttrs, validOrderByAttrs, orderByAttrAndDBCloum)
+	if err != nil {
+		return nil, err
+	}
 
-	retOrderByStr := ""
+	orderBy := make([]string, 0, len(sortColumns))
+	for _, sortColumn := range sortColumns {
+		if sortColumn.Direction != "" {
+			orderBy = append(orderBy, fmt.Sprintf("%v %v", sortColumn.Column, sortColumn.Direction))
+		} else {
+			orderBy = append(orderBy, sortColumn.Column)
+		}
+	}
+	if len(orderBy) > 0 {
+		return Order(strings.Join(orderBy, ","), reorder), nil
+	}
+	return nil, nil
+}
+
+// ParseOrderBy validates the order by attributes and maps them to the db columns, the parameters are same as GetOrderBy
+func ParseOrderBy(orderByAttrs []string, validOrderByAttrs []string, orderByAttrAndDBCloum map[string][]string) ([]SortColumn, error) {
+	sortColumns := make([]SortColumn, 0)
 	validOrderByAttrsAsMap := make(map[string]bool)
 	validOrderByDirection := map[string]string{"ASC": "ASC", "0": "ASC", "A": "ASC", "DESC": "DESC", "1": "DESC", "D": "DESC"}
 
@@ -572,44 +726,34 @@ func GetOrderBy(orderByAttrs []string, validOrderByAttrs []string, orderByAttrAn
 		validOrderByAttrsAsMap[validOrderByAttr] = true
 	}
 
-	for i, orderByAttr := range orderByAttrs {
-		if i > 0 {
-			retOrderByStr += ","
+	for _, orderByAttr := range orderByAttrs {
+		if strings.TrimSpace(orderByAttr) == "" {
+			continue
 		}
-		if strings.TrimSpace(orderByAttr) != "" {
-			attrAndDirection := strings.Split(orderByAttr, ",")
-			if len(attrAndDirection) > 2 {
-				return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"orderby": "Key_InvalidFormat"})
+		attrAndDirection := strings.Split(orderByAttr, ",")
+		if len(attrAndDirection) > 2 {
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"orderby": "Key_InvalidFormat"})
+		}
+		if !validOrderByAttrsAsMap[attrAndDirection[0]] { //Chk if its a valid orderby column
+			return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"orderby": "Key_InvalidAttribute"})
+		}
+		orderByDirection := ""
+		if len(attrAndDirection) == 2 { // 2 - order by contains direction too
+			direction, ok := validOrderByDirection[strings.ToUpper(attrAndDirection[1])]
+			if !ok {
+				return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"orderby": "Key_InvalidDirection"})
 			}
-			if validOrderByAttrsAsMap[attrAndDirection[0]] { //Chk if its a valid orderby column
-				orderByDirection := ""
-				if len(attrAndDirection) == 2 { // 2 - order by contains direction too
-					if direction, ok := validOrderByDirection[strings.ToUpper(attrAndDirection[1])]; ok {
-						orderByDirection = fmt.Sprintf(" %v", direction)
-					} else {
-						return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"orderby": "Key_InvalidDirection"})
-					}
-				}
-				if dbColumns, ok := orderByAttrAndDBCloum[attrAndDirection[0]]; ok { //Chk if it has any db column mapping
-					for j, dbColumn := range dbColumns {
-						if j > 0 {
-							retOrderByStr += ","
-						}
-						retOrderByStr = fmt.Sprintf("%v%v%v", retOrderByStr, dbColumn, orderByDirection)
-					}
-				} else {
-					retOrderByStr = fmt.Sprintf("%v%v%v", retOrderByStr, attrAndDirection[0], orderByDirection)
-				}
-
-			} else {
-				return nil, microappError.NewValidationError("Key_InvalidFields", map[string]string{"orderby": "Key_InvalidAttribute"})
+			orderByDirection = direction
+		}
+		if dbColumns, ok := orderByAttrAndDBCloum[attrAndDirection[0]]; ok { //Chk if it has any db column mapping
+			for _, dbColumn := range dbColumns {
+				sortColumns = append(sortColumns, SortColumn{Column: dbColumn, Direction: orderByDirection})
 			}
+		} else {
+			sortColumns = append(sortColumns, SortColumn{Column: attrAndDirection[0], Direction: orderByDirection})
 		}
 	}
-	if retOrderByStr != "" {
-		return Order(retOrderByStr, reorder), nil
-	}
-	return nil, nil
+	return sortColumns, nil
 }
 
 // Contains checks if value present in array
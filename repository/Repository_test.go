@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/log"
+	"github.com/islax/microapp/model"
+	"github.com/islax/microapp/web"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens an in-memory sqlite database with the given plugins and creates the tables of the models
+func newTestDB(t *testing.T, plugins []gorm.Plugin, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Each connection opens a new in-memory database
+	t.Cleanup(func() { sqlDB.Close() })
+	for _, plugin := range plugins {
+		if err = db.Use(plugin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestUOW(db *gorm.DB, readOnly bool) *UnitOfWork {
+	return NewUnitOfWork(db, readOnly, zerolog.Nop(), log.Config{})
+}
+
+func TestNestedUnitOfWork(t *testing.T) {
+	db := newTestDB(t, nil, &batchItem{})
+	repository := NewRepository()
+	add := func(uow *UnitOfWork, code string) {
+		if err := repository.Add(uow, &batchItem{Base: model.Base{ID: uuid.NewV4()}, Code: code}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nested := func(uow *UnitOfWork, savePoint string) *UnitOfWork {
+		nestedUOW, err := uow.Nested()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nestedUOW.savePoint != savePoint {
+			t.Errorf("Expected savepoint [%v], Actual [%v]", savePoint, nestedUOW.savePoint)
+		}
+		return nestedUOW
+	}
+
+	uow := newTestUOW(db, false)
+	add(uow, "a")
+	rolledBack := nested(uow, "sp_1")
+	add(rolledBack, "b")
+	rolledBackInner := nested(rolledBack, "sp_2")
+	add(rolledBackInner, "c")
+	if err := rolledBackInner.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	rolledBack.Complete() // Rolls back to sp_1, including the changes of the committed inner unit of work
+	add(uow, "d")
+	released := nested(uow, "sp_3")
+	add(released, "e")
+	if err := released.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := uow.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var codes []string
+	db.Model(&batchItem{}).Order("code").Pluck("code", &codes)
+	if strings.Join(codes, ",") != "a,d,e" {
+		t.Errorf("Expected rows [a,d,e], Actual [%v]", codes)
+	}
+}
+
+func TestNestedUnitOfWorkRolledBackWithParent(t *testing.T) {
+	db := newTestDB(t, nil, &batchItem{})
+	uow := newTestUOW(db, false)
+	nestedUOW, err := uow.Nested()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewRepository().Add(nestedUOW, &batchItem{Base: model.Base{ID: uuid.NewV4()}, Code: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = nestedUOW.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	uow.Complete()
+
+	var count int64
+	db.Model(&batchItem{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected changes of the committed nested unit of work to be rolled back with the parent, Actual [%v] rows", count)
+	}
+}
+
+type versionedItem struct {
+	model.Base
+	model.Versioned
+	Name string
+}
+
+func TestUpdateVersionedEntity(t *testing.T) {
+	db := newTestDB(t, nil, &versionedItem{})
+	repository := NewRepository()
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+
+	item := versionedItem{Base: model.Base{ID: uuid.NewV4()}, Name: "a"}
+	if err := repository.Add(uow, &item); err != nil {
+		t.Fatal(err)
+	}
+	stale := item
+
+	item.Name = "b"
+	if err := repository.Update(uow, &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Version != 1 {
+		t.Errorf("Expected version to be incremented to [1], Actual [%v]", item.Version)
+	}
+
+	stale.Name = "c"
+	err := repository.CheckVersionAndUpdate(uow, &stale, nil)
+	if _, ok := err.(microappError.ConflictError); !ok {
+		t.Fatalf("Expected ConflictError for the stale version, Actual [%v]", err)
+	}
+	if stale.Version != 0 {
+		t.Errorf("Expected version of the stale entity to be restored to [0], Actual [%v]", stale.Version)
+	}
+	recorder := httptest.NewRecorder()
+	web.RespondError(recorder, err)
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Expected status [%v], Actual [%v]", http.StatusConflict, recorder.Code)
+	}
+
+	var stored versionedItem
+	uow.DB.First(&stored, "id = ?", item.ID)
+	if stored.Name != "b" || stored.Version != 1 {
+		t.Errorf("Expected row [b, 1] to be unchanged by the conflicting update, Actual [%v, %v]", stored.Name, stored.Version)
+	}
+}
+
+func TestUpdateVersionedEntityWithIfMatch(t *testing.T) {
+	db := newTestDB(t, nil, &versionedItem{})
+	repository := NewRepository()
+	uow := newTestUOW(db, false)
+	defer uow.Complete()
+
+	item := versionedItem{Base: model.Base{ID: uuid.NewV4()}, Versioned: model.Versioned{Version: 2}, Name: "a"}
+	if err := repository.Add(uow, &item); err != nil {
+		t.Fatal(err)
+	}
+
+	ifMatchCombinations := []struct {
+		ifMatch  string
+		conflict bool
+	}{
+		{`"1"`, true},
+		{web.ETag(2), false},
+		{web.ETag(2), true}, // The version was incremented by the previous update
+		{"*", false},
+	}
+	for _, combination := range ifMatchCombinations {
+		request := httptest.NewRequest("PUT", "/", nil)
+		request.Header.Set("If-Match", combination.ifMatch)
+		var entity versionedItem
+		uow.DB.First(&entity, "id = ?", item.ID)
+		version, ok, err := web.GetIfMatchVersion(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			entity.SetVersion(version)
+		}
+		entity.Name = "updated " + combination.ifMatch
+
+		err = repository.Update(uow, &entity)
+		if _, isConflict := err.(microappError.ConflictError); isConflict != combination.conflict {
+			t.Errorf("If-Match [%v]: Expected conflict [%v], Actual [%v]", combination.ifMatch, combination.conflict, err)
+		}
+	}
+
+	var stored versionedItem
+	uow.DB.First(&stored, "id = ?", item.ID)
+	if stored.Version != 4 {
+		t.Errorf("Expected version [4] after two updates, Actual [%v]", stored.Version)
+	}
+}
+
+func TestRestoreAndGetDeleted(t *testing.T) {
+	repository := NewRepository()
+	trash := repository.(TrashRepository)
+
+	t.Run("Base", func(t *testing.T) {
+		uow := newTestUOW(newTestDB(t, nil, &batchItem{}), false)
+		defer uow.Complete()
+		items := []batchItem{{Base: model.Base{ID: uuid.NewV4()}, Code: "a"}, {Base: model.Base{ID: uuid.NewV4()}, Code: "b"}}
+		if err := uow.DB.Create(&items).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := repository.Delete(uow, &batchItem{}, "id = ?", items[0].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		var deleted []batchItem
+		if err := trash.GetDeleted(uow, &deleted, nil); err != nil || len(deleted) != 1 || deleted[0].ID != items[0].ID {
+			t.Fatalf("Expected only the soft deleted row [%v], Actual %v [%v]", items[0].ID, deleted, err)
+		}
+		if restored, err := trash.Restore(uow, &batchItem{}, "id = ?", items[0].ID); err != nil || restored != 1 {
+			t.Fatalf("Expected 1 row to be restored, Actual [%v] [%v]", restored, err)
+		}
+		var restoredItem batchItem
+		if err := repository.Get(uow, &restoredItem, items[0].ID, nil); err != nil {
+			t.Errorf("Expected the restored row to be found, Actual [%v]", err)
+		}
+		deleted = nil
+		if err := trash.GetDeleted(uow, &deleted, nil); err != nil || len(deleted) != 0 {
+			t.Errorf("Expected no soft deleted rows, Actual %v [%v]", deleted, err)
+		}
+	})
+
+	t.Run("TenantBase", func(t *testing.T) {
+		uow := newTestUOW(newTestDB(t, nil, &tenantItem{}), false)
+		defer uow.Complete()
+		tenantID := uuid.NewV4()
+		items := []tenantItem{
+			{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: tenantID}, Name: "a"},
+			{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: tenantID}, Name: "b"},
+		}
+		if err := uow.DB.Create(&items).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := repository.DeleteForTenant(uow, &tenantItem{}, tenantID); err != nil {
+			t.Fatal(err)
+		}
+
+		var deleted []tenantItem
+		if err := trash.GetDeleted(uow, &deleted, []QueryProcessor{Filter("tenantId = ?", tenantID)}); err != nil || len(deleted) != 2 {
+			t.Fatalf("Expected 2 soft deleted rows, Actual %v [%v]", deleted, err)
+		}
+		if restored, err := trash.Restore(uow, &tenantItem{}, "id = ?", items[1].ID); err != nil || restored != 1 {
+			t.Fatalf("Expected 1 row to be restored, Actual [%v] [%v]", restored, err)
+		}
+		var active []tenantItem
+		if err := repository.GetAllForTenant(uow, &active, tenantID, nil); err != nil || len(active) != 1 || active[0].Name != "b" {
+			t.Errorf("Expected only the restored row [b], Actual %v [%v]", active, err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	statementTimeoutPluginName = "microapp:statement_timeout"
+	statementCancelKey         = "microapp:statement_cancel"
+)
+
+type statementTimeoutKey struct{}
+
+type statementCancel struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// StatementTimeoutPlugin is a gorm plugin which cancels the queries, creates, updates, deletes and Exec statements running longer than
+// the statement timeout of the unit of work, the statement then fails with context.DeadlineExceeded. Row, Rows and Scan are not limited,
+// so that the rows can be read after the statement, they are cancelled along with the context of the unit of work.
+type StatementTimeoutPlugin struct{}
+
+// NewStatementTimeoutPlugin creates a new statement timeout plugin, register it using gorm.DB.Use
+func NewStatementTimeoutPlugin() *StatementTimeoutPlugin {
+	return &StatementTimeoutPlugin{}
+}
+
+// Name implements gorm.Plugin
+func (plugin *StatementTimeoutPlugin) Name() string {
+	return statementTimeoutPluginName
+}
+
+// Initialize implements gorm.Plugin
+func (plugin *StatementTimeoutPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register(statementTimeoutPluginName+":before_create", plugin.startTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:create").Register(statementTimeoutPluginName+":create", plugin.stopTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register(statementTimeoutPluginName+":before_query", plugin.startTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().After("gorm:query").Register(statementTimeoutPluginName+":query", plugin.stopTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register(statementTimeoutPluginName+":before_update", plugin.startTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register(statementTimeoutPluginName+":update", plugin.stopTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register(statementTimeoutPluginName+":before_delete", plugin.startTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register(statementTimeoutPluginName+":delete", plugin.stopTimeout); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register(statementTimeoutPluginName+":before_raw", plugin.startTimeout); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register(statementTimeoutPluginName+":raw", plugin.stopTimeout)
+}
+
+// WithStatementTimeout returns a context in which the statements are cancelled after the timeout, zero disables it
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+func (plugin *StatementTimeoutPlugin) startTimeout(db *gorm.DB) {
+	ctx := statementContext(db)
+	timeout, ok := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	if !ok || timeout <= 0 || db.Error != nil {
+		return
+	}
+	statementCtx, cancel := context.WithTimeout(ctx, timeout)
+	db.Statement.Settings.Store(statementCancelKey, statementCancel{parent: db.Statement.Context, cancel: cancel})
+	db.Statement.Context = statementCtx
+}
+
+// stopTimeout releases the timer and restores the context, the statement can be reused by the chained methods, e.g. Count and Find
+func (plugin *StatementTimeoutPlugin) stopTimeout(db *gorm.DB) {
+	value, ok := db.Statement.Settings.Load(statementCancelKey)
+	if !ok {
+		return
+	}
+	db.Statement.Settings.Delete(statementCancelKey)
+	timeout := value.(statementCancel)
+	timeout.cancel()
+	db.Statement.Context = timeout.parent
+}
+
+// SetStatementTimeout cancels the statements of the unit of work running longer than the timeout, zero disables it.
+// The cancellation requires StatementTimeoutPlugin to be registered with the database.
+func (uow *UnitOfWork) SetStatementTimeout(timeout time.Duration) {
+	uow.DB = uow.DB.WithContext(WithStatementTimeout(statementContext(uow.DB), timeout))
+}
+
+// Context returns the context of the unit of work
+func (uow *UnitOfWork) Context() context.Context {
+	return statementContext(uow.DB)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/log"
+	"github.com/islax/microapp/web"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// slowStatement counts up to hundred million in sqlite, it runs for seconds unless cancelled
+const slowStatement = "WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter WHERE x < 100000000) SELECT count(*) FROM counter"
+
+func TestStatementTimeoutCancelsSlowStatement(t *testing.T) {
+	db := newTestDB(t, []gorm.Plugin{NewStatementTimeoutPlugin()})
+	uow := newTestUOW(db, false)
+	uow.SetStatementTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	err := uow.DB.Exec(slowStatement).Error
+	if err == nil {
+		t.Fatal("Expected slow statement to be cancelled, Actual [no error]")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected slow statement to be cancelled after the timeout, Actual [%v]", elapsed)
+	}
+
+	dbErr := microappError.NewDatabaseError(err)
+	if _, ok := dbErr.(microappError.TimeoutError); !ok {
+		t.Fatalf("Expected TimeoutError, Actual [%T: %v]", dbErr, dbErr)
+	}
+	recorder := httptest.NewRecorder()
+	web.RespondError(recorder, dbErr)
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status [%v], Actual [%v]", http.StatusGatewayTimeout, recorder.Code)
+	}
+
+	// The timeout of a statement does not affect the next statements of the unit of work
+	var count int
+	if err = uow.DB.Raw("SELECT 1").Scan(&count).Error; err != nil || count != 1 {
+		t.Errorf("Expected next statement to succeed, Actual [%v, %v]", count, err)
+	}
+}
+
+func TestStatementCancelledWithContext(t *testing.T) {
+	db := newTestDB(t, []gorm.Plugin{NewStatementTimeoutPlugin()})
+	ctx, cancel := context.WithCancel(context.Background())
+	uow := NewUnitOfWorkWithContext(ctx, db, false, zerolog.Nop(), log.Config{})
+	uow.SetStatementTimeout(10 * time.Second)
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err := uow.DB.Exec(slowStatement).Error
+	if err == nil {
+		t.Fatal("Expected slow statement to be cancelled, Actual [no error]")
+	}
+
+	dbErr := microappError.NewDatabaseError(err)
+	if _, ok := dbErr.(microappError.CanceledError); !ok {
+		t.Fatalf("Expected CanceledError, Actual [%T: %v]", dbErr, dbErr)
+	}
+	recorder := httptest.NewRecorder()
+	web.RespondError(recorder, dbErr)
+	if recorder.Code != web.StatusClientClosedRequest {
+		t.Errorf("Expected status [%v], Actual [%v]", web.StatusClientClosedRequest, recorder.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"reflect"
+
+	microappError "github.com/islax/microapp/error"
+)
+
+// StreamRepository is implemented by the repositories which can stream the records, GormRepository implements it
+type StreamRepository interface {
+	Stream(uow *UnitOfWork, model interface{}, queryProcessors []QueryProcessor, handle func(entity interface{}) error) microappError.DatabaseError
+}
+
+// Stream retrieves the entities of the model as per the query processors, e.g. filters and order, one at a time and calls handle for each of them,
+// so that large result sets can be exported without loading them in memory. The entity is a pointer to a new model and associations are not preloaded.
+// Streaming stops at the first error returned by handle, which is then returned.
+func (repository *GormRepository) Stream(uow *UnitOfWork, model interface{}, queryProcessors []QueryProcessor, handle func(entity interface{}) error) microappError.DatabaseError {
+	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
+	db := uow.DB.Model(model)
+	for _, queryProcessor := range queryProcessors {
+		var err microappError.DatabaseError
+		if db, err = queryProcessor(db, model); err != nil {
+			return err
+		}
+	}
+
+	rows, err := db.Rows()
+	if err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entity := reflect.New(modelType).Interface()
+		if err := db.ScanRows(rows, entity); err != nil {
+			return microappError.NewDatabaseError(err)
+		}
+		if err := handle(entity); err != nil {
+			if databaseError, ok := err.(microappError.DatabaseError); ok {
+				return databaseError
+			}
+			return microappError.NewDatabaseError(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return microappError.NewDatabaseError(err)
+	}
+	return nil
+}
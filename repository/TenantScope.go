@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/model"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const tenantScopePluginName = "microapp:tenant_scope"
+
+type tenantScopeKey struct{}
+
+type tenantScope struct {
+	tenantID    uuid.UUID
+	crossTenant bool
+}
+
+// ErrTenantMismatch is returned when an entity of another tenant is created in a tenant scoped unit of work
+var ErrTenantMismatch = errors.New("entity does not belong to the tenant of the unit of work")
+
+var tenantBaseType = reflect.TypeOf(model.TenantBase{})
+
+// TenantScopePlugin is a gorm plugin which scopes the queries, updates and deletes of the models embedding model.TenantBase
+// to the tenant of the unit of work and stamps the tenant on the created entities. Raw and Exec queries are not scoped.
+type TenantScopePlugin struct {
+	tenantModels sync.Map // model type -> isTenantModel
+}
+
+// NewTenantScopePlugin creates a new tenant scope plugin, register it using gorm.DB.Use
+func NewTenantScopePlugin() *TenantScopePlugin {
+	return &TenantScopePlugin{}
+}
+
+// Name implements gorm.Plugin
+func (plugin *TenantScopePlugin) Name() string {
+	return tenantScopePluginName
+}
+
+// Initialize implements gorm.Plugin
+func (plugin *TenantScopePlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register(tenantScopePluginName+":create", plugin.stampTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register(tenantScopePluginName+":query", plugin.addTenantCondition); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register(tenantScopePluginName+":row", plugin.addTenantCondition); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register(tenantScopePluginName+":update", plugin.addTenantCondition); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register(tenantScopePluginName+":delete", plugin.addTenantCondition)
+}
+
+// WithTenant returns a context in which the queries of the tenant models are scoped to the given tenant, uuid.Nil disables the scoping
+func WithTenant(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantScopeKey{}, &tenantScope{tenantID: tenantID})
+}
+
+// WithCrossTenant returns a context in which the queries of the tenant models are not scoped to the tenant, to be used by admin and system operations
+func WithCrossTenant(ctx context.Context) context.Context {
+	scope := &tenantScope{crossTenant: true}
+	if currentScope, ok := ctx.Value(tenantScopeKey{}).(*tenantScope); ok {
+		scope.tenantID = currentScope.tenantID
+	}
+	return context.WithValue(ctx, tenantScopeKey{}, scope)
+}
+
+// CrossTenant disables the tenant scoping for the query, to be used by admin and system operations
+func CrossTenant() QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, microappError.DatabaseError) {
+		return db.WithContext(WithCrossTenant(statementContext(db))), nil
+	}
+}
+
+// tenantOf returns the tenant to which the statement is scoped, uuid.Nil if it is not scoped
+func tenantOf(db *gorm.DB) uuid.UUID {
+	if scope, ok := statementContext(db).Value(tenantScopeKey{}).(*tenantScope); ok && !scope.crossTenant {
+		return scope.tenantID
+	}
+	return uuid.Nil
+}
+
+func statementContext(db *gorm.DB) context.Context {
+	if db.Statement != nil && db.Statement.Context != nil {
+		return db.Statement.Context
+	}
+	return context.Background()
+}
+
+func (plugin *TenantScopePlugin) isTenantModel(modelSchema *schema.Schema) bool {
+	if modelSchema == nil {
+		return false
+	}
+	if isTenantModel, ok := plugin.tenantModels.Load(modelSchema.ModelType); ok {
+		return isTenantModel.(bool)
+	}
+	isTenantModel := false
+	if modelType := modelSchema.ModelType; modelType.Kind() == reflect.Struct {
+		field, ok := modelType.FieldByName("TenantBase")
+		isTenantModel = ok && field.Anonymous && field.Type == tenantBaseType
+	}
+	plugin.tenantModels.Store(modelSchema.ModelType, isTenantModel)
+	return isTenantModel
+}
+
+func (plugin *TenantScopePlugin) addTenantCondition(db *gorm.DB) {
+	tenantID := tenantOf(db)
+	if tenantID == uuid.Nil || db.Error != nil || !plugin.isTenantModel(db.Statement.Schema) {
+		return
+	}
+	tenantField := db.Statement.Schema.LookUpField("TenantID")
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantField.DBName}, Value: tenantID},
+	}})
+}
+
+func (plugin *TenantScopePlugin) stampTenant(db *gorm.DB) {
+	tenantID := tenantOf(db)
+	if tenantID == uuid.Nil || db.Error != nil || !plugin.isTenantModel(db.Statement.Schema) {
+		return
+	}
+	tenantField := db.Statement.Schema.LookUpField("TenantID")
+
+	stamp := func(entity reflect.Value) {
+		value, isZero := tenantField.ValueOf(entity)
+		if isZero {
+			if err := tenantField.Set(entity, tenantID); err != nil {
+				db.AddError(err)
+			}
+		} else if value.(uuid.UUID) != tenantID {
+			db.AddError(ErrTenantMismatch)
+		}
+	}
+
+	switch db.Statement.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < db.Statement.ReflectValue.Len(); i++ {
+			stamp(reflect.Indirect(db.Statement.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		stamp(db.Statement.ReflectValue)
+	}
+}
+
+// SetTenant scopes the queries, updates and deletes of the tenant models in the unit of work to the given tenant and stamps it on the created entities.
+// The scoping requires TenantScopePlugin to be registered with the database, uuid.Nil disables the scoping.
+func (uow *UnitOfWork) SetTenant(tenantID uuid.UUID) {
+	uow.tenantID = tenantID
+	uow.DB = uow.DB.WithContext(WithTenant(statementContext(uow.DB), tenantID))
+}
+
+// TenantID returns the tenant to which the unit of work is scoped, uuid.Nil if it is not scoped
+func (uow *UnitOfWork) TenantID() uuid.UUID {
+	return uow.tenantID
+}
+
+// CrossTenant returns a unit of work sharing the transaction of this unit of work in which the tenant scoping is disabled,
+// to be used by admin and system operations. The returned unit of work must not be committed, commit this unit of work instead.
+func (uow *UnitOfWork) CrossTenant() *UnitOfWork {
+	crossTenantUOW := *uow
+	crossTenantUOW.DB = uow.DB.WithContext(WithCrossTenant(statementContext(uow.DB)))
+	crossTenantUOW.committed = true // Commit and Complete of the shared transaction are left to this unit of work
+	return &crossTenantUOW
+}
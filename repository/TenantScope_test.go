@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/islax/microapp/model"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+)
+
+type tenantItem struct {
+	model.TenantBase
+	Name string
+}
+
+func TestTenantScopePlugin(t *testing.T) {
+	db := newTestDB(t, []gorm.Plugin{NewTenantScopePlugin()}, &tenantItem{})
+	tenantID, otherTenantID := uuid.NewV4(), uuid.NewV4()
+	items := []tenantItem{
+		{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: tenantID}, Name: "a"},
+		{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: tenantID}, Name: "b"},
+		{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: otherTenantID}, Name: "c"},
+	}
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	scopedUOW := func() *UnitOfWork {
+		uow := newTestUOW(db, false)
+		uow.SetTenant(tenantID)
+		return uow
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		var names []string
+		if err := uow.DB.Model(&tenantItem{}).Order("name").Pluck("name", &names).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Errorf("Expected only the items of the tenant, Actual %v", names)
+		}
+	})
+
+	t.Run("Row", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		var count int
+		if err := uow.DB.Model(&tenantItem{}).Select("count(*)").Row().Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("Expected the row query to be scoped, Actual [%v]", count)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		result := uow.DB.Model(&tenantItem{}).Where("name IN ?", []string{"a", "c"}).Update("name", "x")
+		if result.Error != nil || result.RowsAffected != 1 {
+			t.Errorf("Expected only the item of the tenant to be updated, Actual [%v] [%v]", result.RowsAffected, result.Error)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		result := uow.DB.Where("name IN ?", []string{"a", "c"}).Delete(&tenantItem{})
+		if result.Error != nil || result.RowsAffected != 1 {
+			t.Errorf("Expected only the item of the tenant to be deleted, Actual [%v] [%v]", result.RowsAffected, result.Error)
+		}
+	})
+
+	t.Run("CreateStampsTenant", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		item := &tenantItem{TenantBase: model.TenantBase{ID: uuid.NewV4()}, Name: "d"}
+		if err := uow.DB.Create(item).Error; err != nil {
+			t.Fatal(err)
+		}
+		if item.TenantID != tenantID {
+			t.Errorf("Expected the tenant to be stamped, Actual [%v]", item.TenantID)
+		}
+	})
+
+	t.Run("CreateForOtherTenant", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		item := &tenantItem{TenantBase: model.TenantBase{ID: uuid.NewV4(), TenantID: otherTenantID}, Name: "d"}
+		if err := uow.DB.Create(item).Error; !errors.Is(err, ErrTenantMismatch) {
+			t.Errorf("Expected ErrTenantMismatch, Actual [%v]", err)
+		}
+	})
+
+	t.Run("CrossTenant", func(t *testing.T) {
+		uow := scopedUOW()
+		defer uow.Complete()
+		var all []tenantItem
+		if err := uow.CrossTenant().DB.Find(&all).Error; err != nil || len(all) != 3 {
+			t.Errorf("Expected the cross tenant unit of work not to be scoped, Actual [%v] [%v]", len(all), err)
+		}
+		if err := NewRepository().GetAll(uow, &all, []QueryProcessor{CrossTenant()}); err != nil || len(all) != 3 {
+			t.Errorf("Expected the cross tenant query not to be scoped, Actual [%v] [%v]", len(all), err)
+		}
+		if err := NewRepository().GetAll(uow, &all, nil); err != nil || len(all) != 2 {
+			t.Errorf("Expected the unit of work to remain scoped, Actual [%v] [%v]", len(all), err)
+		}
+	})
+
+	t.Run("NotScoped", func(t *testing.T) {
+		uow := newTestUOW(db, false)
+		defer uow.Complete()
+		var all []tenantItem
+		if err := uow.DB.Find(&all).Error; err != nil || len(all) != 3 {
+			t.Errorf("Expected the unit of work without tenant not to be scoped, Actual [%v] [%v]", len(all), err)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"github.com/rs/zerolog"
+)
+
+// transactionHooks holds the functions to be run after the transaction of a unit of work is committed or rolled back.
+// The hooks of a nested unit of work are moved to its parent when it is committed, so that they run along with the outermost transaction.
+type transactionHooks struct {
+	onCommit   []func()
+	onRollback []func()
+	parent     *transactionHooks
+	logger     *zerolog.Logger
+}
+
+func newTransactionHooks(parent *transactionHooks, logger *zerolog.Logger) *transactionHooks {
+	if parent != nil {
+		logger = parent.logger
+	}
+	return &transactionHooks{parent: parent, logger: logger}
+}
+
+// OnCommit registers a function to be run after the transaction is committed, e.g. to dispatch events or invalidate caches.
+// The functions are run in the order they were registered, for a nested unit of work they run once the outermost unit of work is committed.
+func (uow *UnitOfWork) OnCommit(hook func()) {
+	if uow.hooks == nil {
+		uow.hooks = newTransactionHooks(nil, nil)
+	}
+	uow.hooks.onCommit = append(uow.hooks.onCommit, hook)
+}
+
+// OnRollback registers a function to be run after the transaction is rolled back or fails to commit.
+// The functions are run in the order they were registered, for a nested unit of work they run when either it or the outermost unit of work is rolled back.
+func (uow *UnitOfWork) OnRollback(hook func()) {
+	if uow.hooks == nil {
+		uow.hooks = newTransactionHooks(nil, nil)
+	}
+	uow.hooks.onRollback = append(uow.hooks.onRollback, hook)
+}
+
+// committed runs the commit hooks, or moves the hooks to the parent for a nested unit of work
+func (hooks *transactionHooks) committed() {
+	if hooks == nil {
+		return
+	}
+	onCommit, onRollback := hooks.onCommit, hooks.onRollback
+	hooks.onCommit, hooks.onRollback = nil, nil
+	if hooks.parent != nil {
+		hooks.parent.onCommit = append(hooks.parent.onCommit, onCommit...)
+		hooks.parent.onRollback = append(hooks.parent.onRollback, onRollback...)
+		return
+	}
+	hooks.run(onCommit)
+}
+
+// rolledBack discards the commit hooks and runs the rollback hooks
+func (hooks *transactionHooks) rolledBack() {
+	if hooks == nil {
+		return
+	}
+	onRollback := hooks.onRollback
+	hooks.onCommit, hooks.onRollback = nil, nil
+	hooks.run(onRollback)
+}
+
+// run runs the hooks in order, a panicking hook is logged and does not prevent the remaining hooks from running
+func (hooks *transactionHooks) run(functions []func()) {
+	for _, function := range functions {
+		func() {
+			defer func() {
+				if r := recover(); r != nil && hooks.logger != nil {
+					hooks.logger.Error().Interface("panic", r).Msg("Transaction hook failed.")
+				}
+			}()
+			function()
+		}()
+	}
+}
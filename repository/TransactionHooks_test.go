@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransactionHooks(t *testing.T) {
+	tests := []struct {
+		name            string
+		nestedCommitted bool
+		rootCommitted   bool
+		want            []string
+	}{
+		{"CommitAll", true, true, []string{"root.commit.1", "nested.commit", "root.commit.2"}},
+		{"NestedRolledBack", false, true, []string{"nested.rollback", "root.commit.1", "root.commit.2"}},
+		{"RootRolledBack", true, false, []string{"root.rollback", "nested.rollback"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			record := func(name string) func() { return func() { got = append(got, name) } }
+
+			root := newTransactionHooks(nil, nil)
+			root.onCommit = append(root.onCommit, record("root.commit.1"))
+			root.onRollback = append(root.onRollback, record("root.rollback"))
+			nested := newTransactionHooks(root, nil)
+			nested.onCommit = append(nested.onCommit, record("nested.commit"))
+			nested.onRollback = append(nested.onRollback, record("nested.rollback"))
+			if test.nestedCommitted {
+				nested.committed()
+			} else {
+				nested.rolledBack()
+			}
+			root.onCommit = append(root.onCommit, record("root.commit.2"))
+			if test.rootCommitted {
+				root.committed()
+			} else {
+				root.rolledBack()
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected hooks %v, Actual %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestTransactionHooksRecoverFromPanic(t *testing.T) {
+	hooks := newTransactionHooks(nil, nil)
+	ran := false
+	hooks.onCommit = append(hooks.onCommit, func() { panic("failed") }, func() { ran = true })
+	hooks.committed()
+	if !ran {
+		t.Error("Expected the hook after the panicking hook to run")
+	}
+}
@@ -46,7 +46,7 @@ func (controller *SettingsMetadataMigrationController) RegisterRoutes(muxRouter
 }
 
 func (controller *SettingsMetadataMigrationController) migratetenants(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
-	context := controller.app.NewExecutionContext(token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.migrate", true, false)
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.migrate", true, false)
 	uow := context.GetUOW()
 	defer uow.Complete()
 
@@ -83,7 +83,7 @@ func (controller *SettingsMetadataMigrationController) migratetenants(w http.Res
 				continue
 			}
 			if tenant.Settings != "{}" {
-				if err := controller.repository.Add(uow, tenant); err != nil {
+				if err := controller.addTenant(uow, tenant); err != nil {
 					context.LogError(err, "Unable to add tenant settings.")
 					failureTenants = append(failureTenants, tenantIDStr)
 					continue
@@ -92,12 +92,16 @@ func (controller *SettingsMetadataMigrationController) migratetenants(w http.Res
 		}
 		successTenants = append(successTenants, tenantIDStr)
 	}
-	uow.Commit()
+	if err := uow.Commit(); err != nil {
+		context.LogError(err, "Unable to commit tenant settings.")
+		microappWeb.RespondError(w, err)
+		return
+	}
 	microappWeb.RespondJSON(w, http.StatusOK, map[string]interface{}{"successTenants": successTenants, "failureTenants": failureTenants})
 }
 
 func (controller *SettingsMetadataMigrationController) migratetenant(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
-	context := controller.app.NewExecutionContext(token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.migrate", true, false)
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.migrate", true, false)
 	uow := context.GetUOW()
 	defer uow.Complete()
 
@@ -137,11 +141,29 @@ func (controller *SettingsMetadataMigrationController) migratetenant(w http.Resp
 			return
 		}
 	}
-	uow.Commit()
+	if err := uow.Commit(); err != nil {
+		context.LogError(err, "Unable to commit tenant settings.")
+		microappWeb.RespondError(w, err)
+		return
+	}
 	context.LoggerEventActionCompletion().Str("TenantId", stringTenantID).Msg("Tenant settings migrated")
 	microappWeb.RespondJSON(w, http.StatusOK, "")
 }
 
+// addTenant adds the tenant settings in a nested unit of work, so that the failure of a tenant does not roll back the other tenants
+func (controller *SettingsMetadataMigrationController) addTenant(uow *microappRepo.UnitOfWork, tenant *tenantModel.TenantSettings) error {
+	tenantUOW, err := uow.Nested()
+	if err != nil {
+		return err
+	}
+	defer tenantUOW.Complete()
+
+	if err := controller.repository.Add(tenantUOW, tenant); err != nil {
+		return err
+	}
+	return tenantUOW.Commit()
+}
+
 func (controller *SettingsMetadataMigrationController) checkAndInitializeSettingsMetadata() error {
 	if len(controller.settingsMetadatas) == 0 && controller.app.Config.IsSet(config.EvSuffixForSettingsMetadataPath) {
 		settingMetadata, err := controller.initSettingsMetaData(config.EvSuffixForSettingsMetadataPath)
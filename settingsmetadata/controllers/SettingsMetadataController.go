@@ -55,7 +55,7 @@ func (controller *SettingsMetadataController) RegisterRoutes(muxRouter *mux.Rout
 }
 
 func (controller *SettingsMetadataController) getSettingsMetadata(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
-	context := controller.app.NewExecutionContext(token, microapp.GetCorrelationIDFromRequest(r), "settingsmetadata.get", true, true)
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "settingsmetadata.get", true, true)
 	uow := context.GetUOW()
 	defer uow.Complete()
 	if err := controller.checkAndInitializeSettingsMetadata(); err != nil {
@@ -82,7 +82,7 @@ func (controller *SettingsMetadataController) getSettingsMetadata(w http.Respons
 }
 
 func (controller *SettingsMetadataController) get(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
-	context := controller.app.NewExecutionContext(token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.get", true, true)
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.get", true, true)
 	uow := context.GetUOW()
 	defer uow.Complete()
 	params := mux.Vars(r)
@@ -130,7 +130,7 @@ func (controller *SettingsMetadataController) get(w http.ResponseWriter, r *http
 }
 
 func (controller *SettingsMetadataController) update(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
-	context := controller.app.NewExecutionContext(token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.update", true, false)
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.update", true, false)
 	uow := context.GetUOW()
 	defer uow.Complete()
 	params := mux.Vars(r)
@@ -183,7 +183,6 @@ func (controller *SettingsMetadataController) update(w http.ResponseWriter, r *h
 		}
 	}
 
-	uow.Commit()
 	responseDTO := toDTO(tenant)
 
 	err = tenant.GetTenantSettings(controller.settingsMetadatas, map[string]interface{}{})
@@ -193,13 +192,30 @@ func (controller *SettingsMetadataController) update(w http.ResponseWriter, r *h
 		return
 	}
 
+	topic := strings.ToLower(strings.ReplaceAll(controller.app.Name, " ", "")) + ".settingsupdated"
+	if controller.app.IsOutboxEnabled() {
+		if err = uow.Enqueue(topic, toDTO(tenant)); err != nil {
+			context.LogError(err, fmt.Sprintf(microappLog.MessageGenericErrorTemplate, "enqueuing settings updated event"))
+			microappWeb.RespondError(w, err)
+			return
+		}
+	} else {
+		uow.OnCommit(func() {
+			controller.app.DispatchEventWithOptions(context, topic, toDTO(tenant), nil)
+		})
+	}
+	if err = uow.Commit(); err != nil {
+		context.LogError(err, microappLog.MessageUpdateEntityError)
+		microappWeb.RespondError(w, err)
+		return
+	}
+
 	context.LoggerEventActionCompletion().Str("TenantId", responseDTO.ID.String()).Msg("Tenant settings updated")
-	controller.app.DispatchEvent(token.Raw, context.GetCorrelationID(), strings.ToLower(strings.ReplaceAll(controller.app.Name, " ", ""))+".settingsupdated", toDTO(tenant))
 	microappWeb.RespondJSON(w, http.StatusOK, nil)
 }
 
 func (controller *SettingsMetadataController) getByName(w http.ResponseWriter, r *http.Request, token *microappSecurity.JwtToken) {
-	context := controller.app.NewExecutionContext(token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.get", true, true)
+	context := controller.app.NewExecutionContextWithContext(r.Context(), token, microapp.GetCorrelationIDFromRequest(r), "tenantsettings.get", true, true)
 	uow := context.GetUOW()
 	defer uow.Complete()
 
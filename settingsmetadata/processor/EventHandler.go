@@ -5,14 +5,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 
 	"github.com/islax/microapp"
 	"github.com/islax/microapp/config"
 	microappCtx "github.com/islax/microapp/context"
+	"github.com/islax/microapp/event"
 	"github.com/islax/microapp/event/monitor"
+	"github.com/islax/microapp/event/router"
 	microappLog "github.com/islax/microapp/log"
 	microappRepo "github.com/islax/microapp/repository"
-	microappSecurity "github.com/islax/microapp/security"
 	tenantModel "github.com/islax/microapp/settingsmetadata/model"
 	uuid "github.com/satori/go.uuid"
 )
@@ -23,6 +25,12 @@ type EventHandler struct {
 	repository        microappRepo.Repository
 	eventChannel      chan *monitor.EventInfo
 	settingsMetadatas []tenantModel.SettingsMetaData
+	mutex             sync.Mutex
+}
+
+type tenantEventPayload struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"displayName"`
 }
 
 // NewEventHandler creates new instance of TenantActionEventHandler
@@ -32,69 +40,65 @@ func NewEventHandler(app *microapp.App, repository microappRepo.Repository, even
 
 // Start will start listening to channel for events
 func (handler *EventHandler) Start() {
-	for eventPayload := range handler.eventChannel {
-		switch eventPayload.Name {
-		case "tenant.added":
-			handler.processTenantAdd(eventPayload)
-		case "tenant.deleted":
-			handler.processTenantDelete(eventPayload)
+	eventRouter := router.NewRouter(handler.app)
+	logger := handler.app.Logger("EventHandler")
+	// Events of a tenant are handled in order, so that a tenant is not deleted before it is added
+	eventRouter.SetOrderingKey(func(eventInfo *monitor.EventInfo) string {
+		var payload tenantEventPayload
+		if err := json.Unmarshal([]byte(eventInfo.Payload), &payload); err != nil {
+			// The event is rejected by the route as its payload can not be decoded, order it by routing key meanwhile
+			logger.Warn().Err(err).Str("event", eventInfo.Name).Msg("Unable to read the tenant of the event, ordering it by routing key.")
+			return event.NormalizeTopic(eventInfo.Name)
 		}
+		return payload.ID.String()
+	})
+	if processedMessageStore, err := handler.app.EnableProcessedMessageStore(); err != nil {
+		logger.Error().Err(err).Msg("Failed to enable processed message store, events will not be deduplicated.")
+	} else {
+		eventRouter.SetProcessedMessageStore(processedMessageStore)
 	}
+	eventRouter.HandleWithPayload("tenant.added", "tenantsettings.add", tenantEventPayload{}, handler.processTenantAdd)
+	eventRouter.HandleWithPayload("tenant.deleted", "tenantsettings.delete", tenantEventPayload{}, handler.processTenantDelete)
+	eventRouter.Start(handler.eventChannel)
 }
 
-func (handler *EventHandler) processTenantAdd(eventPayload *monitor.EventInfo) {
-	token, _ := microappSecurity.GetTokenFromRawAuthHeader(handler.app.Config, eventPayload.RawToken)
-	context := handler.app.NewExecutionContext(token, eventPayload.CorelationID, "tenantsettings.add", true, false)
-	uow := context.GetUOW()
-	defer uow.Complete()
-	handler.createTenantSettingsMetadata(uow, context, eventPayload)
-	uow.Commit()
-	context.Logger(microappLog.EventTypeSuccess, microappLog.EventCodeActionComplete).Info().Msg("Finished adding new tenant settings")
-}
-
-func (handler *EventHandler) createTenantSettingsMetadata(uow *microappRepo.UnitOfWork, context microappCtx.ExecutionContext, eventPayload *monitor.EventInfo) {
-	eventData := make(map[string]interface{})
-	var tenantID uuid.UUID
+func (handler *EventHandler) processTenantAdd(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo, payload interface{}) error {
+	tenantEvent := payload.(*tenantEventPayload)
 
 	if err := handler.checkAndInitializeSettingsMetadata(); err != nil {
 		context.LogError(err, fmt.Sprintf(microappLog.MessageGenericErrorTemplate, "initializing settings-metadata"))
-		return
+		return err
 	}
-	json.Unmarshal([]byte(eventPayload.Payload), &eventData)
-	tenantID, _ = uuid.FromString(eventData["id"].(string))
-	tenantDisplayName := eventData["displayName"].(string)
 
-	tenant, err := tenantModel.NewTenant(context, tenantID, map[string]interface{}{"displayName": tenantDisplayName}, handler.settingsMetadatas)
+	tenant, err := tenantModel.NewTenant(context, tenantEvent.ID, map[string]interface{}{"displayName": tenantEvent.DisplayName}, handler.settingsMetadatas)
 	if err != nil {
 		context.LogError(err, "Unable to add new tenant.")
-		return
+		return err
 	}
-	if err := handler.repository.Add(uow, tenant); err != nil {
+	if err := handler.repository.Add(context.GetUOW(), tenant); err != nil {
 		context.LogError(err, "Unable to add tenant settings.")
-		return
+		return err
 	}
-}
 
-func (handler *EventHandler) processTenantDelete(eventPayload *monitor.EventInfo) {
-	token, _ := microappSecurity.GetTokenFromRawAuthHeader(handler.app.Config, eventPayload.RawToken)
-	context := handler.app.NewExecutionContext(token, eventPayload.CorelationID, "tenantsettings.delete", true, false)
-	uow := context.GetUOW()
-	defer uow.Complete()
-
-	eventData := make(map[string]string)
-	json.Unmarshal([]byte(eventPayload.Payload), &eventData)
+	context.Logger(microappLog.EventTypeSuccess, microappLog.EventCodeActionComplete).Info().Msg("Finished adding new tenant settings")
+	return nil
+}
 
-	tenantID, _ := uuid.FromString(eventData["id"])
-	if err := handler.repository.Delete(uow, tenantModel.TenantSettings{}, tenantID); err != nil {
+func (handler *EventHandler) processTenantDelete(context microappCtx.ExecutionContext, eventInfo *monitor.EventInfo, payload interface{}) error {
+	tenantID := payload.(*tenantEventPayload).ID
+	// Hard delete, so that a tenant re-created with the same id does not conflict with the soft deleted row
+	if err := handler.repository.DeletePermanent(context.GetUOW(), tenantModel.TenantSettings{}, tenantID); err != nil {
 		context.Logger(microappLog.EventTypeServiceDataReplication, "Key_TenantDataReplication").Error().Err(err).Str("forTenant", tenantID.String()).Msg("Unable to delete tenant.")
-		return
+		return err
 	}
 
-	uow.Commit()
 	context.LoggerEventActionCompletion().Msg("Tenant deleted.")
+	return nil
 }
 
 func (handler *EventHandler) checkAndInitializeSettingsMetadata() error {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
 	if len(handler.settingsMetadatas) == 0 {
 		settingMetadata, err := handler.initSettingsMetaData(config.EvSuffixForSettingsMetadataPath)
 		if err != nil {
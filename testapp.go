@@ -19,6 +19,7 @@ import (
 	"gorm.io/gorm/schema"
 
 	microappError "github.com/islax/microapp/error"
+	"github.com/islax/microapp/event"
 
 	uuid "github.com/satori/go.uuid"
 
@@ -34,6 +35,7 @@ type TestApp struct {
 	application             *App
 	controllerRouteProvider func(*App) []RouteSpecifier
 	dbInitializer           func(db *gorm.DB)
+	eventBroker             *event.InMemoryBroker
 }
 
 // NewTestApp returns new instance of TestApp
@@ -66,9 +68,16 @@ func NewTestApp(appName string, controllerRouteProvider func(*App) []RouteSpecif
 
 	rand.Seed(time.Now().UnixNano())
 	randomAPIPort := fmt.Sprintf("10%v%v%v", rand.Intn(9), rand.Intn(9), rand.Intn(9)) // Generating random API port so that if multiple tests can run parallel
-	application := New(appName, map[string]interface{}{"API_PORT": randomAPIPort, "JWT_PRIVATE_KEY_PATH": "certs/star.dev.local.key", "JWT_PUBLIC_KEY_PATH": "certs/star.dev.local.crt"}, zerolog.New(os.Stdout), db, nil, nil)
+	appLogger := zerolog.New(os.Stdout)
+	eventBroker := event.NewInMemoryBroker(&appLogger)
+	application := New(appName, map[string]interface{}{"API_PORT": randomAPIPort, "JWT_PRIVATE_KEY_PATH": "certs/star.dev.local.key", "JWT_PUBLIC_KEY_PATH": "certs/star.dev.local.crt"}, appLogger, db, nil, eventBroker)
 
-	return &TestApp{application: application, controllerRouteProvider: controllerRouteProvider, dbInitializer: dbInitializer}
+	return &TestApp{application: application, controllerRouteProvider: controllerRouteProvider, dbInitializer: dbInitializer, eventBroker: eventBroker}
+}
+
+// EventBroker returns the in-memory broker to which the events dispatched by the app are published, use monitor.NewInMemoryEventMonitor to consume them
+func (testApp *TestApp) EventBroker() *event.InMemoryBroker {
+	return testApp.eventBroker
 }
 
 // Initialize prepares the app for testing
@@ -81,10 +90,6 @@ func (testApp *TestApp) Initialize() {
 // Stop the app
 func (testApp *TestApp) Stop() {
 	testApp.application.Stop()
-	sqlDB, err := testApp.application.DB.DB()
-	if err != nil {
-		sqlDB.Close()
-	}
 	os.Remove("./test_islax.db")
 }
 
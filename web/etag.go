@@ -0,0 +1,40 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	microappError "github.com/islax/microapp/error"
+)
+
+// ETag returns the entity tag for the version of the entity
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// SetETag sets the ETag header for the version of the entity
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// RespondJSONWithETag makes the response with payload as json format and adds ETag header for the version of the entity
+func RespondJSONWithETag(w http.ResponseWriter, status int, version int64, payload interface{}) {
+	SetETag(w, version)
+	RespondJSON(w, status, payload)
+}
+
+// GetIfMatchVersion returns the version of the entity in the If-Match header, set it on the entity before updating so that
+// the update fails with ConflictError if the entity was modified since. The ok is false if the header is missing or is '*'.
+func GetIfMatchVersion(r *http.Request) (version int64, ok bool, err error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, false, nil
+	}
+	ifMatch = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	version, err = strconv.ParseInt(ifMatch, 10, 64)
+	if err != nil {
+		return 0, false, microappError.NewValidationError(microappError.ErrorCodeInvalidFields, map[string]string{"If-Match": microappError.ErrorCodeInvalidValue})
+	}
+	return version, true, nil
+}
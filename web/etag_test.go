@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	microappError "github.com/islax/microapp/error"
+)
+
+func TestRespondJSONWithETag(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	RespondJSONWithETag(recorder, http.StatusOK, 3, map[string]string{"id": "1"})
+	if etag := recorder.Header().Get("ETag"); etag != `"3"` {
+		t.Errorf("Expected ETag [\"3\"], Actual [%v]", etag)
+	}
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status [%v], Actual [%v]", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestGetIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		version int64
+		ok      bool
+		invalid bool
+	}{
+		{"", 0, false, false},
+		{"*", 0, false, false},
+		{`"3"`, 3, true, false},
+		{` W/"3" `, 3, true, false},
+		{ETag(42), 42, true, false},
+		{`"abc"`, 0, false, true},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("PUT", "/", nil)
+		if test.ifMatch != "" {
+			request.Header.Set("If-Match", test.ifMatch)
+		}
+		version, ok, err := GetIfMatchVersion(request)
+		if version != test.version || ok != test.ok {
+			t.Errorf("If-Match [%v]: Expected [%v, %v], Actual [%v, %v]", test.ifMatch, test.version, test.ok, version, ok)
+		}
+		if _, isValidationError := err.(microappError.ValidationError); isValidationError != test.invalid {
+			t.Errorf("If-Match [%v]: Expected validation error [%v], Actual [%v]", test.ifMatch, test.invalid, err)
+		}
+	}
+}
+
+func TestRespondErrorForConflict(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	RespondError(recorder, microappError.NewConflictError("Tenant", "1"))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Expected status [%v], Actual [%v]", http.StatusConflict, recorder.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RequestedFields returns the fields requested by the 'fields' query param along with the top level associations requested by the 'expand' query param,
+// e.g. id, name and owner for fields=id,name&expand=owner.address. It returns nil if the 'fields' query param is missing.
+func RequestedFields(r *http.Request) []string {
+	queryParams := r.URL.Query()
+	fields := appendFields(nil, queryParams.Get("fields"), false)
+	if len(fields) == 0 {
+		return nil
+	}
+	return appendFields(fields, queryParams.Get("expand"), true)
+}
+
+func appendFields(fields []string, value string, topLevel bool) []string {
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if topLevel {
+			field = strings.SplitN(field, ".", 2)[0]
+		}
+		if field == "" {
+			continue
+		}
+		exists := false
+		for _, existing := range fields {
+			exists = exists || existing == field
+		}
+		if !exists {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// TrimFields returns the json of the payload, an object or an array of objects, with only the given fields of the objects.
+// The whole payload is returned if fields is empty.
+func TrimFields(payload interface{}, fields []string) ([]byte, error) {
+	response, err := json.Marshal(payload)
+	if err != nil || len(fields) == 0 {
+		return response, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(response))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keep[field] = true
+	}
+	trim := func(value interface{}) {
+		if object, ok := value.(map[string]interface{}); ok {
+			for key := range object {
+				if !keep[key] {
+					delete(object, key)
+				}
+			}
+		}
+	}
+	if array, ok := value.([]interface{}); ok {
+		for _, item := range array {
+			trim(item)
+		}
+	} else {
+		trim(value)
+	}
+	return json.Marshal(value)
+}
+
+// RespondJSONWithFields makes the response with payload as json format trimmed to the fields requested by the request, see RequestedFields
+func RespondJSONWithFields(w http.ResponseWriter, r *http.Request, status int, payload interface{}) {
+	response, err := TrimFields(payload, RequestedFields(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
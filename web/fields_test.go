@@ -0,0 +1,54 @@
+package web
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRequestedFields(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"expand=owner", nil},
+		{"fields=id,name", []string{"id", "name"}},
+		{"fields=id,%20name,,id&expand=owner.address,owner,items", []string{"id", "name", "owner", "items"}},
+	}
+	for _, test := range tests {
+		if fields := RequestedFields(httptest.NewRequest("GET", "/?"+test.query, nil)); !reflect.DeepEqual(fields, test.want) {
+			t.Errorf("Query [%v]: Expected [%v], Actual [%v]", test.query, test.want, fields)
+		}
+	}
+}
+
+func TestTrimFields(t *testing.T) {
+	type entity struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Amount int64  `json:"amount"`
+	}
+	tests := []struct {
+		name    string
+		payload interface{}
+		fields  []string
+		want    string
+	}{
+		{"AllFields", entity{"1", "a", 10}, nil, `{"id":"1","name":"a","amount":10}`},
+		{"Object", entity{"1", "a", 10}, []string{"id", "amount"}, `{"amount":10,"id":"1"}`},
+		{"Array", []entity{{"1", "a", 9007199254740993}, {"2", "b", 0}}, []string{"amount"}, `[{"amount":9007199254740993},{"amount":0}]`},
+		{"UnknownField", entity{"1", "a", 10}, []string{"other"}, `{}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := TrimFields(test.payload, test.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(response) != test.want {
+				t.Errorf("Expected [%v], Actual [%v]", test.want, string(response))
+			}
+		})
+	}
+}
@@ -8,6 +8,9 @@ import (
 	microappError "github.com/islax/microapp/error"
 )
 
+// StatusClientClosedRequest is the non standard status code of the response to a request cancelled as the client disconnected
+const StatusClientClosedRequest = 499
+
 // RespondJSON makes the response with payload as json format
 func RespondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	response, err := json.Marshal(payload)
@@ -47,6 +50,12 @@ func RespondError(w http.ResponseWriter, err error) {
 		RespondJSON(w, http.StatusBadRequest, err)
 	case microappError.HTTPResourceNotFound:
 		RespondJSON(w, http.StatusNotFound, err)
+	case microappError.ConflictError:
+		RespondJSON(w, http.StatusConflict, err)
+	case microappError.TimeoutError:
+		RespondJSON(w, http.StatusGatewayTimeout, err)
+	case microappError.CanceledError:
+		RespondJSON(w, StatusClientClosedRequest, err)
 	case microappError.HTTPError:
 		httpError := err.(microappError.HTTPError)
 		RespondErrorMessage(w, httpError.HTTPStatus, httpError.ErrorKey)
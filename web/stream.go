@@ -0,0 +1,156 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Content types of the streamed responses
+const (
+	ContentTypeNDJSON = "application/x-ndjson"
+	ContentTypeCSV    = "text/csv"
+)
+
+// streamFlushInterval is the number of rows after which the response is flushed to the client
+const streamFlushInterval = 100
+
+// CSVColumn maps a field of the json of the streamed rows to a CSV column
+type CSVColumn struct {
+	Header string // header of the column, the field is used if empty
+	Field  string // field of the json of the row
+}
+
+// StreamContentType returns the content type of the streamed response as per the Accept header, CSV if it accepts text/csv else NDJSON
+func StreamContentType(r *http.Request) string {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0]); strings.EqualFold(mediaType, ContentTypeCSV) {
+			return ContentTypeCSV
+		}
+	}
+	return ContentTypeNDJSON
+}
+
+// RespondStream makes the response with the rows written by stream, e.g. using Repository.Stream, as NDJSON or CSV as per StreamContentType.
+// The CSV columns are mapped using columns, all the fields of the first row sorted by name if empty. The response is flushed every 100 rows.
+// If stream fails before writing any row, the error is responded using RespondError, otherwise the response is truncated and the error is returned.
+func RespondStream(w http.ResponseWriter, r *http.Request, columns []CSVColumn, stream func(write func(row interface{}) error) error) error {
+	writer := &streamWriter{w: w, contentType: StreamContentType(r), columns: columns}
+	if err := stream(writer.write); err != nil {
+		if writer.rows == 0 {
+			RespondError(w, err)
+		}
+		return err
+	}
+	if writer.rows == 0 {
+		writer.start()
+	}
+	return writer.flush()
+}
+
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	columns     []CSVColumn
+	csvWriter   *csv.Writer
+	rows        int
+}
+
+func (writer *streamWriter) start() {
+	writer.w.Header().Set("Content-Type", writer.contentType)
+	writer.w.WriteHeader(http.StatusOK)
+	if writer.contentType == ContentTypeCSV {
+		writer.csvWriter = csv.NewWriter(writer.w)
+		if len(writer.columns) > 0 {
+			writer.writeCSVHeader()
+		}
+	}
+}
+
+func (writer *streamWriter) write(row interface{}) error {
+	if writer.rows == 0 {
+		writer.start()
+	}
+	writer.rows++
+	var err error
+	if writer.contentType == ContentTypeCSV {
+		err = writer.writeCSV(row)
+	} else {
+		err = json.NewEncoder(writer.w).Encode(row)
+	}
+	if err == nil && writer.rows%streamFlushInterval == 0 {
+		err = writer.flush()
+	}
+	return err
+}
+
+func (writer *streamWriter) writeCSV(row interface{}) error {
+	body, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	fields := make(map[string]interface{})
+	if err := decoder.Decode(&fields); err != nil {
+		return err
+	}
+	if len(writer.columns) == 0 {
+		for field := range fields {
+			writer.columns = append(writer.columns, CSVColumn{Field: field})
+		}
+		sort.Slice(writer.columns, func(i, j int) bool { return writer.columns[i].Field < writer.columns[j].Field })
+		if err := writer.writeCSVHeader(); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(writer.columns))
+	for i, column := range writer.columns {
+		if record[i], err = csvValue(fields[column.Field]); err != nil {
+			return err
+		}
+	}
+	return writer.csvWriter.Write(record)
+}
+
+func (writer *streamWriter) writeCSVHeader() error {
+	header := make([]string, len(writer.columns))
+	for i, column := range writer.columns {
+		header[i] = column.Header
+		if header[i] == "" {
+			header[i] = column.Field
+		}
+	}
+	return writer.csvWriter.Write(header)
+}
+
+func (writer *streamWriter) flush() error {
+	if writer.csvWriter != nil {
+		writer.csvWriter.Flush()
+		if err := writer.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := writer.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// csvValue formats the json value, objects and arrays are formatted as json
+func csvValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number, bool:
+		return fmt.Sprint(value), nil
+	}
+	body, err := json.Marshal(value)
+	return string(body), err
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRespondStream(t *testing.T) {
+	type entity struct {
+		ID     string            `json:"id"`
+		Amount float64           `json:"amount"`
+		Active bool              `json:"active"`
+		Note   *string           `json:"note"`
+		Tags   map[string]string `json:"tags"`
+	}
+	note := "a, \"b\""
+	rows := []entity{{"1", 10.5, true, &note, map[string]string{"k": "v"}}, {"2", 0, false, nil, nil}}
+	tests := []struct {
+		name       string
+		accept     string
+		columns    []CSVColumn
+		rows       []entity
+		err        error
+		wantStatus int
+		wantType   string
+		wantBody   string
+		wantErr    bool
+	}{
+		{"NDJSON", "", nil, rows, nil, http.StatusOK, ContentTypeNDJSON,
+			"{\"id\":\"1\",\"amount\":10.5,\"active\":true,\"note\":\"a, \\\"b\\\"\",\"tags\":{\"k\":\"v\"}}\n{\"id\":\"2\",\"amount\":0,\"active\":false,\"note\":null,\"tags\":null}\n", false},
+		{"CSVWithColumns", "text/html, text/csv;q=0.9", []CSVColumn{{"ID", "id"}, {"", "note"}, {"Amount", "amount"}}, rows, nil, http.StatusOK, ContentTypeCSV,
+			"ID,note,Amount\n1,\"a, \"\"b\"\"\",10.5\n2,,0\n", false},
+		{"CSVAllFields", "text/csv", nil, rows, nil, http.StatusOK, ContentTypeCSV,
+			"active,amount,id,note,tags\ntrue,10.5,1,\"a, \"\"b\"\"\",\"{\"\"k\"\":\"\"v\"\"}\"\nfalse,0,2,,\n", false},
+		{"CSVEmpty", "text/csv", []CSVColumn{{"ID", "id"}}, nil, nil, http.StatusOK, ContentTypeCSV, "ID\n", false},
+		{"ErrorBeforeRows", "", nil, nil, errors.New("failed"), http.StatusInternalServerError, "application/json", "{\"error\":\"Key_InternalError\"}", true},
+		{"ErrorAfterRows", "", nil, rows[:1], errors.New("failed"), http.StatusOK, ContentTypeNDJSON,
+			"{\"id\":\"1\",\"amount\":10.5,\"active\":true,\"note\":\"a, \\\"b\\\"\",\"tags\":{\"k\":\"v\"}}\n", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", test.accept)
+			w := httptest.NewRecorder()
+			err := RespondStream(w, r, test.columns, func(write func(row interface{}) error) error {
+				for _, row := range test.rows {
+					if err := write(row); err != nil {
+						return err
+					}
+				}
+				return test.err
+			})
+			if (err != nil) != test.wantErr {
+				t.Errorf("Expected error [%v], Actual [%v]", test.wantErr, err)
+			}
+			if w.Code != test.wantStatus || w.Header().Get("Content-Type") != test.wantType {
+				t.Errorf("Expected [%v %v], Actual [%v %v]", test.wantStatus, test.wantType, w.Code, w.Header().Get("Content-Type"))
+			}
+			if w.Body.String() != test.wantBody {
+				t.Errorf("Expected body [%v], Actual [%v]", test.wantBody, w.Body.String())
+			}
+		})
+	}
+}